@@ -0,0 +1,30 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+)
+
+const APIKeyHeader = `X-API-Key`
+
+// APIKeyAuthenticator authenticates requests by static keys bound to organizations
+type APIKeyAuthenticator struct {
+	// Keys maps API key to organization MSP id
+	Keys  map[string]string
+	Roles OrgRoles
+}
+
+func (a APIKeyAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	key := r.Header.Get(APIKeyHeader)
+	if key == `` {
+		return nil, ErrNoCredentials
+	}
+
+	for k, org := range a.Keys {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			return a.Roles.principal(`apikey:`+org, org, `apikey`)
+		}
+	}
+	return nil, errors.New(`invalid api key`)
+}
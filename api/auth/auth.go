@@ -0,0 +1,106 @@
+// Package auth authenticates REST callers and binds them to organization and role
+package auth
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo"
+)
+
+const (
+	RoleAgent    = `AGENT`
+	RoleMerchant = `MERCHANT`
+	RoleBank     = `BANK`
+
+	principalKey = `principal`
+)
+
+// ErrNoCredentials is returned by authenticator when request has no credentials of its kind
+var ErrNoCredentials = errors.New(`no credentials`)
+
+// Principal is authenticated caller, Org is MSP id of organization signing transactions
+type Principal struct {
+	Subject string `json:"subject"`
+	Org     string `json:"org"`
+	Role    string `json:"role"`
+	Method  string `json:"method"`
+}
+
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// OrgRoles maps organization MSP id to role, used when credentials don't carry role
+type OrgRoles map[string]string
+
+func (roles OrgRoles) principal(subject string, org string, method string) (*Principal, error) {
+	role, ok := roles[org]
+	if !ok {
+		return nil, errors.New(`organization is not bound to role: ` + org)
+	}
+	return &Principal{Subject: subject, Org: org, Role: role, Method: method}, nil
+}
+
+// Middleware
+// Authenticates request with first authenticator accepting credentials and stores principal in context
+// Authenticators of the same credentials kind, e.g. jwt with different secrets, are tried in order
+func Middleware(authenticators ...Authenticator) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			var rejected error
+			for _, a := range authenticators {
+				p, err := a.Authenticate(c.Request())
+				if err == ErrNoCredentials {
+					continue
+				}
+				if err != nil {
+					if rejected == nil {
+						rejected = err
+					}
+					continue
+				}
+				c.Set(principalKey, p)
+				return next(c)
+			}
+			if rejected != nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, rejected.Error())
+			}
+			return echo.NewHTTPError(http.StatusUnauthorized, `credentials are not presented`)
+		}
+	}
+}
+
+// GetPrincipal returns principal of authenticated request, nil if request isn't authenticated
+func GetPrincipal(c echo.Context) *Principal {
+	p, _ := c.Get(principalKey).(*Principal)
+	return p
+}
+
+// RequireRole
+// Rejects requests of principals with role not in roles
+func RequireRole(roles ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			p := GetPrincipal(c)
+			if p == nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, `request is not authenticated`)
+			}
+			for _, role := range roles {
+				if p.Role == role {
+					return next(c)
+				}
+			}
+			return echo.NewHTTPError(http.StatusForbidden, `role is not allowed: `+p.Role)
+		}
+	}
+}
+
+// Org returns organization of authenticated principal, can be used as SDK pool identify function
+func Org(c echo.Context) (string, error) {
+	p := GetPrincipal(c)
+	if p == nil {
+		return ``, errors.New(`request is not authenticated`)
+	}
+	return p.Org, nil
+}
@@ -0,0 +1,193 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/labstack/echo"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestAuth(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Auth Suite")
+}
+
+// serve runs request through middlewares and returns response status and principal seen by handler
+func serve(r *http.Request, middlewares ...echo.MiddlewareFunc) (int, *Principal) {
+	e := echo.New()
+	var principal *Principal
+	e.GET(`/`, func(c echo.Context) error {
+		principal = GetPrincipal(c)
+		return c.NoContent(http.StatusOK)
+	}, middlewares...)
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, r)
+	return rec.Code, principal
+}
+
+func bearer(token string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, `/`, nil)
+	r.Header.Set(`Authorization`, `Bearer `+token)
+	return r
+}
+
+func clientCert(org string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, `/`, nil)
+	subject := pkix.Name{CommonName: `user1`}
+	if org != `` {
+		subject.Organization = []string{org}
+	}
+	r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: subject}}}}
+	return r
+}
+
+var _ = Describe("Auth", func() {
+	roles := OrgRoles{`Org3MSP`: RoleMerchant, `Org4MSP`: RoleAgent}
+	jwtAuth := JWTAuthenticator{Secret: []byte(`production`), Roles: roles}
+	issuer := TestIssuer{Secret: []byte(`development`), TTL: time.Hour}
+	testAuth := JWTAuthenticator{Secret: issuer.Secret, Issuer: TestIssuerName, Roles: roles}
+
+	token := func(i TestIssuer, org string, role string) string {
+		resp, err := i.Issue(TokenRequest{Subject: `user1`, Org: org, Role: role})
+		Expect(err).NotTo(HaveOccurred())
+		return resp.Token
+	}
+
+	Describe("JWT", func() {
+		It("Bind token to role of organization", func() {
+			p, err := jwtAuth.Authenticate(bearer(token(TestIssuer{Secret: jwtAuth.Secret, TTL: time.Hour}, `Org4MSP`, RoleMerchant)))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(p.Org).To(Equal(`Org4MSP`))
+			Expect(p.Role).To(Equal(RoleAgent))
+			Expect(p.Method).To(Equal(`jwt`))
+
+			_, err = jwtAuth.Authenticate(bearer(token(TestIssuer{Secret: jwtAuth.Secret, TTL: time.Hour}, `Org9MSP`, ``)))
+			Expect(err).To(MatchError(`organization is not bound to role: Org9MSP`))
+
+			_, err = jwtAuth.Authenticate(httptest.NewRequest(http.MethodGet, `/`, nil))
+			Expect(err).To(Equal(ErrNoCredentials))
+		})
+
+		It("Accept test issuer tokens only with test issuer secret", func() {
+			testToken := token(issuer, `Org3MSP`, ``)
+
+			_, err := jwtAuth.Authenticate(bearer(testToken))
+			Expect(err).To(HaveOccurred())
+
+			p, err := testAuth.Authenticate(bearer(testToken))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(p.Role).To(Equal(RoleMerchant))
+
+			// token signed with test secret by other issuer
+			other, err := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{Org: `Org3MSP`,
+				StandardClaims: jwt.StandardClaims{Issuer: `other`, ExpiresAt: time.Now().Add(time.Hour).Unix()}}).SignedString(issuer.Secret)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = testAuth.Authenticate(bearer(other))
+			Expect(err).To(MatchError(`unexpected token issuer: other`))
+		})
+
+		It("Serve test tokens only to localhost", func() {
+			e := echo.New()
+			e.POST(`/auth/test/token`, issuer.Handler)
+
+			for addr, code := range map[string]int{`127.0.0.1:1234`: http.StatusOK, `10.0.0.1:1234`: http.StatusForbidden} {
+				r := httptest.NewRequest(http.MethodPost, `/auth/test/token`, strings.NewReader(`{"org":"Org3MSP"}`))
+				r.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+				r.RemoteAddr = addr
+				rec := httptest.NewRecorder()
+				e.ServeHTTP(rec, r)
+				Expect(rec.Code).To(Equal(code))
+			}
+		})
+	})
+
+	Describe("API keys", func() {
+		It("Authenticate known keys only", func() {
+			a := APIKeyAuthenticator{Keys: map[string]string{`key1`: `Org3MSP`}, Roles: roles}
+
+			r := httptest.NewRequest(http.MethodGet, `/`, nil)
+			_, err := a.Authenticate(r)
+			Expect(err).To(Equal(ErrNoCredentials))
+
+			r.Header.Set(APIKeyHeader, `key1`)
+			p, err := a.Authenticate(r)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(p.Org).To(Equal(`Org3MSP`))
+			Expect(p.Role).To(Equal(RoleMerchant))
+
+			r.Header.Set(APIKeyHeader, `key2`)
+			_, err = a.Authenticate(r)
+			Expect(err).To(MatchError(`invalid api key`))
+		})
+	})
+
+	Describe("mTLS", func() {
+		It("Take organization from verified certificate subject", func() {
+			a := MTLSAuthenticator{Roles: roles}
+
+			_, err := a.Authenticate(httptest.NewRequest(http.MethodGet, `/`, nil))
+			Expect(err).To(Equal(ErrNoCredentials))
+
+			p, err := a.Authenticate(clientCert(`Org4MSP`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(p.Subject).To(Equal(`user1`))
+			Expect(p.Role).To(Equal(RoleAgent))
+
+			_, err = a.Authenticate(clientCert(``))
+			Expect(err).To(MatchError(`client certificate has no organization`))
+		})
+	})
+
+	Describe("Middleware", func() {
+		It("Try authenticators of the same credentials in order", func() {
+			middleware := Middleware(jwtAuth, testAuth)
+
+			code, p := serve(bearer(token(issuer, `Org3MSP`, ``)), middleware)
+			Expect(code).To(Equal(http.StatusOK))
+			Expect(p.Org).To(Equal(`Org3MSP`))
+
+			code, _ = serve(bearer(`invalid`), middleware)
+			Expect(code).To(Equal(http.StatusUnauthorized))
+
+			code, _ = serve(httptest.NewRequest(http.MethodGet, `/`, nil), middleware)
+			Expect(code).To(Equal(http.StatusUnauthorized))
+		})
+
+		It("Reject principals without required role", func() {
+			middleware := Middleware(testAuth)
+
+			code, _ := serve(bearer(token(issuer, `Org3MSP`, ``)), middleware, RequireRole(RoleMerchant))
+			Expect(code).To(Equal(http.StatusOK))
+
+			code, _ = serve(bearer(token(issuer, `Org4MSP`, ``)), middleware, RequireRole(RoleMerchant, RoleBank))
+			Expect(code).To(Equal(http.StatusForbidden))
+
+			code, _ = serve(httptest.NewRequest(http.MethodGet, `/`, nil), RequireRole(RoleMerchant))
+			Expect(code).To(Equal(http.StatusUnauthorized))
+		})
+
+		It("Identify principal by own enrollment identity if it is bound", func() {
+			ids := Identities{`Org4MSP`: {`cashier1`: `Org4MSP-cashier1`}}
+			c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, `/`, nil), httptest.NewRecorder())
+
+			_, err := ids.Identify(c)
+			Expect(err).To(HaveOccurred())
+
+			c.Set(principalKey, &Principal{Subject: `cashier1`, Org: `Org4MSP`, Role: RoleAgent})
+			Expect(ids.Identify(c)).To(Equal(`Org4MSP-cashier1`))
+
+			c.Set(principalKey, &Principal{Subject: `cashier1`, Org: `Org3MSP`, Role: RoleMerchant})
+			Expect(ids.Identify(c)).To(Equal(`Org3MSP`))
+		})
+	})
+})
@@ -0,0 +1,25 @@
+package auth
+
+import (
+	"errors"
+
+	"github.com/labstack/echo"
+)
+
+// Identities maps organization MSP id and principal subject to SDK identity signing transactions of principal
+// Chaincode checks certificate attributes and four-eyes approvals per certificate, so principals
+// with own access level, point of sale or approval duty need own enrollment identity
+type Identities map[string]map[string]string
+
+// Identify returns SDK identity of authenticated principal, organization identity is used for principals without own one
+// Can be used as SDK pool identify function
+func (ids Identities) Identify(c echo.Context) (string, error) {
+	p := GetPrincipal(c)
+	if p == nil {
+		return ``, errors.New(`request is not authenticated`)
+	}
+	if identity, ok := ids[p.Org][p.Subject]; ok {
+		return identity, nil
+	}
+	return p.Org, nil
+}
@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/labstack/echo"
+)
+
+// TestIssuerName is iss claim of test issuer tokens
+const TestIssuerName = `tickets-test-issuer`
+
+// TestIssuer issues tokens accepted by JWTAuthenticator with the same secret and TestIssuerName issuer
+// It is intended for local development only, secret must differ from production one, Handler serves loopback requests only
+type TestIssuer struct {
+	Secret []byte
+	TTL    time.Duration
+}
+
+type TokenRequest struct {
+	Subject string `json:"subject"`
+	Org     string `json:"org"`
+	Role    string `json:"role"`
+}
+
+type TokenResponse struct {
+	Token     string `json:"token"`
+	ExpiresAt int64  `json:"expiresAt"`
+}
+
+func (i TestIssuer) Issue(req TokenRequest) (TokenResponse, error) {
+	expiresAt := time.Now().Add(i.TTL).Unix()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		Org:  req.Org,
+		Role: req.Role,
+		StandardClaims: jwt.StandardClaims{
+			Subject:   req.Subject,
+			Issuer:    TestIssuerName,
+			ExpiresAt: expiresAt,
+		},
+	})
+
+	signed, err := token.SignedString(i.Secret)
+	return TokenResponse{Token: signed, ExpiresAt: expiresAt}, err
+}
+
+// Handler
+// Issues token for organization and role from request body
+func (i TestIssuer) Handler(c echo.Context) error {
+	host, _, err := net.SplitHostPort(c.Request().RemoteAddr)
+	if ip := net.ParseIP(host); err != nil || ip == nil || !ip.IsLoopback() {
+		return echo.NewHTTPError(http.StatusForbidden, `test issuer is available only from localhost`)
+	}
+
+	var req TokenRequest
+	if err = c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if req.Org == `` {
+		return echo.NewHTTPError(http.StatusBadRequest, `org is empty`)
+	}
+
+	resp, err := i.Issue(req)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// Claims of tickets API token, role claim is used only for organizations not bound in OrgRoles
+type Claims struct {
+	Org  string `json:"org"`
+	Role string `json:"role"`
+	jwt.StandardClaims
+}
+
+// JWTAuthenticator authenticates requests by HS256 bearer token
+type JWTAuthenticator struct {
+	Secret []byte
+	// Issuer is required iss claim if set
+	Issuer string
+	Roles  OrgRoles
+}
+
+func (a JWTAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	header := r.Header.Get(`Authorization`)
+	if !strings.HasPrefix(header, `Bearer `) {
+		return nil, ErrNoCredentials
+	}
+
+	var claims Claims
+	_, err := jwt.ParseWithClaims(strings.TrimPrefix(header, `Bearer `), &claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodHS256 {
+			return nil, fmt.Errorf("unexpected signing method: %s", token.Header[`alg`])
+		}
+		return a.Secret, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid token: %s", err)
+	}
+
+	if a.Issuer != `` && claims.Issuer != a.Issuer {
+		return nil, fmt.Errorf("unexpected token issuer: %s", claims.Issuer)
+	}
+
+	if claims.Org == `` {
+		return nil, errors.New(`token has no org claim`)
+	}
+
+	if _, ok := a.Roles[claims.Org]; ok {
+		return a.Roles.principal(claims.Subject, claims.Org, `jwt`)
+	}
+	if claims.Role == `` {
+		return nil, errors.New(`organization is not bound to role: ` + claims.Org)
+	}
+	return &Principal{Subject: claims.Subject, Org: claims.Org, Role: claims.Role, Method: `jwt`}, nil
+}
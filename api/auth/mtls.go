@@ -0,0 +1,24 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+)
+
+// MTLSAuthenticator authenticates requests by verified TLS client certificate
+// Organization is taken from certificate subject O, e.g. O=Org3MSP
+type MTLSAuthenticator struct {
+	Roles OrgRoles
+}
+
+func (a MTLSAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return nil, ErrNoCredentials
+	}
+
+	cert := r.TLS.VerifiedChains[0][0]
+	if len(cert.Subject.Organization) == 0 {
+		return nil, errors.New(`client certificate has no organization`)
+	}
+	return a.Roles.principal(cert.Subject.CommonName, cert.Subject.Organization[0], `mtls`)
+}
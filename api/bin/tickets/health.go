@@ -0,0 +1,23 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo"
+)
+
+// healthHandler reports that API process is alive
+func healthHandler(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{`status`: `ok`})
+}
+
+// readinessHandler reports that API can query tickets chaincode, ready doesn't depend on caller
+// so probes don't need credentials
+func readinessHandler(ready func() error) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if err := ready(); err != nil {
+			return c.JSON(http.StatusServiceUnavailable, map[string]string{`status`: `unavailable`, `error`: err.Error()})
+		}
+		return c.JSON(http.StatusOK, map[string]string{`status`: `ready`})
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/labstack/echo/middleware"
+	"github.com/labstack/gommon/log"
+	"s7ab-platform-hyperledger/platform/core/logger"
+	"s7ab-platform-hyperledger/platform/s7ticket/api/auth"
+	"s7ab-platform-hyperledger/platform/s7ticket/api/common"
+	"s7ab-platform-hyperledger/platform/s7ticket/api/config"
+	"s7ab-platform-hyperledger/platform/s7ticket/api/operations"
+	"s7ab-platform-hyperledger/platform/s7ticket/api/simulator"
+	"s7ab-platform-hyperledger/platform/s7ticket/api/tickets"
+	"s7ab-platform-hyperledger/platform/s7ticket/api/tickets/handlers"
+)
+
+var logLevels = map[string]log.Lvl{
+	`debug`: log.DEBUG,
+	`info`:  log.INFO,
+	`warn`:  log.WARN,
+	`error`: log.ERROR,
+	`off`:   log.OFF,
+}
+
+func main() {
+	configPath := flag.String(`config`, os.Getenv(config.EnvPrefix+`CONFIG`), `path to YAML config file`)
+	simulatorMode := flag.Bool(`simulator`, false, `serve API with in-memory ledger instead of fabric network`)
+	flag.Parse()
+
+	l := logger.NewZapLogger(nil)
+
+	c, err := config.Load(*configPath)
+	if err == nil {
+		c.Simulator = c.Simulator || *simulatorMode
+		err = c.Validate()
+	}
+	if err != nil {
+		l.Warn(`config`, logger.KV(`error`, err))
+		os.Exit(1)
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+
+	authenticators, err := newAuthenticators(c)
+	if err != nil {
+		l.Warn(`auth`, logger.KV(`error`, err))
+		os.Exit(1)
+	}
+
+	sdkMiddleware, simulatorAuth, ready, err := newSDKMiddleware(ctx, c, l)
+	if err != nil {
+		l.Warn(`sdk`, logger.KV(`error`, err))
+		os.Exit(1)
+	}
+	if simulatorAuth != nil {
+		authenticators = append(authenticators, simulatorAuth)
+	}
+	authMiddleware := auth.Middleware(authenticators...)
+
+	e := echo.New()
+	e.HideBanner = true
+	if level, ok := logLevels[c.Log.Level]; ok {
+		e.Logger.SetLevel(level)
+	}
+	if c.Log.Requests {
+		e.Use(middleware.Logger())
+	}
+	e.Use(middleware.Recover())
+
+	e.GET(`/health`, healthHandler)
+	e.GET(`/ready`, readinessHandler(ready))
+	if c.Auth.TestIssuer.Enabled {
+		issuer := auth.TestIssuer{Secret: []byte(c.Auth.TestIssuer.Secret), TTL: c.Auth.TestIssuer.TTL}
+		e.POST(`/auth/test/token`, issuer.Handler)
+	}
+	operationStore := operations.NewMemoryStore(c.Operations.TTL)
+	go operationStore.Run(ctx, time.Minute)
+	handlers.Operations = operations.NewRunner(operationStore, l)
+	handlers.Operations.Secret = []byte(c.Operations.CallbackSecret)
+	handlers.Operations.Hosts = c.Operations.CallbackHosts
+	if c.RateLimit.Rate > 0 {
+		limiter := common.NewRateLimiter(c.RateLimit.Rate, c.RateLimit.Burst)
+		tickets.PaymentRateLimit = common.RateLimitMiddleware(limiter, auth.Org, l)
+	}
+	tickets.NewModule(e, c.UrlPath, authMiddleware, sdkMiddleware)
+
+	server, err := newServer(c)
+	if err != nil {
+		l.Warn(`tls`, logger.KV(`error`, err))
+		os.Exit(1)
+	}
+
+	go func() {
+		if err := e.StartServer(server); err != nil && err != http.ErrServerClosed {
+			l.Warn(`api`, logger.KV(`error`, err))
+			os.Exit(1)
+		}
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), c.ShutdownTimeout)
+	defer cancel()
+	if err = e.Shutdown(shutdownCtx); err != nil {
+		l.Warn(`api shutdown`, logger.KV(`error`, err))
+	}
+}
+
+// newSDKMiddleware returns middleware attaching SDK to request context:
+// simulator identity in simulator mode or pooled SDK of authenticated principal organization
+// In simulator mode authenticator of simulator identities is returned too
+// Returned readiness check doesn't depend on caller, it queries chaincode with SDK of configured organization
+func newSDKMiddleware(ctx context.Context, c config.Config, l logger.Logger) (echo.MiddlewareFunc, auth.Authenticator, func() error, error) {
+	if c.Simulator {
+		network, err := simulator.New(l)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		l.Info(`simulator`, logger.KV(`identities`, network.Identities()), logger.KV(`header`, simulator.IdentityHeader))
+		handlers.BreachRecorder = func() (*common.PaymentSDK, func(), error) {
+			b, err := network.Backend(network.Merchant())
+			if err != nil {
+				return nil, nil, err
+			}
+			return common.NewPaymentSDK(b), func() {}, nil
+		}
+		// in-memory ledger is ready once seeded
+		return common.Middleware(network.Resolve, l), network, func() error { return nil }, nil
+	}
+
+	pool := common.NewPool(common.PoolOptions{
+		IdleTimeout: c.Pool.IdleTimeout,
+		MaxInFlight: c.Pool.MaxInFlight,
+		Init: func(identity string, channel string, l logger.Logger) (*common.PaymentSDK, error) {
+			s, err := common.InitSDK(identity, channel, l)
+			if err == nil {
+				s.Chaincode = c.Chaincode
+			}
+			return s, err
+		},
+		Check: func(s *common.PaymentSDK) error {
+			_, err := s.GetMerchant()
+			return err
+		},
+		Close: (*common.PaymentSDK).Close,
+	}, l)
+
+	// default organization client is created on start to fail fast on wrong SDK settings
+	_, release, err := pool.Get(c.Org, c.Channel)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	release()
+	go pool.Run(ctx, c.Pool.CheckInterval)
+
+	// breaches of agent limits are recorded with SDK of API organization, it must be merchant
+	handlers.BreachRecorder = func() (*common.PaymentSDK, func(), error) {
+		return pool.Get(c.Org, c.Channel)
+	}
+
+	ready := func() error {
+		s, release, err := pool.Get(c.Org, c.Channel)
+		if err != nil {
+			return err
+		}
+		defer release()
+		_, err = s.GetMerchant()
+		return err
+	}
+	return common.PoolMiddleware(pool, c.Channel, auth.Identities(c.Auth.Identities).Identify, l), nil, ready, nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net/http"
+
+	"s7ab-platform-hyperledger/platform/s7ticket/api/auth"
+	"s7ab-platform-hyperledger/platform/s7ticket/api/config"
+)
+
+// newAuthenticators returns authenticators enabled in config
+func newAuthenticators(c config.Config) (authenticators []auth.Authenticator, err error) {
+	roles := auth.OrgRoles(c.Auth.OrgRoles)
+
+	if c.Auth.MTLS.Enabled {
+		authenticators = append(authenticators, auth.MTLSAuthenticator{Roles: roles})
+	}
+	if c.Auth.JWT.Secret != `` {
+		authenticators = append(authenticators, auth.JWTAuthenticator{Secret: []byte(c.Auth.JWT.Secret), Roles: roles})
+	}
+	if len(c.Auth.APIKeys) > 0 {
+		authenticators = append(authenticators, auth.APIKeyAuthenticator{Keys: c.Auth.APIKeys, Roles: roles})
+	}
+	if c.Auth.TestIssuer.Enabled {
+		authenticators = append(authenticators, auth.JWTAuthenticator{
+			Secret: []byte(c.Auth.TestIssuer.Secret), Issuer: auth.TestIssuerName, Roles: roles})
+	}
+	return
+}
+
+// newServer returns http server, client certificates are verified with client CA when mtls is enabled
+func newServer(c config.Config) (*http.Server, error) {
+	s := &http.Server{Addr: c.Listen}
+	if !c.TLS.Enabled {
+		return s, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.TLS.CertFile, c.TLS.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	s.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+
+	if c.Auth.MTLS.Enabled {
+		caBytes, err := ioutil.ReadFile(c.Auth.MTLS.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caBytes) {
+			return nil, errors.New(`no certificates in client CA file`)
+		}
+		s.TLSConfig.ClientCAs = pool
+		// other auth methods are allowed, so client certificate is optional
+		s.TLSConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return s, nil
+}
@@ -0,0 +1,38 @@
+package common
+
+import (
+	"s7ab-platform-hyperledger/platform/s7platform/sdk"
+)
+
+// Backend executes chaincode functions for PaymentSDK
+type Backend interface {
+	Query(chaincode string, fn string, args []string) ([]byte, error)
+	Invoke(chaincode string, fn string, args []string) ([]byte, error)
+}
+
+// fabricBackend sends requests to fabric network through platform SDK
+type fabricBackend struct {
+	s *sdk.SDKControlStructure
+}
+
+func (b fabricBackend) Query(chaincode string, fn string, args []string) ([]byte, error) {
+	return b.s.SDKCore.Query(chaincode, fn, args)
+}
+
+func (b fabricBackend) Invoke(chaincode string, fn string, args []string) ([]byte, error) {
+	return b.s.SDKCore.Invoke(chaincode, fn, args)
+}
+
+// Stage is step of transaction processing
+// Backend invoke doesn't report endorsement and ordering, transaction is INVOKING from send until commit or failure
+type Stage string
+
+const (
+	StageSubmitted Stage = `SUBMITTED`
+	StageInvoking  Stage = `INVOKING`
+	StageCommitted Stage = `COMMITTED`
+	StageFailed    Stage = `FAILED`
+)
+
+// ProgressFunc is called when transaction reaches next stage
+type ProgressFunc func(stage Stage)
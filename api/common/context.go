@@ -2,6 +2,7 @@ package common
 
 import (
 	"github.com/labstack/echo"
+	"net/http"
 	"s7ab-platform-hyperledger/platform/core/api/common"
 	"s7ab-platform-hyperledger/platform/core/logger"
 )
@@ -13,19 +14,72 @@ const (
 type Context struct {
 	common.Context
 	SDK *PaymentSDK
+	// Pool is set when SDK is taken from pool, InitSDK reuses pooled clients in this case
+	Pool *Pool
+	// Identity is pool key of SDK: caller organization or own enrollment identity of caller
+	Identity string
+	// releases of pooled clients taken by InitSDK, called when request is done
+	releases *[]func()
 }
 
 // NewContext
 // Get new context instance
 func NewContext(e echo.Context, s *PaymentSDK, l logger.Logger) Context {
 	c := Context{}
-	c.Context = common.NewContext(e, &s.SDKCore, l)
+	if s.SDKControlStructure != nil {
+		c.Context = common.NewContext(e, &s.SDKCore, l)
+	} else {
+		// SDK works without fabric network, e.g. in simulator mode
+		c.Context = common.NewContext(e, nil, l)
+	}
 	c.SDK = s
 	return c
 }
 
+// Middleware
+// Wraps echo context with Context holding SDK returned by resolve
+func Middleware(resolve func(e echo.Context) (*PaymentSDK, error), l logger.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(e echo.Context) error {
+			s, err := resolve(e)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusServiceUnavailable, err.Error())
+			}
+			return next(NewContext(e, s, l))
+		}
+	}
+}
+
 // InitSDK
-// Get SDK instance of presented Organization
+// Get SDK instance of presented Organization on channel and chaincode of current SDK
 func (c *Context) InitSDK(org string) (*PaymentSDK, error) {
-	return InitSDK(org, defaultChannel, c.Log)
+	channel := defaultChannel
+	if c.SDK != nil && c.SDK.Channel != `` {
+		channel = c.SDK.Channel
+	}
+
+	if c.Pool != nil {
+		s, release, err := c.Pool.Get(org, channel)
+		if err != nil {
+			return nil, err
+		}
+		*c.releases = append(*c.releases, release)
+		return s, nil
+	}
+
+	s, err := InitSDK(org, channel, c.Log)
+	if err != nil {
+		return nil, err
+	}
+	if c.SDK != nil {
+		s.Chaincode = c.SDK.Chaincode
+	}
+	return s, nil
+}
+
+// release returns pooled clients taken by InitSDK during request
+func (c Context) release() {
+	for _, release := range *c.releases {
+		release()
+	}
 }
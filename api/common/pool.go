@@ -0,0 +1,239 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo"
+	"s7ab-platform-hyperledger/platform/core/logger"
+)
+
+const (
+	defaultPoolIdleTimeout = 10 * time.Minute
+	defaultPoolMaxInFlight = 100
+)
+
+// ErrTooManyRequests is returned when organization reached limit of in-flight requests
+var ErrTooManyRequests = errors.New(`too many in-flight requests for organization`)
+
+type PoolOptions struct {
+	// IdleTimeout is duration after last use when client is evicted
+	IdleTimeout time.Duration
+	// MaxInFlight is max count of simultaneous requests per organization and channel
+	MaxInFlight int
+	// Init creates SDK of organization or enrollment identity, InitSDK is used by default
+	Init func(org string, channel string, l logger.Logger) (*PaymentSDK, error)
+	// Check returns error if SDK is not healthy, unhealthy client is evicted
+	Check func(s *PaymentSDK) error
+	// Close releases SDK resources on eviction
+	Close func(s *PaymentSDK)
+}
+
+type poolKey struct {
+	org     string
+	channel string
+}
+
+type pooledSDK struct {
+	mu       sync.Mutex
+	sdk      *PaymentSDK
+	lastUsed time.Time
+	inFlight chan struct{}
+	// users counts holders of every client of entry, evicted client is closed when last holder releases it
+	users map[*PaymentSDK]int
+}
+
+// Pool is concurrency safe cache of SDK clients keyed by organization and channel
+// Clients are created lazily on first request
+type Pool struct {
+	mu      sync.Mutex
+	clients map[poolKey]*pooledSDK
+	opts    PoolOptions
+	log     logger.Logger
+}
+
+func NewPool(opts PoolOptions, l logger.Logger) *Pool {
+	if opts.IdleTimeout == 0 {
+		opts.IdleTimeout = defaultPoolIdleTimeout
+	}
+	if opts.MaxInFlight == 0 {
+		opts.MaxInFlight = defaultPoolMaxInFlight
+	}
+	if opts.Init == nil {
+		opts.Init = InitSDK
+	}
+	return &Pool{clients: make(map[poolKey]*pooledSDK), opts: opts, log: l}
+}
+
+func (p *Pool) entry(org string, channel string) *pooledSDK {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	k := poolKey{org: org, channel: channel}
+	e, ok := p.clients[k]
+	if !ok {
+		e = &pooledSDK{inFlight: make(chan struct{}, p.opts.MaxInFlight), users: make(map[*PaymentSDK]int)}
+		p.clients[k] = e
+	}
+	return e
+}
+
+// client returns initialized SDK of entry and function to release it,
+// initialization of one entry doesn't block others
+func (p *Pool) client(e *pooledSDK, org string, channel string) (*PaymentSDK, func(), error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.sdk == nil {
+		s, err := p.opts.Init(org, channel, p.log)
+		if err != nil {
+			return nil, nil, err
+		}
+		e.sdk = s
+	}
+	e.lastUsed = time.Now()
+
+	s := e.sdk
+	e.users[s]++
+	var once sync.Once
+	return s, func() { once.Do(func() { p.release(e, s) }) }, nil
+}
+
+// release drops holder of client, client evicted while held is closed by its last holder
+func (p *Pool) release(e *pooledSDK, s *PaymentSDK) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.users[s]--
+	if e.users[s] > 0 {
+		return
+	}
+	delete(e.users, s)
+	if e.sdk != s {
+		p.close(s)
+	} else {
+		e.lastUsed = time.Now()
+	}
+}
+
+// Get returns SDK of organization on channel without in-flight limit and function to release it
+// Client isn't closed by eviction until it is released
+func (p *Pool) Get(org string, channel string) (*PaymentSDK, func(), error) {
+	return p.client(p.entry(org, channel), org, channel)
+}
+
+// Acquire returns SDK of organization on channel and function to release in-flight slot
+// ErrTooManyRequests is returned if organization reached MaxInFlight
+func (p *Pool) Acquire(org string, channel string) (*PaymentSDK, func(), error) {
+	e := p.entry(org, channel)
+
+	select {
+	case e.inFlight <- struct{}{}:
+	default:
+		return nil, nil, ErrTooManyRequests
+	}
+	s, release, err := p.client(e, org, channel)
+	if err != nil {
+		<-e.inFlight
+		return nil, nil, err
+	}
+	return s, func() {
+		release()
+		<-e.inFlight
+	}, nil
+}
+
+// Maintain evicts idle and unhealthy clients
+func (p *Pool) Maintain() {
+	p.mu.Lock()
+	entries := make(map[poolKey]*pooledSDK, len(p.clients))
+	for k, e := range p.clients {
+		entries[k] = e
+	}
+	p.mu.Unlock()
+
+	for k, e := range entries {
+		e.mu.Lock()
+		s := e.sdk
+		idle := s != nil && e.users[s] == 0 && time.Since(e.lastUsed) > p.opts.IdleTimeout
+		if idle {
+			p.evict(e, s)
+		}
+		e.mu.Unlock()
+
+		if s == nil || idle || p.opts.Check == nil {
+			continue
+		}
+
+		// health check goes to network, entry isn't locked so requests of organization aren't blocked by it
+		if err := p.opts.Check(s); err != nil {
+			p.log.Warn(`sdk pool health check`, logger.KV(`org`, k.org), logger.KV(`channel`, k.channel), logger.KV(`error`, err))
+			e.mu.Lock()
+			// client could be replaced while checked
+			if e.sdk == s {
+				p.evict(e, s)
+			}
+			e.mu.Unlock()
+		}
+	}
+}
+
+// evict removes client from entry, entry must be locked
+// Requests in flight keep their SDK and it is closed when the last of them releases it, next request creates new one
+func (p *Pool) evict(e *pooledSDK, s *PaymentSDK) {
+	e.sdk = nil
+	if e.users[s] == 0 {
+		p.close(s)
+	}
+}
+
+func (p *Pool) close(s *PaymentSDK) {
+	if p.opts.Close != nil {
+		p.opts.Close(s)
+	}
+}
+
+// Run calls Maintain with interval until ctx is done
+func (p *Pool) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.Maintain()
+		}
+	}
+}
+
+// PoolMiddleware
+// Attaches SDK of caller identity from pool to Context, identify returns caller organization or own enrollment identity
+func PoolMiddleware(p *Pool, channel string, identify func(e echo.Context) (string, error), l logger.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(e echo.Context) error {
+			identity, err := identify(e)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+			}
+
+			s, release, err := p.Acquire(identity, channel)
+			if err == ErrTooManyRequests {
+				return echo.NewHTTPError(http.StatusTooManyRequests, err.Error())
+			} else if err != nil {
+				return echo.NewHTTPError(http.StatusServiceUnavailable, err.Error())
+			}
+			defer release()
+
+			c := NewContext(e, s, l)
+			c.Pool = p
+			c.Identity = identity
+			c.releases = new([]func())
+			defer c.release()
+			return next(c)
+		}
+	}
+}
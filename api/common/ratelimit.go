@@ -0,0 +1,80 @@
+package common
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo"
+	"s7ab-platform-hyperledger/platform/core/logger"
+)
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// RateLimiter is token bucket per organization, bucket holds Burst tokens and is refilled with Rate tokens per second
+type RateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	rate    float64
+	burst   float64
+	now     func() time.Time
+}
+
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{buckets: make(map[string]*bucket), rate: rate, burst: float64(burst), now: time.Now}
+}
+
+// Allow takes token from bucket of organization, false is returned if bucket is empty
+func (l *RateLimiter) Allow(org string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	b, ok := l.buckets[org]
+	if !ok {
+		b = &bucket{tokens: l.burst, updated: now}
+		l.buckets[org] = b
+	}
+
+	b.tokens += now.Sub(b.updated).Seconds() * l.rate
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.updated = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// RateLimitMiddleware
+// Rejects requests of organization with empty bucket, identify returns caller organization
+// Limiter can be nil, requests are not limited in this case
+func RateLimitMiddleware(limiter *RateLimiter, identify func(e echo.Context) (string, error), l logger.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(e echo.Context) error {
+			if limiter == nil {
+				return next(e)
+			}
+
+			org, err := identify(e)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+			}
+
+			if !limiter.Allow(org) {
+				l.Warn(`rate limit breach`, logger.KV(`org`, org), logger.KV(`path`, e.Path()))
+				return echo.NewHTTPError(http.StatusTooManyRequests, `rate limit exceeded for organization: `+org)
+			}
+			return next(e)
+		}
+	}
+}
@@ -5,19 +5,36 @@ import (
 	coreEntities "s7ab-platform-hyperledger/platform/core/entities"
 	"s7ab-platform-hyperledger/platform/core/logger"
 	"s7ab-platform-hyperledger/platform/s7platform/sdk"
+	apiEntities "s7ab-platform-hyperledger/platform/s7ticket/api/tickets/entities"
 	"s7ab-platform-hyperledger/platform/s7ticket/entities"
+	"strconv"
+	"time"
 )
 
 const (
-	chaincode = `tickets`
+	defaultChaincode = `tickets`
 )
 
+// PaymentSDK is tickets chaincode client
+// SDKControlStructure is nil when SDK works with backend other than fabric network
 type PaymentSDK struct {
 	*sdk.SDKControlStructure
+	Backend Backend
+
+	Channel   string
+	Chaincode string
+}
+
+// chaincode returns name of tickets chaincode, default name is used if it isn't set
+func (ts *PaymentSDK) chaincode() string {
+	if ts.Chaincode == `` {
+		return defaultChaincode
+	}
+	return ts.Chaincode
 }
 
 func (ts *PaymentSDK) PaymentByNumber(key string) (*entities.Payment, error) {
-	paymentString, err := ts.SDKCore.Query(chaincode, `GetByKey`, []string{key})
+	paymentString, err := ts.Backend.Query(ts.chaincode(), `/get`, []string{key})
 	if err != nil {
 		return nil, err
 	}
@@ -30,7 +47,7 @@ func (ts *PaymentSDK) PaymentByNumber(key string) (*entities.Payment, error) {
 }
 
 func (ts *PaymentSDK) PaymentsList(limit int, offset int) ([]entities.Payment, error) {
-	paymentBytes, err := ts.SDKCore.Query(chaincode, `List`, []string{string(limit), string(offset)})
+	paymentBytes, err := ts.Backend.Query(ts.chaincode(), `/list`, []string{strconv.Itoa(limit), strconv.Itoa(offset)})
 	if err != nil {
 		return nil, err
 	}
@@ -44,7 +61,7 @@ func (ts *PaymentSDK) PaymentsList(limit int, offset int) ([]entities.Payment, e
 }
 
 func (ts *PaymentSDK) AgentsList() ([]coreEntities.Member, error) {
-	agentsBytes, err := ts.SDKCore.Query(chaincode, `/agent/list`, []string{})
+	agentsBytes, err := ts.Backend.Query(ts.chaincode(), `/agent/list`, []string{})
 	if err != nil {
 		return nil, err
 	}
@@ -58,7 +75,7 @@ func (ts *PaymentSDK) AgentsList() ([]coreEntities.Member, error) {
 }
 
 func (ts *PaymentSDK) Agent() (agent coreEntities.Member, err error) {
-	agentBytes, err := ts.SDKCore.Query(chaincode, `/agent`, []string{})
+	agentBytes, err := ts.Backend.Query(ts.chaincode(), `/agent`, []string{})
 	if err != nil {
 		return
 	}
@@ -67,28 +84,548 @@ func (ts *PaymentSDK) Agent() (agent coreEntities.Member, err error) {
 	return
 }
 
+// PaymentHistory returns payment versions as ledger key modifications with parsed payment
 func (ts *PaymentSDK) PaymentHistory(key string) ([]coreEntities.KeyModification, error) {
-	historyBytes, err := ts.SDKCore.Query(chaincode, `/history`, []string{key})
+	timeline, err := ts.PaymentTimeline(key)
 	if err != nil {
 		return nil, err
 	}
-	var mods []coreEntities.KeyModification
-	if err = json.Unmarshal(historyBytes, &mods); err != nil {
-		return nil, err
-	}
-	var p entities.Payment
-	for i := 0; i < len(mods); i++ {
-		if err = json.Unmarshal(mods[i].Payload, &p); err != nil {
-			return nil, err
-		} else {
-			mods[i].PayloadParsed = p
+
+	mods := make([]coreEntities.KeyModification, 0, len(timeline))
+	for _, entry := range timeline {
+		mod := coreEntities.KeyModification{TxID: entry.TxId, IsDelete: entry.IsDelete}
+		if at, err := time.Parse(time.RFC3339Nano, entry.Timestamp); err == nil {
+			mod.Time = at.Unix()
+		}
+		if entry.State != nil {
+			if mod.Payload, err = json.Marshal(entry.State); err != nil {
+				return nil, err
+			}
+			mod.PayloadParsed = *entry.State
 		}
+		mods = append(mods, mod)
 	}
 	return mods, nil
 }
 
+// PaymentTimeline returns payment timeline with changed fields on every step
+func (ts *PaymentSDK) PaymentTimeline(key string) ([]entities.PaymentHistoryEntry, error) {
+	historyBytes, err := ts.Backend.Query(ts.chaincode(), `/history`, []string{key})
+	if err != nil {
+		return nil, err
+	}
+	var history []entities.PaymentHistoryEntry
+	if err = json.Unmarshal(historyBytes, &history); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// SettlementCreate opens settlement cycle for currency, allowed only for merchant
+func (ts *PaymentSDK) SettlementCreate(currency string) (*entities.SettlementBatch, error) {
+	batchBytes, err := ts.Backend.Invoke(ts.chaincode(), `/settlement/create`, []string{currency})
+	if err != nil {
+		return nil, err
+	}
+	var batch entities.SettlementBatch
+	if err = json.Unmarshal(batchBytes, &batch); err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// SettlementConfirm confirms settlement batch by current bank
+func (ts *PaymentSDK) SettlementConfirm(batchId string) error {
+	_, err := ts.Backend.Invoke(ts.chaincode(), `/settlement/confirm`, []string{batchId})
+	return err
+}
+
+func (ts *PaymentSDK) SettlementBatch(batchId string) (*entities.SettlementBatch, error) {
+	batchBytes, err := ts.Backend.Query(ts.chaincode(), `/settlement/get`, []string{batchId})
+	if err != nil {
+		return nil, err
+	}
+	var batch entities.SettlementBatch
+	if err = json.Unmarshal(batchBytes, &batch); err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+func (ts *PaymentSDK) SettlementBatches() ([]entities.SettlementBatch, error) {
+	batchesBytes, err := ts.Backend.Query(ts.chaincode(), `/settlement/list`, []string{})
+	if err != nil {
+		return nil, err
+	}
+	var batches []entities.SettlementBatch
+	if err = json.Unmarshal(batchesBytes, &batches); err != nil {
+		return nil, err
+	}
+	return batches, nil
+}
+
+func (ts *PaymentSDK) FeeSchedule() (schedule entities.FeeSchedule, err error) {
+	scheduleBytes, err := ts.Backend.Query(ts.chaincode(), `/fees/get`, []string{})
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(scheduleBytes, &schedule)
+	return
+}
+
+// SetFeeSchedule replaces fee schedule, allowed only for merchant
+func (ts *PaymentSDK) SetFeeSchedule(schedule entities.FeeSchedule) error {
+	scheduleBytes, err := json.Marshal(schedule)
+	if err != nil {
+		return err
+	}
+	_, err = ts.Backend.Invoke(ts.chaincode(), `/fees/set`, []string{string(scheduleBytes)})
+	return err
+}
+
+// Migrate runs next chunk of payments migration, allowed only for merchant
+func (ts *PaymentSDK) Migrate(chunk int) (log entities.MigrationLog, err error) {
+	logBytes, err := ts.Backend.Invoke(ts.chaincode(), `/migrate`, []string{strconv.Itoa(chunk)})
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(logBytes, &log)
+	return
+}
+
+func (ts *PaymentSDK) MigrationStatus() (log entities.MigrationLog, err error) {
+	logBytes, err := ts.Backend.Query(ts.chaincode(), `/migrate/status`, []string{})
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(logBytes, &log)
+	return
+}
+
+func (ts *PaymentSDK) Config() (config entities.Config, err error) {
+	configBytes, err := ts.Backend.Query(ts.chaincode(), `/config/get`, []string{})
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(configBytes, &config)
+	return
+}
+
+// UpdateConfig replaces chaincode config, allowed only for config owner
+func (ts *PaymentSDK) UpdateConfig(config entities.Config) error {
+	configBytes, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	_, err = ts.Backend.Invoke(ts.chaincode(), `/config/update`, []string{string(configBytes)})
+	return err
+}
+
+func (ts *PaymentSDK) PaymentLimits() (limits entities.PaymentLimits, err error) {
+	limitsBytes, err := ts.Backend.Query(ts.chaincode(), `/limits/get`, []string{})
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(limitsBytes, &limits)
+	return
+}
+
+// SetPaymentLimits replaces agents payment limits, allowed only for merchant
+func (ts *PaymentSDK) SetPaymentLimits(limits entities.PaymentLimits) error {
+	limitsBytes, err := json.Marshal(limits)
+	if err != nil {
+		return err
+	}
+	_, err = ts.Backend.Invoke(ts.chaincode(), `/limits/set`, []string{string(limitsBytes)})
+	return err
+}
+
+func (ts *PaymentSDK) RiskRules() (rules entities.RiskRules, err error) {
+	rulesBytes, err := ts.Backend.Query(ts.chaincode(), `/risk/get`, []string{})
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(rulesBytes, &rules)
+	return
+}
+
+// SetRiskRules replaces risk rules, allowed only for merchant
+func (ts *PaymentSDK) SetRiskRules(rules entities.RiskRules) error {
+	rulesBytes, err := json.Marshal(rules)
+	if err != nil {
+		return err
+	}
+	_, err = ts.Backend.Invoke(ts.chaincode(), `/risk/set`, []string{string(rulesBytes)})
+	return err
+}
+
+// Blocklist returns blocklist entries of type, all entries if entryType is empty
+func (ts *PaymentSDK) Blocklist(entryType string) ([]entities.BlocklistEntry, error) {
+	entriesBytes, err := ts.Backend.Query(ts.chaincode(), `/blocklist/list`, []string{entryType})
+	if err != nil {
+		return nil, err
+	}
+	var entries []entities.BlocklistEntry
+	if err = json.Unmarshal(entriesBytes, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// BlocklistAdd adds or replaces blocklist entries in one transaction, allowed for merchant and bank
+func (ts *PaymentSDK) BlocklistAdd(entries []entities.BlocklistEntry) ([]entities.BlocklistEntry, error) {
+	entriesBytes, err := json.Marshal(entries)
+	if err != nil {
+		return nil, err
+	}
+	addedBytes, err := ts.Backend.Invoke(ts.chaincode(), `/blocklist/add`, []string{string(entriesBytes)})
+	if err != nil {
+		return nil, err
+	}
+	var added []entities.BlocklistEntry
+	if err = json.Unmarshal(addedBytes, &added); err != nil {
+		return nil, err
+	}
+	return added, nil
+}
+
+// BlocklistRemove removes blocklist entry, allowed for merchant and bank
+func (ts *PaymentSDK) BlocklistRemove(entryType string, value string) error {
+	_, err := ts.Backend.Invoke(ts.chaincode(), `/blocklist/remove`, []string{entryType, value})
+	return err
+}
+
+// HoldConfirm reserves payment funds until expiry, allowed only for payer bank
+func (ts *PaymentSDK) HoldConfirm(payload entities.HoldConfirmPayload) error {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = ts.Backend.Invoke(ts.chaincode(), `/hold/confirm`, []string{string(payloadBytes)})
+	return err
+}
+
+// HoldCapture debits held funds, allowed only for payer agent
+// Approval is returned when capture above approval threshold waits for second signer
+func (ts *PaymentSDK) HoldCapture(payload entities.HoldCapturePayload) (*entities.PaymentApprovalEvent, error) {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	result, err := ts.Backend.Invoke(ts.chaincode(), `/hold/capture`, []string{string(payloadBytes)})
+	if err != nil {
+		return nil, err
+	}
+	return pendingApproval(result)
+}
+
+// HoldRelease returns held funds to payer
+func (ts *PaymentSDK) HoldRelease(paymentId string) error {
+	_, err := ts.Backend.Invoke(ts.chaincode(), `/hold/release`, []string{paymentId})
+	return err
+}
+
+// Issue records ticket number of debited payment, allowed only for payer agent
+func (ts *PaymentSDK) Issue(payload entities.IssuePayload) error {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = ts.Backend.Invoke(ts.chaincode(), `/issue`, []string{string(payloadBytes)})
+	return err
+}
+
+// Cancel cancels payment before debit, allowed only for payer agent
+func (ts *PaymentSDK) Cancel(payload entities.CancelPayload) error {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = ts.Backend.Invoke(ts.chaincode(), `/cancel`, []string{string(payloadBytes)})
+	return err
+}
+
+// Void voids issued ticket with refund during void window, allowed only for merchant
+func (ts *PaymentSDK) Void(payload entities.CancelPayload) error {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = ts.Backend.Invoke(ts.chaincode(), `/void`, []string{string(payloadBytes)})
+	return err
+}
+
+// DisputeOpen opens dispute against debited payment, allowed only for payer bank
+func (ts *PaymentSDK) DisputeOpen(payload entities.DisputeOpenPayload) (*entities.Dispute, error) {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	disputeBytes, err := ts.Backend.Invoke(ts.chaincode(), `/dispute/open`, []string{string(payloadBytes)})
+	if err != nil {
+		return nil, err
+	}
+	var dispute entities.Dispute
+	if err = json.Unmarshal(disputeBytes, &dispute); err != nil {
+		return nil, err
+	}
+	return &dispute, nil
+}
+
+// DisputeRespond sends evidence hashes of merchant
+func (ts *PaymentSDK) DisputeRespond(payload entities.DisputeRespondPayload) error {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = ts.Backend.Invoke(ts.chaincode(), `/dispute/respond`, []string{string(payloadBytes)})
+	return err
+}
+
+// DisputeResolve accepts or rejects dispute, accepted dispute refunds payment
+func (ts *PaymentSDK) DisputeResolve(payload entities.DisputeResolvePayload) error {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = ts.Backend.Invoke(ts.chaincode(), `/dispute/resolve`, []string{string(payloadBytes)})
+	return err
+}
+
+func (ts *PaymentSDK) Dispute(disputeId string) (*entities.Dispute, error) {
+	disputeBytes, err := ts.Backend.Query(ts.chaincode(), `/dispute/get`, []string{disputeId})
+	if err != nil {
+		return nil, err
+	}
+	var dispute entities.Dispute
+	if err = json.Unmarshal(disputeBytes, &dispute); err != nil {
+		return nil, err
+	}
+	return &dispute, nil
+}
+
+// Disputes returns disputes of payment visible to current organization, all payments if paymentId is empty
+func (ts *PaymentSDK) Disputes(paymentId string) ([]entities.Dispute, error) {
+	disputesBytes, err := ts.Backend.Query(ts.chaincode(), `/dispute/list`, []string{paymentId})
+	if err != nil {
+		return nil, err
+	}
+	var disputes []entities.Dispute
+	if err = json.Unmarshal(disputesBytes, &disputes); err != nil {
+		return nil, err
+	}
+	return disputes, nil
+}
+
+// PaymentMeta returns typed meta of payment
+func (ts *PaymentSDK) PaymentMeta(paymentId string) (*entities.PaymentMeta, error) {
+	metaBytes, err := ts.Backend.Query(ts.chaincode(), `/meta/fields`, []string{paymentId})
+	if err != nil {
+		return nil, err
+	}
+	var meta entities.PaymentMeta
+	if err = json.Unmarshal(metaBytes, &meta); err != nil {
+		return nil, err
+	}
+	return &meta, nil
+}
+
+// SetPaymentMeta sets payment meta values allowed for current role by meta schema
+func (ts *PaymentSDK) SetPaymentMeta(payload entities.MetaPutPayload) error {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = ts.Backend.Invoke(ts.chaincode(), `/meta/put`, []string{string(payloadBytes)})
+	return err
+}
+
+func (ts *PaymentSDK) MetaSchema() ([]entities.MetaField, error) {
+	schemaBytes, err := ts.Backend.Query(ts.chaincode(), `/meta/schema`, []string{})
+	if err != nil {
+		return nil, err
+	}
+	var schema []entities.MetaField
+	if err = json.Unmarshal(schemaBytes, &schema); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+// PaymentsByPNR returns payments of itinerary visible to current organization
+func (ts *PaymentSDK) PaymentsByPNR(pnr string) ([]entities.Payment, error) {
+	paymentsBytes, err := ts.Backend.Query(ts.chaincode(), `/itinerary/search`, []string{pnr})
+	if err != nil {
+		return nil, err
+	}
+	var payments []entities.Payment
+	if err = json.Unmarshal(paymentsBytes, &payments); err != nil {
+		return nil, err
+	}
+	return payments, nil
+}
+
+// SalesReportCreate builds or rebuilds draft sales report of agent period, allowed only for merchant
+func (ts *PaymentSDK) SalesReportCreate(payload entities.SalesReportPayload) (*entities.SalesReport, error) {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	reportBytes, err := ts.Backend.Invoke(ts.chaincode(), `/report/create`, []string{string(payloadBytes)})
+	if err != nil {
+		return nil, err
+	}
+	var report entities.SalesReport
+	if err = json.Unmarshal(reportBytes, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// SalesReportSign co-signs report hash, period is closed when merchant and agent signed it
+func (ts *PaymentSDK) SalesReportSign(reportId string, hash string) error {
+	_, err := ts.Backend.Invoke(ts.chaincode(), `/report/sign`, []string{reportId, hash})
+	return err
+}
+
+func (ts *PaymentSDK) SalesReport(reportId string) (*entities.SalesReport, error) {
+	reportBytes, err := ts.Backend.Query(ts.chaincode(), `/report/get`, []string{reportId})
+	if err != nil {
+		return nil, err
+	}
+	var report entities.SalesReport
+	if err = json.Unmarshal(reportBytes, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// SalesReports returns sales reports of agent visible to current organization, all agents if agentId is empty
+func (ts *PaymentSDK) SalesReports(agentId string) ([]entities.SalesReport, error) {
+	reportsBytes, err := ts.Backend.Query(ts.chaincode(), `/report/list`, []string{agentId})
+	if err != nil {
+		return nil, err
+	}
+	var reports []entities.SalesReport
+	if err = json.Unmarshal(reportsBytes, &reports); err != nil {
+		return nil, err
+	}
+	return reports, nil
+}
+
+// SubAgentSet adds or replaces point of sale of agent with permissions and limits, allowed only for merchant
+func (ts *PaymentSDK) SubAgentSet(subAgent entities.SubAgent) (*entities.SubAgent, error) {
+	subAgentBytes, err := json.Marshal(subAgent)
+	if err != nil {
+		return nil, err
+	}
+	savedBytes, err := ts.Backend.Invoke(ts.chaincode(), `/subagent/set`, []string{string(subAgentBytes)})
+	if err != nil {
+		return nil, err
+	}
+	var saved entities.SubAgent
+	if err = json.Unmarshal(savedBytes, &saved); err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// SubAgents returns points of sale of agent, agent of current organization if agentId is empty
+func (ts *PaymentSDK) SubAgents(agentId string) ([]entities.SubAgent, error) {
+	subAgentsBytes, err := ts.Backend.Query(ts.chaincode(), `/subagent/list`, []string{agentId})
+	if err != nil {
+		return nil, err
+	}
+	var subAgents []entities.SubAgent
+	if err = json.Unmarshal(subAgentsBytes, &subAgents); err != nil {
+		return nil, err
+	}
+	return subAgents, nil
+}
+
+// SubAgentPayments returns payments created by points of sale of current agent, all points of sale if subAgentId is empty
+func (ts *PaymentSDK) SubAgentPayments(subAgentId string) ([]entities.Payment, error) {
+	paymentsBytes, err := ts.Backend.Query(ts.chaincode(), `/subagent/payments`, []string{subAgentId})
+	if err != nil {
+		return nil, err
+	}
+	var payments []entities.Payment
+	if err = json.Unmarshal(paymentsBytes, &payments); err != nil {
+		return nil, err
+	}
+	return payments, nil
+}
+
+// invoke sends transaction and reports its stages to progress, progress can be nil
+// SDK invoke returns after commit, so transaction is reported as invoking until it is committed or failed
+func (ts *PaymentSDK) invoke(fn string, args []string, progress ProgressFunc) ([]byte, error) {
+	if progress == nil {
+		progress = func(Stage) {}
+	}
+
+	progress(StageInvoking)
+	result, err := ts.Backend.Invoke(ts.chaincode(), fn, args)
+	if err != nil {
+		progress(StageFailed)
+		return nil, err
+	}
+	progress(StageCommitted)
+	return result, nil
+}
+
+// CreatePayment creates payment from agent organization and waits for commit
+func (ts *PaymentSDK) CreatePayment(payload entities.PaymentCreatePayload, progress ProgressFunc) error {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = ts.invoke(`/create`, []string{string(payloadBytes)}, progress)
+	return err
+}
+
+// RecordLimitBreach records limit breach of payment rejected for agent, allowed only for merchant
+// Event of rejected transaction isn't delivered, so breach is recorded in own transaction
+func (ts *PaymentSDK) RecordLimitBreach(agentId string, payload entities.PaymentCreatePayload) (breach entities.LimitBreachEvent, err error) {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	breachBytes, err := ts.Backend.Invoke(ts.chaincode(), `/limits/breach`, []string{agentId, string(payloadBytes)})
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(breachBytes, &breach)
+	return
+}
+
+// UpdatePaymentState changes payment state and waits for commit
+// Approval is returned when state change above approval threshold waits for second signer
+func (ts *PaymentSDK) UpdatePaymentState(request apiEntities.RequestUpdateState, progress ProgressFunc) (*entities.PaymentApprovalEvent, error) {
+	requestBytes, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	result, err := ts.invoke(`/updateState`, []string{string(requestBytes)}, progress)
+	if err != nil {
+		return nil, err
+	}
+	return pendingApproval(result)
+}
+
+// pendingApproval parses result of state change, applied change has empty result
+func pendingApproval(result []byte) (*entities.PaymentApprovalEvent, error) {
+	if len(result) == 0 {
+		return nil, nil
+	}
+	var approval entities.PaymentApprovalEvent
+	if err := json.Unmarshal(result, &approval); err != nil {
+		return nil, err
+	}
+	return &approval, nil
+}
+
 func (ts *PaymentSDK) GetMerchant() (*coreEntities.Member, error) {
-	merchantBytes, err := ts.SDKCore.Query(chaincode, `/merchant`, []string{})
+	merchantBytes, err := ts.Backend.Query(ts.chaincode(), `/merchant`, []string{})
 	if err != nil {
 		return nil, err
 	}
@@ -99,11 +636,23 @@ func (ts *PaymentSDK) GetMerchant() (*coreEntities.Member, error) {
 	return &m, nil
 }
 
+// NewPaymentSDK returns SDK working with presented backend
+func NewPaymentSDK(b Backend) *PaymentSDK {
+	return &PaymentSDK{Backend: b}
+}
+
 func InitSDK(org string, channel string, l logger.Logger) (*PaymentSDK, error) {
 
 	s, err := sdk.Init(org, channel, l)
 	if err != nil {
 		return nil, err
 	}
-	return &PaymentSDK{s}, nil
+	return &PaymentSDK{SDKControlStructure: s, Backend: fabricBackend{s}, Channel: channel}, nil
+}
+
+// Close releases fabric SDK connections, SDK can't be used after it
+func (ts *PaymentSDK) Close() {
+	if ts.SDKControlStructure != nil {
+		ts.SDKControlStructure.Close()
+	}
 }
@@ -0,0 +1,239 @@
+// Package config loads tickets API server settings from YAML file and environment variables
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// EnvPrefix is prefix of environment variables overriding file settings, e.g. TICKETS_API_CHANNEL
+const EnvPrefix = `TICKETS_API_`
+
+type TLS struct {
+	Enabled  bool   `yaml:"enabled"`
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+}
+
+type Log struct {
+	// Level is one of debug, info, warn, error, off
+	Level    string `yaml:"level"`
+	Requests bool   `yaml:"requests"`
+}
+
+type Pool struct {
+	IdleTimeout   time.Duration `yaml:"idleTimeout"`
+	CheckInterval time.Duration `yaml:"checkInterval"`
+	MaxInFlight   int           `yaml:"maxInFlight"`
+}
+
+// RateLimit is token bucket of payment creation per organization, zero rate disables limit
+type RateLimit struct {
+	// Rate is count of payments per second
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
+}
+
+// Operations configures store of asynchronous submissions and their callbacks
+type Operations struct {
+	// TTL is duration finished operation is kept for polling
+	TTL time.Duration `yaml:"ttl"`
+	// CallbackSecret signs callback bodies with HMAC-SHA256, callbacks are disabled if it is empty
+	CallbackSecret string `yaml:"callbackSecret"`
+	// CallbackHosts is allow-list of callback hosts, any public host is allowed if it is empty
+	CallbackHosts []string `yaml:"callbackHosts"`
+}
+
+type JWT struct {
+	Secret string `yaml:"secret"`
+}
+
+type MTLS struct {
+	Enabled      bool   `yaml:"enabled"`
+	ClientCAFile string `yaml:"clientCAFile"`
+}
+
+// TestIssuer signs development tokens with own secret, its tokens aren't accepted with jwt secret
+// It can be enabled only in simulator mode
+type TestIssuer struct {
+	Enabled bool          `yaml:"enabled"`
+	Secret  string        `yaml:"secret"`
+	TTL     time.Duration `yaml:"ttl"`
+}
+
+type Auth struct {
+	JWT  JWT  `yaml:"jwt"`
+	MTLS MTLS `yaml:"mtls"`
+	// APIKeys maps API key to organization MSP id
+	APIKeys map[string]string `yaml:"apiKeys"`
+	// OrgRoles maps organization MSP id to role: AGENT, MERCHANT or BANK
+	OrgRoles map[string]string `yaml:"orgRoles"`
+	// Identities maps organization MSP id and principal subject to SDK identity with own enrollment certificate,
+	// transactions of other principals are signed with organization identity
+	Identities map[string]map[string]string `yaml:"identities"`
+	TestIssuer TestIssuer                   `yaml:"testIssuer"`
+}
+
+type Config struct {
+	Org             string        `yaml:"org"`
+	Channel         string        `yaml:"channel"`
+	Chaincode       string        `yaml:"chaincode"`
+	Listen          string        `yaml:"listen"`
+	UrlPath         string        `yaml:"urlPath"`
+	Simulator       bool          `yaml:"simulator"`
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout"`
+	TLS             TLS           `yaml:"tls"`
+	Log             Log           `yaml:"log"`
+	Pool            Pool          `yaml:"pool"`
+	Operations      Operations    `yaml:"operations"`
+	RateLimit       RateLimit     `yaml:"rateLimit"`
+	Auth            Auth          `yaml:"auth"`
+}
+
+// Default returns config used when value is not set in file or environment
+func Default() Config {
+	return Config{
+		Channel:         `mychannel`,
+		Chaincode:       `tickets`,
+		Listen:          `:8080`,
+		UrlPath:         `/tickets`,
+		ShutdownTimeout: 10 * time.Second,
+		Log:             Log{Level: `info`},
+		Pool:            Pool{IdleTimeout: 10 * time.Minute, CheckInterval: time.Minute, MaxInFlight: 100},
+		Operations:      Operations{TTL: time.Hour},
+		Auth:            Auth{TestIssuer: TestIssuer{TTL: time.Hour}},
+	}
+}
+
+// Load reads config from YAML file, then applies environment variables
+// File path can be empty, default config with environment variables is returned in this case
+// Config isn't validated, call Validate after command line flags are applied
+func Load(path string) (Config, error) {
+	c := Default()
+
+	if path != `` {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return c, err
+		}
+		if err = yaml.Unmarshal(data, &c); err != nil {
+			return c, fmt.Errorf("invalid config file %s: %s", path, err)
+		}
+	}
+
+	err := c.applyEnv()
+	return c, err
+}
+
+func (c *Config) applyEnv() (err error) {
+	stringVars := map[string]*string{
+		`ORG`:           &c.Org,
+		`CHANNEL`:       &c.Channel,
+		`CHAINCODE`:     &c.Chaincode,
+		`LISTEN`:        &c.Listen,
+		`URL_PATH`:      &c.UrlPath,
+		`TLS_CERT_FILE`: &c.TLS.CertFile,
+		`TLS_KEY_FILE`:  &c.TLS.KeyFile,
+		`LOG_LEVEL`:     &c.Log.Level,
+
+		`AUTH_JWT_SECRET`:          &c.Auth.JWT.Secret,
+		`AUTH_MTLS_CLIENT_CA_FILE`: &c.Auth.MTLS.ClientCAFile,
+		`AUTH_TEST_ISSUER_SECRET`:  &c.Auth.TestIssuer.Secret,
+
+		`OPERATIONS_CALLBACK_SECRET`: &c.Operations.CallbackSecret,
+	}
+	for name, value := range stringVars {
+		if v, ok := os.LookupEnv(EnvPrefix + name); ok {
+			*value = v
+		}
+	}
+
+	boolVars := map[string]*bool{
+		`SIMULATOR`:    &c.Simulator,
+		`TLS_ENABLED`:  &c.TLS.Enabled,
+		`LOG_REQUESTS`: &c.Log.Requests,
+
+		`AUTH_MTLS_ENABLED`:        &c.Auth.MTLS.Enabled,
+		`AUTH_TEST_ISSUER_ENABLED`: &c.Auth.TestIssuer.Enabled,
+	}
+	for name, value := range boolVars {
+		if v, ok := os.LookupEnv(EnvPrefix + name); ok {
+			if *value, err = strconv.ParseBool(v); err != nil {
+				return fmt.Errorf("invalid %s%s: %s", EnvPrefix, name, err)
+			}
+		}
+	}
+
+	durationVars := map[string]*time.Duration{
+		`SHUTDOWN_TIMEOUT`:    &c.ShutdownTimeout,
+		`POOL_IDLE_TIMEOUT`:   &c.Pool.IdleTimeout,
+		`POOL_CHECK_INTERVAL`: &c.Pool.CheckInterval,
+		`OPERATIONS_TTL`:      &c.Operations.TTL,
+	}
+	for name, value := range durationVars {
+		if v, ok := os.LookupEnv(EnvPrefix + name); ok {
+			if *value, err = time.ParseDuration(v); err != nil {
+				return fmt.Errorf("invalid %s%s: %s", EnvPrefix, name, err)
+			}
+		}
+	}
+
+	if v, ok := os.LookupEnv(EnvPrefix + `POOL_MAX_IN_FLIGHT`); ok {
+		if c.Pool.MaxInFlight, err = strconv.Atoi(v); err != nil {
+			return fmt.Errorf("invalid %sPOOL_MAX_IN_FLIGHT: %s", EnvPrefix, err)
+		}
+	}
+
+	if v, ok := os.LookupEnv(EnvPrefix + `RATE_LIMIT_RATE`); ok {
+		if c.RateLimit.Rate, err = strconv.ParseFloat(v, 64); err != nil {
+			return fmt.Errorf("invalid %sRATE_LIMIT_RATE: %s", EnvPrefix, err)
+		}
+	}
+
+	if v, ok := os.LookupEnv(EnvPrefix + `RATE_LIMIT_BURST`); ok {
+		if c.RateLimit.Burst, err = strconv.Atoi(v); err != nil {
+			return fmt.Errorf("invalid %sRATE_LIMIT_BURST: %s", EnvPrefix, err)
+		}
+	}
+	return nil
+}
+
+// Validate checks required settings
+func (c Config) Validate() error {
+	if !c.Simulator && c.Org == `` {
+		return fmt.Errorf("org is required, set it in config or %sORG", EnvPrefix)
+	}
+	if c.Channel == `` {
+		return fmt.Errorf("channel is required")
+	}
+	if c.Chaincode == `` {
+		return fmt.Errorf("chaincode is required")
+	}
+	if c.TLS.Enabled && (c.TLS.CertFile == `` || c.TLS.KeyFile == ``) {
+		return fmt.Errorf("tls cert and key files are required when tls is enabled")
+	}
+	if c.Auth.MTLS.Enabled && (!c.TLS.Enabled || c.Auth.MTLS.ClientCAFile == ``) {
+		return fmt.Errorf("mtls requires enabled tls and client CA file")
+	}
+	if c.RateLimit.Rate < 0 || c.RateLimit.Burst < 0 {
+		return fmt.Errorf("rate limit rate and burst can't be negative")
+	}
+	if c.Auth.TestIssuer.Enabled && !c.Simulator {
+		return fmt.Errorf("test issuer is available only in simulator mode")
+	}
+	if c.Auth.TestIssuer.Enabled && c.Auth.TestIssuer.Secret == `` {
+		return fmt.Errorf("test issuer requires own secret")
+	}
+	if c.Auth.TestIssuer.Enabled && c.Auth.TestIssuer.Secret == c.Auth.JWT.Secret {
+		return fmt.Errorf("test issuer secret must differ from jwt secret")
+	}
+	if !c.Simulator && c.Auth.JWT.Secret == `` && !c.Auth.MTLS.Enabled && len(c.Auth.APIKeys) == 0 {
+		return fmt.Errorf("at least one auth method is required: jwt, mtls or apiKeys")
+	}
+	return nil
+}
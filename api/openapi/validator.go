@@ -0,0 +1,154 @@
+// Package openapi validates REST requests and responses against OpenAPI 3 document
+package openapi
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/labstack/echo"
+)
+
+// DefaultMaxBodySize is max size of request body read for validation
+const DefaultMaxBodySize = 1 << 20
+
+// Validator matches requests under url prefix with document paths
+type Validator struct {
+	Swagger *openapi3.Swagger
+	// MaxBodySize is max size of request body, larger requests are rejected with 413 before validation
+	MaxBodySize int64
+	router      *openapi3filter.Router
+	prefix      string
+}
+
+// Load parses OpenAPI document, paths of document are relative to prefix
+func Load(spec []byte, prefix string) (*Validator, error) {
+	swagger, err := openapi3.NewSwaggerLoader().LoadSwaggerFromData(spec)
+	if err != nil {
+		return nil, err
+	}
+	return &Validator{Swagger: swagger, MaxBodySize: DefaultMaxBodySize, router: openapi3filter.NewRouter().WithSwagger(swagger), prefix: prefix}, nil
+}
+
+// readBody reads request body limited by MaxBodySize, body is read once and restored for validation and handler
+func (v *Validator) readBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+
+	tooLarge := echo.NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("request body is larger than %d bytes", v.MaxBodySize))
+	if r.ContentLength > v.MaxBodySize {
+		return nil, tooLarge
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, v.MaxBodySize+1))
+	r.Body.Close()
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if int64(len(body)) > v.MaxBodySize {
+		return nil, tooLarge
+	}
+	return body, nil
+}
+
+func (v *Validator) input(r *http.Request) (*openapi3filter.RequestValidationInput, error) {
+	u := *r.URL
+	u.Path = strings.TrimPrefix(u.Path, v.prefix)
+
+	route, pathParams, err := v.router.FindRoute(r.Method, &u)
+	if err != nil {
+		return nil, err
+	}
+	return &openapi3filter.RequestValidationInput{Request: r, PathParams: pathParams, Route: route}, nil
+}
+
+// recorder keeps response until it is validated
+type recorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *recorder) WriteHeader(status int) {
+	r.status = status
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	return r.body.Write(b)
+}
+
+// Middleware
+// Rejects requests larger than MaxBodySize with 413 and requests not matching document with 400, responses not matching document are logged
+// or replaced with 500 if strict is set
+func (v *Validator) Middleware(strict bool) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			input, err := v.input(c.Request())
+			if err != nil {
+				return echo.NewHTTPError(http.StatusNotFound, err.Error())
+			}
+
+			body, err := v.readBody(c.Request())
+			if err != nil {
+				return err
+			}
+
+			if body != nil {
+				c.Request().Body = ioutil.NopCloser(bytes.NewReader(body))
+			}
+			if err = openapi3filter.ValidateRequest(c.Request().Context(), input); err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+			if body != nil {
+				c.Request().Body = ioutil.NopCloser(bytes.NewReader(body))
+			}
+
+			original := c.Response().Writer
+			rec := &recorder{ResponseWriter: original, status: http.StatusOK}
+			c.Response().Writer = rec
+			err = next(c)
+			c.Response().Writer = original
+			if err != nil && !c.Response().Committed {
+				// error response is written by echo error handler
+				return err
+			}
+
+			responseErr := openapi3filter.ValidateResponse(c.Request().Context(), &openapi3filter.ResponseValidationInput{
+				RequestValidationInput: input,
+				Status:                 rec.status,
+				Header:                 original.Header(),
+				Body:                   ioutil.NopCloser(bytes.NewReader(rec.body.Bytes())),
+			})
+			if responseErr != nil {
+				c.Logger().Warnf("response of %s %s doesn't match openapi document: %s", c.Request().Method, c.Path(), responseErr)
+				if strict {
+					original.Header().Del(echo.HeaderContentLength)
+					original.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+					original.WriteHeader(http.StatusInternalServerError)
+					_, writeErr := original.Write([]byte(`{"message":"response doesn't match openapi document"}`))
+					return writeErr
+				}
+			}
+
+			original.WriteHeader(rec.status)
+			if _, writeErr := original.Write(rec.body.Bytes()); writeErr != nil {
+				return writeErr
+			}
+			return err
+		}
+	}
+}
+
+// Handler
+// Serves OpenAPI document
+func Handler(spec []byte) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return c.Blob(http.StatusOK, `application/yaml`, spec)
+	}
+}
@@ -0,0 +1,327 @@
+// Package operations tracks asynchronous ledger submissions of REST API
+package operations
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"syscall"
+	"time"
+
+	"s7ab-platform-hyperledger/platform/core/logger"
+	"s7ab-platform-hyperledger/platform/s7ticket/api/common"
+	"s7ab-platform-hyperledger/platform/s7ticket/entities"
+)
+
+const (
+	KindCreatePayment = `CREATE_PAYMENT`
+	KindUpdatePayment = `UPDATE_PAYMENT`
+
+	// SignatureHeader is hex encoded HMAC-SHA256 of callback body with callback secret
+	SignatureHeader = `X-Tickets-Signature`
+
+	defaultTTL             = time.Hour
+	defaultCallbackTimeout = 10 * time.Second
+)
+
+var (
+	ErrNotFound           = errors.New(`operation not found`)
+	ErrInvalidCallbackUrl = errors.New(`callback url must be absolute http or https url`)
+	ErrCallbackHost       = errors.New(`callback host is not allowed`)
+	ErrCallbacksDisabled  = errors.New(`callbacks are disabled, callback secret is not set`)
+)
+
+// Operation is state of asynchronous submission
+// Stage is one of common stages, Error is set when stage is FAILED
+// Approval is set when committed change only opened four-eyes approval and payment state isn't changed yet
+type Operation struct {
+	Id          string                         `json:"id"`
+	Kind        string                         `json:"kind"`
+	PaymentId   string                         `json:"paymentId"`
+	Stage       common.Stage                   `json:"stage"`
+	Error       string                         `json:"error,omitempty"`
+	Approval    *entities.PaymentApprovalEvent `json:"approval,omitempty"`
+	CallbackUrl string                         `json:"callbackUrl,omitempty"`
+	Org         string                         `json:"org"`
+	CreatedAt   time.Time                      `json:"createdAt"`
+	UpdatedAt   time.Time                      `json:"updatedAt"`
+}
+
+// Done returns true when operation is committed or failed
+func (o Operation) Done() bool {
+	return o.Stage == common.StageCommitted || o.Stage == common.StageFailed
+}
+
+type Store interface {
+	Put(o Operation) error
+	Get(id string) (Operation, error)
+}
+
+// MemoryStore keeps operations in memory, finished operations are removed after TTL
+type MemoryStore struct {
+	mu         sync.RWMutex
+	operations map[string]Operation
+	ttl        time.Duration
+}
+
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	if ttl == 0 {
+		ttl = defaultTTL
+	}
+	return &MemoryStore{operations: make(map[string]Operation), ttl: ttl}
+}
+
+func (s *MemoryStore) Put(o Operation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.operations[o.Id] = o
+	return nil
+}
+
+func (s *MemoryStore) Get(id string) (Operation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	o, ok := s.operations[id]
+	if !ok {
+		return Operation{}, ErrNotFound
+	}
+	return o, nil
+}
+
+// Expire removes operations finished earlier than TTL ago
+func (s *MemoryStore) Expire() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, o := range s.operations {
+		if o.Done() && time.Since(o.UpdatedAt) > s.ttl {
+			delete(s.operations, id)
+		}
+	}
+}
+
+// Run calls Expire with interval until ctx is done
+func (s *MemoryStore) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Expire()
+		}
+	}
+}
+
+// Submit sends transaction reporting its stages to progress
+// It returns pending approval if transaction only opened four-eyes approval
+type Submit func(progress common.ProgressFunc) (*entities.PaymentApprovalEvent, error)
+
+// Runner runs submissions in background and tracks them in store
+type Runner struct {
+	Store  Store
+	Client *http.Client
+	Log    logger.Logger
+	// Secret signs callback bodies, callbacks are rejected if it isn't set
+	Secret []byte
+	// Hosts is allow-list of callback hosts, any public host is allowed if it is empty
+	Hosts []string
+}
+
+// NewRunner returns runner posting callbacks to public addresses or to hosts of allow-list if it is set
+func NewRunner(store Store, l logger.Logger) *Runner {
+	r := &Runner{Store: store, Log: l}
+	dialer := &net.Dialer{Timeout: defaultCallbackTimeout, Control: func(network string, address string, c syscall.RawConn) error {
+		// allow-listed hosts can be internal
+		if len(r.Hosts) > 0 {
+			return nil
+		}
+		return publicAddressControl(network, address, c)
+	}}
+	r.Client = &http.Client{
+		Timeout:   defaultCallbackTimeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext},
+		// redirect could lead callback to address, which isn't allowed
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	return r
+}
+
+// isPublicIP returns false for loopback, private, link-local and other not routable addresses
+func isPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, block := range privateBlocks {
+		if block.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+var privateBlocks = func() (blocks []*net.IPNet) {
+	for _, cidr := range []string{`10.0.0.0/8`, `172.16.0.0/12`, `192.168.0.0/16`, `100.64.0.0/10`, `fc00::/7`} {
+		_, block, _ := net.ParseCIDR(cidr)
+		blocks = append(blocks, block)
+	}
+	return
+}()
+
+// publicAddressControl rejects connections to not public addresses, it checks resolved address
+// so callback host can't be rebound to internal address after validation
+func publicAddressControl(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+		return fmt.Errorf("%s: %s", ErrCallbackHost, address)
+	}
+	return nil
+}
+
+// ValidateCallbackUrl returns error if callback url is set and isn't absolute http(s) url of allowed host
+// Host must be in allow-list if it is set, otherwise host must not resolve to loopback or private address
+func (r *Runner) ValidateCallbackUrl(callbackUrl string) error {
+	if callbackUrl == `` {
+		return nil
+	}
+	u, err := url.Parse(callbackUrl)
+	if err != nil || (u.Scheme != `http` && u.Scheme != `https`) || u.Hostname() == `` {
+		return ErrInvalidCallbackUrl
+	}
+	if len(r.Secret) == 0 {
+		return ErrCallbacksDisabled
+	}
+
+	if len(r.Hosts) > 0 {
+		for _, h := range r.Hosts {
+			if h == u.Hostname() {
+				return nil
+			}
+		}
+		return ErrCallbackHost
+	}
+
+	ips, err := net.LookupIP(u.Hostname())
+	if err != nil || len(ips) == 0 {
+		return ErrCallbackHost
+	}
+	for _, ip := range ips {
+		if !isPublicIP(ip) {
+			return ErrCallbackHost
+		}
+	}
+	return nil
+}
+
+// Sign returns signature of callback body, receiver checks it in SignatureHeader
+func Sign(secret []byte, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func newId() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ``, err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Start stores operation in SUBMITTED stage and runs submit in background
+// Result is posted to callbackUrl when operation is committed or failed
+func (r *Runner) Start(kind string, paymentId string, org string, callbackUrl string, submit Submit) (Operation, error) {
+	if err := r.ValidateCallbackUrl(callbackUrl); err != nil {
+		return Operation{}, err
+	}
+	id, err := newId()
+	if err != nil {
+		return Operation{}, err
+	}
+
+	now := time.Now().UTC()
+	o := Operation{
+		Id:          id,
+		Kind:        kind,
+		PaymentId:   paymentId,
+		Stage:       common.StageSubmitted,
+		CallbackUrl: callbackUrl,
+		Org:         org,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err = r.Store.Put(o); err != nil {
+		return Operation{}, err
+	}
+
+	go r.run(o, submit)
+	return o, nil
+}
+
+func (r *Runner) run(o Operation, submit Submit) {
+	progress := func(stage common.Stage) {
+		o.Stage = stage
+		o.UpdatedAt = time.Now().UTC()
+		if err := r.Store.Put(o); err != nil {
+			r.Log.Warn(`operation store`, logger.KV(`operation`, o.Id), logger.KV(`error`, err))
+		}
+	}
+
+	approval, err := submit(func(stage common.Stage) {
+		// failure is stored below together with error, commit together with pending approval
+		if stage != common.StageFailed && stage != common.StageCommitted {
+			progress(stage)
+		}
+	})
+	if err != nil {
+		o.Error = err.Error()
+		progress(common.StageFailed)
+	} else {
+		o.Approval = approval
+		progress(common.StageCommitted)
+	}
+
+	if o.CallbackUrl != `` {
+		r.callback(o)
+	}
+}
+
+// callback posts finished operation signed with runner secret to its callback url, delivery isn't retried
+func (r *Runner) callback(o Operation) {
+	body, err := json.Marshal(o)
+	if err != nil {
+		r.Log.Warn(`operation callback`, logger.KV(`operation`, o.Id), logger.KV(`error`, err))
+		return
+	}
+	req, err := http.NewRequest(http.MethodPost, o.CallbackUrl, bytes.NewReader(body))
+	if err != nil {
+		r.Log.Warn(`operation callback`, logger.KV(`operation`, o.Id), logger.KV(`error`, err))
+		return
+	}
+	req.Header.Set(`Content-Type`, `application/json`)
+	req.Header.Set(SignatureHeader, Sign(r.Secret, body))
+
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		r.Log.Warn(`operation callback`, logger.KV(`operation`, o.Id), logger.KV(`error`, err))
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		r.Log.Warn(`operation callback`, logger.KV(`operation`, o.Id), logger.KV(`status`, resp.StatusCode))
+	}
+}
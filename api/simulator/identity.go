@@ -0,0 +1,94 @@
+package simulator
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"time"
+
+	platformEntities "s7ab-platform-hyperledger/platform/core/entities"
+	"s7ab-platform-hyperledger/platform/s7ticket/api/auth"
+	"s7ab-platform-hyperledger/platform/s7ticket/entities"
+)
+
+// attrsOID is certificate extension with attributes issued by fabric-ca
+var attrsOID = asn1.ObjectIdentifier{1, 2, 3, 4, 5, 6, 7, 8, 1}
+
+// identity is seeded organization of simulated network with its CA certificate used as transaction creator
+type identity struct {
+	platformEntities.Member
+}
+
+// newIdentity creates organization with self-signed CA certificate, bankId is empty for organizations without bank
+// Certificate of organization with tickets role has admin access, so simulated clients can call every route
+func newIdentity(mspId, role, itn, account, bankId string) (identity, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return identity{}, err
+	}
+
+	var extensions []pkix.Extension
+	if role != `` {
+		attrsBytes, err := json.Marshal(map[string]interface{}{`attrs`: map[string]string{
+			entities.AttrRole: strings.ToLower(role) + `-` + entities.AccessAdmin,
+		}})
+		if err != nil {
+			return identity{}, err
+		}
+		extensions = append(extensions, pkix.Extension{Id: attrsOID, Value: attrsBytes})
+	}
+
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: `ca.` + mspId, Organization: []string{mspId}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		ExtraExtensions:       extensions,
+	}
+	certBytes, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return identity{}, err
+	}
+
+	var org identity
+	org.OrganizationId = mspId
+	org.OrganizationCACert = string(pem.EncodeToMemory(&pem.Block{Type: `CERTIFICATE`, Bytes: certBytes}))
+	org.BankOrganizationId = bankId
+	org.Requisites.ITN = itn
+	org.Requisites.SettlementAccount = account
+	return org, nil
+}
+
+// newIdentities creates organizations of simulated network: operator, two banks, merchant and two agents
+func newIdentities() (operator, bank, bank2, merchant, agent, agent2 identity, err error) {
+	if operator, err = newIdentity(`Org1MSP`, ``, `7700000001`, `40702810000000000001`, ``); err != nil {
+		return
+	}
+	if bank, err = newIdentity(`Org2MSP`, auth.RoleBank, `7700000002`, `30101810000000000002`, ``); err != nil {
+		return
+	}
+	if bank2, err = newIdentity(`Org5MSP`, auth.RoleBank, `7700000005`, `30101810000000000005`, ``); err != nil {
+		return
+	}
+	bank.Type = platformEntities.BANK_TYPE
+	bank2.Type = platformEntities.BANK_TYPE
+
+	if merchant, err = newIdentity(`Org3MSP`, auth.RoleMerchant, `7700000003`, `40702810000000000003`, bank.OrganizationId); err != nil {
+		return
+	}
+	if agent, err = newIdentity(`Org4MSP`, auth.RoleAgent, `7700000004`, `40702810000000000004`, bank.OrganizationId); err != nil {
+		return
+	}
+	agent2, err = newIdentity(`Org6MSP`, auth.RoleAgent, `7700000006`, `40702810000000000006`, bank2.OrganizationId)
+	return
+}
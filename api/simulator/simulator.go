@@ -0,0 +1,210 @@
+// Package simulator runs tickets and organizations chaincodes in process on mock stubs,
+// so REST API can be served without fabric network
+package simulator
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/labstack/echo"
+	coreCC "s7ab-platform-hyperledger/platform/core/chaincode"
+	"s7ab-platform-hyperledger/platform/core/logger"
+	s7t "s7ab-platform-hyperledger/platform/s7platform/testing"
+	"s7ab-platform-hyperledger/platform/s7ticket/api/auth"
+	"s7ab-platform-hyperledger/platform/s7ticket/api/common"
+	"s7ab-platform-hyperledger/platform/s7ticket/chaincode"
+	"s7ab-platform-hyperledger/platform/s7ticket/entities"
+)
+
+const (
+	// IdentityHeader is request header with MSP id used as transaction creator
+	IdentityHeader = `X-Simulator-Msp`
+
+	ticketsChaincode       = `tickets`
+	organizationsChaincode = `organizations`
+)
+
+// Network is in-memory ledger with seeded organizations: operator, two banks, merchant and two agents
+// Requests are executed one by one, as peer does with transactions of one chaincode
+type Network struct {
+	mu         sync.Mutex
+	txCounter  uint64
+	stubs      map[string]*s7t.FullMockStub
+	identities map[string]string
+	roles      auth.OrgRoles
+	merchant   string
+}
+
+func orgToCreatorTransformer(params ...interface{}) (mspID, cert string) {
+	org := params[0].(identity)
+	return org.OrganizationId, org.OrganizationCACert
+}
+
+// New starts chaincodes and seeds organizations with generated identities
+func New(l logger.Logger) (*Network, error) {
+	operator, bank, bank2, merchant, agent, agent2, err := newIdentities()
+	if err != nil {
+		return nil, err
+	}
+
+	orgs := s7t.NewFullMockStub(organizationsChaincode, coreCC.NewOrganization(l))
+	orgs.RegisterCreatorTransformer(orgToCreatorTransformer)
+	if err = checkResponse(orgs.MockInit("init-organizations", orgs.ArgsToBytes(operator.OrganizationId))); err != nil {
+		return nil, err
+	}
+
+	tickets := s7t.NewFullMockStub(ticketsChaincode, chaincode.NewTicket(l))
+	tickets.MockPeerChaincode(organizationsChaincode+"/mychannel", orgs)
+	tickets.RegisterCreatorTransformer(orgToCreatorTransformer)
+
+	steps := []struct {
+		stub *s7t.FullMockStub
+		from interface{}
+		fn   string
+		args []interface{}
+	}{
+		{orgs, operator, `/create`, []interface{}{bank}},
+		{orgs, operator, `/create`, []interface{}{bank2}},
+		{orgs, operator, `/create`, []interface{}{merchant}},
+		{orgs, operator, `/create`, []interface{}{agent}},
+		{orgs, operator, `/create`, []interface{}{agent2}},
+		{orgs, bank, `/bank/member/confirm`, []interface{}{merchant.OrganizationId, merchant}},
+		{orgs, bank, `/bank/member/confirm`, []interface{}{agent.OrganizationId, agent}},
+		{orgs, bank2, `/bank/member/confirm`, []interface{}{agent2.OrganizationId, agent2}},
+	}
+	for _, step := range steps {
+		if err = checkResponse(step.stub.From(step.from).Invoke(step.fn, step.args...)); err != nil {
+			return nil, fmt.Errorf("seed %s: %s", step.fn, err)
+		}
+	}
+
+	config := entities.Config{Merchant: merchant.OrganizationId, Owner: merchant.OrganizationId, DefaultCurrency: `RUB`}
+	if err = checkResponse(tickets.MockInit("init-tickets", tickets.ArgsToBytes(config))); err != nil {
+		return nil, err
+	}
+
+	for _, a := range []identity{agent, agent2} {
+		if err = checkResponse(tickets.From(merchant).Invoke(`/agent/add`, a.OrganizationId)); err != nil {
+			return nil, fmt.Errorf("seed agent %s: %s", a.OrganizationId, err)
+		}
+	}
+
+	n := &Network{
+		stubs:      map[string]*s7t.FullMockStub{ticketsChaincode: tickets, organizationsChaincode: orgs},
+		identities: make(map[string]string),
+		merchant:   merchant.OrganizationId,
+		roles: auth.OrgRoles{
+			merchant.OrganizationId: auth.RoleMerchant,
+			agent.OrganizationId:    auth.RoleAgent,
+			agent2.OrganizationId:   auth.RoleAgent,
+			bank.OrganizationId:     auth.RoleBank,
+			bank2.OrganizationId:    auth.RoleBank,
+		},
+	}
+	for _, o := range []identity{operator, bank, bank2, merchant, agent, agent2} {
+		n.identities[o.OrganizationId] = o.OrganizationCACert
+	}
+	return n, nil
+}
+
+func checkResponse(r pb.Response) error {
+	if r.Status != shim.OK {
+		return errors.New(r.Message)
+	}
+	return nil
+}
+
+// Identities returns MSP ids available as request identity
+func (n *Network) Identities() []string {
+	var ids []string
+	for id := range n.identities {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Merchant returns MSP id of seeded merchant
+func (n *Network) Merchant() string {
+	return n.merchant
+}
+
+// Backend returns backend executing chaincode functions from presented MSP identity
+func (n *Network) Backend(mspId string) (common.Backend, error) {
+	if _, ok := n.identities[mspId]; !ok {
+		return nil, fmt.Errorf("unknown simulator identity: %s", mspId)
+	}
+	return identityBackend{network: n, mspId: mspId}, nil
+}
+
+// Resolve returns SDK bound to identity from IdentityHeader, merchant identity is used by default
+// Can be used with common.Middleware
+func (n *Network) Resolve(e echo.Context) (*common.PaymentSDK, error) {
+	mspId := e.Request().Header.Get(IdentityHeader)
+	if mspId == `` {
+		mspId = n.merchant
+	}
+
+	b, err := n.Backend(mspId)
+	if err != nil {
+		return nil, err
+	}
+	return common.NewPaymentSDK(b), nil
+}
+
+// Authenticate binds request to seeded organization from IdentityHeader, merchant is used by default
+// Implements auth.Authenticator
+func (n *Network) Authenticate(r *http.Request) (*auth.Principal, error) {
+	mspId := r.Header.Get(IdentityHeader)
+	if mspId == `` {
+		mspId = n.merchant
+	}
+	role, ok := n.roles[mspId]
+	if !ok {
+		return nil, fmt.Errorf("simulator identity has no role: %s", mspId)
+	}
+	return &auth.Principal{Subject: mspId, Org: mspId, Role: role, Method: `simulator`}, nil
+}
+
+func (n *Network) execute(mspId string, chaincodeName string, fn string, args []string) ([]byte, error) {
+	stub, ok := n.stubs[chaincodeName]
+	if !ok {
+		return nil, fmt.Errorf("chaincode not found in simulator: %s", chaincodeName)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.txCounter++
+	stub.MockCreator(mspId, n.identities[mspId])
+
+	invokeArgs := [][]byte{[]byte(fn)}
+	for _, arg := range args {
+		invokeArgs = append(invokeArgs, []byte(arg))
+	}
+
+	response := stub.MockInvoke(`simulator-tx-`+strconv.FormatUint(n.txCounter, 10), invokeArgs)
+	if err := checkResponse(response); err != nil {
+		return nil, err
+	}
+	return response.Payload, nil
+}
+
+type identityBackend struct {
+	network *Network
+	mspId   string
+}
+
+func (b identityBackend) Query(chaincode string, fn string, args []string) ([]byte, error) {
+	return b.network.execute(b.mspId, chaincode, fn, args)
+}
+
+func (b identityBackend) Invoke(chaincode string, fn string, args []string) ([]byte, error) {
+	return b.network.execute(b.mspId, chaincode, fn, args)
+}
@@ -15,5 +15,14 @@ type (
 	RequestUpdateState struct {
 		PaymentId string                `json:"payment_id"`
 		State     entities.PaymentState `json:"state"`
+		// LegId is leg of split payment changed by bank, can be omitted if bank funds only one leg
+		LegId string `json:"leg_id,omitempty"`
+		// Reason is set by bank moving payment to CheckFundsFail or DebitFail
+		Reason string `json:"reason,omitempty"`
+	}
+
+	// RequestReportSign is hash of sales report reviewed by signer
+	RequestReportSign struct {
+		Hash string `json:"hash"`
 	}
 )
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo"
+	"s7ab-platform-hyperledger/platform/core/logger"
+	"s7ab-platform-hyperledger/platform/s7ticket/api/auth"
+	"s7ab-platform-hyperledger/platform/s7ticket/api/common"
+	"s7ab-platform-hyperledger/platform/s7ticket/api/operations"
+	apiEntities "s7ab-platform-hyperledger/platform/s7ticket/api/tickets/entities"
+	"s7ab-platform-hyperledger/platform/s7ticket/entities"
+)
+
+const callbackUrlParam = `callbackUrl`
+
+// Operations runs asynchronous submissions, in-memory store is used by default
+var Operations = operations.NewRunner(operations.NewMemoryStore(0), logger.NewZapLogger(nil))
+
+// submission sends transaction with SDK of caller organization and returns pending approval if it is opened
+type submission func(s *common.PaymentSDK, progress common.ProgressFunc) (*entities.PaymentApprovalEvent, error)
+
+// startOperation runs submit in background and responds with operation in SUBMITTED stage
+// Pooled SDK in-flight slot is held until submission finishes, so background submissions count in pool MaxInFlight
+func startOperation(c echo.Context, kind string, paymentId string, submit submission) error {
+	ctx := c.(common.Context)
+
+	var org string
+	if p := auth.GetPrincipal(c); p != nil {
+		org = p.Org
+	}
+
+	s, release := ctx.SDK, func() {}
+	if ctx.Pool != nil {
+		var err error
+		if s, release, err = ctx.Pool.Acquire(ctx.Identity, ctx.SDK.Channel); err == common.ErrTooManyRequests {
+			return echo.NewHTTPError(http.StatusTooManyRequests, err.Error())
+		} else if err != nil {
+			return echo.NewHTTPError(http.StatusServiceUnavailable, err.Error())
+		}
+	}
+
+	o, err := Operations.Start(kind, paymentId, org, c.QueryParam(callbackUrlParam), func(progress common.ProgressFunc) (*entities.PaymentApprovalEvent, error) {
+		defer release()
+		return submit(s, progress)
+	})
+	if err != nil {
+		release()
+	}
+	if err == operations.ErrInvalidCallbackUrl || err == operations.ErrCallbackHost || err == operations.ErrCallbacksDisabled {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	} else if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusAccepted, o)
+}
+
+// CreateAsyncPaymentHandler
+// Submits payment creation and returns operation without waiting for commit
+func CreateAsyncPaymentHandler(c echo.Context) error {
+	var payload entities.PaymentCreatePayload
+	if err := c.Bind(&payload); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	var agentId string
+	if p := auth.GetPrincipal(c); p != nil {
+		agentId = p.Org
+	}
+
+	return startOperation(c, operations.KindCreatePayment, payload.Id, func(s *common.PaymentSDK, progress common.ProgressFunc) (*entities.PaymentApprovalEvent, error) {
+		return nil, recordLimitBreach(agentId, payload, s.CreatePayment(payload, progress))
+	})
+}
+
+// UpdateAsyncPaymentHandler
+// Submits payment state change and returns operation without waiting for commit
+// Operation reports pending approval if change needs second signer
+func UpdateAsyncPaymentHandler(c echo.Context) error {
+	var request apiEntities.RequestUpdateState
+	if err := c.Bind(&request); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	request.PaymentId = c.Param(`id`)
+
+	return startOperation(c, operations.KindUpdatePayment, request.PaymentId, func(s *common.PaymentSDK, progress common.ProgressFunc) (*entities.PaymentApprovalEvent, error) {
+		return s.UpdatePaymentState(request, progress)
+	})
+}
+
+// GetOperationHandler
+// Returns asynchronous operation, operation is visible only for organization submitted it
+func GetOperationHandler(c echo.Context) error {
+	o, err := Operations.Store.Get(c.Param(`id`))
+	if err == operations.ErrNotFound {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	} else if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	if p := auth.GetPrincipal(c); p == nil || p.Org != o.Org {
+		return echo.NewHTTPError(http.StatusNotFound, operations.ErrNotFound.Error())
+	}
+
+	return c.JSON(http.StatusOK, o)
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo"
+	"s7ab-platform-hyperledger/platform/s7ticket/api/common"
+	"s7ab-platform-hyperledger/platform/s7ticket/entities"
+)
+
+// maxBlocklistImportSize is max size of imported CSV body, larger imports must be split, all entries are added in one transaction
+const maxBlocklistImportSize = 1 << 20
+
+// parseBlocklistCSV reads entries from CSV with columns type, value, reason, expiresAt
+// Reason and expiresAt columns are optional, header row is skipped if it is presented
+func parseBlocklistCSV(r io.Reader) ([]entities.BlocklistEntry, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var entries []entities.BlocklistEntry
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if line == 1 && strings.EqualFold(record[0], `type`) {
+			continue
+		}
+		if len(record) < 2 || len(record) > 4 {
+			return nil, fmt.Errorf("line %d: expected 2 to 4 columns: type, value, reason, expiresAt", line)
+		}
+
+		entry := entities.BlocklistEntry{
+			Type:  entities.BlocklistEntryType(strings.ToUpper(record[0])),
+			Value: record[1],
+		}
+		if len(record) > 2 {
+			entry.Reason = record[2]
+		}
+		if len(record) > 3 {
+			entry.ExpiresAt = record[3]
+		}
+		entries = append(entries, entry)
+	}
+
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("csv has no blocklist entries")
+	}
+	return entries, nil
+}
+
+// ListBlocklistHandler
+// Returns blocklist entries, type query param filters entries of one type
+func ListBlocklistHandler(c echo.Context) error {
+	ctx := c.(common.Context)
+
+	entries, err := ctx.SDK.Blocklist(c.QueryParam(`type`))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, entries)
+}
+
+// AddBlocklistHandler
+// Adds or replaces blocklist entries from json array
+func AddBlocklistHandler(c echo.Context) error {
+	ctx := c.(common.Context)
+
+	var entries []entities.BlocklistEntry
+	if err := c.Bind(&entries); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	added, err := ctx.SDK.BlocklistAdd(entries)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, added)
+}
+
+// ImportBlocklistHandler
+// Adds or replaces blocklist entries from CSV body in one transaction, body is limited by maxBlocklistImportSize
+func ImportBlocklistHandler(c echo.Context) error {
+	ctx := c.(common.Context)
+
+	if c.Request().ContentLength > maxBlocklistImportSize {
+		return echo.NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("csv is larger than %d bytes", maxBlocklistImportSize))
+	}
+
+	entries, err := parseBlocklistCSV(http.MaxBytesReader(c.Response(), c.Request().Body, maxBlocklistImportSize))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	added, err := ctx.SDK.BlocklistAdd(entries)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, added)
+}
+
+// RemoveBlocklistHandler
+// Removes blocklist entry by type and value
+func RemoveBlocklistHandler(c echo.Context) error {
+	ctx := c.(common.Context)
+
+	if err := ctx.SDK.BlocklistRemove(strings.ToUpper(c.Param(`type`)), c.Param(`value`)); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.NoContent(http.StatusOK)
+}
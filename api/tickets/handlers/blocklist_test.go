@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"s7ab-platform-hyperledger/platform/s7ticket/entities"
+)
+
+var _ = Describe("Blocklist", func() {
+	It("Parse CSV with optional header and columns", func() {
+		entries, err := parseBlocklistCSV(strings.NewReader("type,value,reason,expiresAt\n" +
+			"account, 40702810000000000001\n" +
+			"ITN,7700000001,fraud,2030-01-01T00:00:00Z\n"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(Equal([]entities.BlocklistEntry{
+			{Type: entities.BlockAccount, Value: `40702810000000000001`},
+			{Type: entities.BlockItn, Value: `7700000001`, Reason: `fraud`, ExpiresAt: `2030-01-01T00:00:00Z`},
+		}))
+	})
+
+	It("Reject CSV with wrong columns or without entries", func() {
+		_, err := parseBlocklistCSV(strings.NewReader("ACCOUNT\n"))
+		Expect(err).To(MatchError(`line 1: expected 2 to 4 columns: type, value, reason, expiresAt`))
+
+		_, err = parseBlocklistCSV(strings.NewReader("ACCOUNT,1,reason,2030-01-01T00:00:00Z,extra\n"))
+		Expect(err).To(HaveOccurred())
+
+		_, err = parseBlocklistCSV(strings.NewReader("type,value\n"))
+		Expect(err).To(MatchError(`csv has no blocklist entries`))
+
+		_, err = parseBlocklistCSV(strings.NewReader(`ACCOUNT,"1`))
+		Expect(err).To(HaveOccurred())
+	})
+})
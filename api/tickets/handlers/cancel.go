@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo"
+	"s7ab-platform-hyperledger/platform/s7ticket/api/common"
+	"s7ab-platform-hyperledger/platform/s7ticket/entities"
+)
+
+// IssueHandler
+// Records number of ticket issued for debited payment
+func IssueHandler(c echo.Context) error {
+	ctx := c.(common.Context)
+
+	var payload entities.IssuePayload
+	if err := c.Bind(&payload); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	payload.PaymentId = c.Param(`id`)
+
+	if err := ctx.SDK.Issue(payload); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// CancelHandler
+// Cancels payment before debit request, payment moves to TicketCanceled
+func CancelHandler(c echo.Context) error {
+	ctx := c.(common.Context)
+
+	var payload entities.CancelPayload
+	if err := c.Bind(&payload); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	payload.PaymentId = c.Param(`id`)
+
+	if err := ctx.SDK.Cancel(payload); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// VoidHandler
+// Voids issued ticket during void window, payment moves to Refunded
+func VoidHandler(c echo.Context) error {
+	ctx := c.(common.Context)
+
+	var payload entities.CancelPayload
+	if err := c.Bind(&payload); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	payload.PaymentId = c.Param(`id`)
+
+	if err := ctx.SDK.Void(payload); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.NoContent(http.StatusOK)
+}
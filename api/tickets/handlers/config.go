@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo"
+	"s7ab-platform-hyperledger/platform/s7ticket/api/common"
+	"s7ab-platform-hyperledger/platform/s7ticket/entities"
+)
+
+// GetConfigHandler
+// Returns tickets chaincode config
+func GetConfigHandler(c echo.Context) error {
+	ctx := c.(common.Context)
+
+	config, err := ctx.SDK.Config()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, config)
+}
+
+// UpdateConfigHandler
+// Replaces tickets chaincode config, allowed only for config owner
+func UpdateConfigHandler(c echo.Context) error {
+	ctx := c.(common.Context)
+
+	var config entities.Config
+	if err := c.Bind(&config); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := ctx.SDK.UpdateConfig(config); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.NoContent(http.StatusOK)
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo"
+	"s7ab-platform-hyperledger/platform/s7ticket/api/common"
+	"s7ab-platform-hyperledger/platform/s7ticket/entities"
+)
+
+// ListDisputesHandler
+// Returns disputes visible to organization, paymentId query param filters disputes of one payment
+func ListDisputesHandler(c echo.Context) error {
+	ctx := c.(common.Context)
+
+	disputes, err := ctx.SDK.Disputes(c.QueryParam(`paymentId`))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, disputes)
+}
+
+// GetDisputeHandler
+// Returns dispute with evidence hashes and deadlines
+func GetDisputeHandler(c echo.Context) error {
+	ctx := c.(common.Context)
+
+	dispute, err := ctx.SDK.Dispute(c.Param(`id`))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, dispute)
+}
+
+// OpenDisputeHandler
+// Opens dispute of payer bank against debited payment
+func OpenDisputeHandler(c echo.Context) error {
+	ctx := c.(common.Context)
+
+	var payload entities.DisputeOpenPayload
+	if err := c.Bind(&payload); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	payload.PaymentId = c.Param(`id`)
+
+	dispute, err := ctx.SDK.DisputeOpen(payload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusCreated, dispute)
+}
+
+// RespondDisputeHandler
+// Attaches merchant evidence hashes to dispute
+func RespondDisputeHandler(c echo.Context) error {
+	ctx := c.(common.Context)
+
+	var payload entities.DisputeRespondPayload
+	if err := c.Bind(&payload); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	payload.DisputeId = c.Param(`id`)
+
+	if err := ctx.SDK.DisputeRespond(payload); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// ResolveDisputeHandler
+// Accepts dispute with refund or rejects it
+func ResolveDisputeHandler(c echo.Context) error {
+	ctx := c.(common.Context)
+
+	var payload entities.DisputeResolvePayload
+	if err := c.Bind(&payload); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	payload.DisputeId = c.Param(`id`)
+
+	if err := ctx.SDK.DisputeResolve(payload); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.NoContent(http.StatusOK)
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo"
+	"s7ab-platform-hyperledger/platform/s7ticket/api/common"
+	"s7ab-platform-hyperledger/platform/s7ticket/entities"
+)
+
+// HoldConfirmHandler
+// Reserves payment funds until expiry instead of check funds success
+func HoldConfirmHandler(c echo.Context) error {
+	ctx := c.(common.Context)
+
+	var payload entities.HoldConfirmPayload
+	if err := c.Bind(&payload); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	payload.PaymentId = c.Param(`id`)
+
+	if err := ctx.SDK.HoldConfirm(payload); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// HoldCaptureHandler
+// Debits full or partial amount of held funds, capture waiting for second approver is accepted with pending approval
+func HoldCaptureHandler(c echo.Context) error {
+	ctx := c.(common.Context)
+
+	var payload entities.HoldCapturePayload
+	if err := c.Bind(&payload); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	payload.PaymentId = c.Param(`id`)
+
+	approval, err := ctx.SDK.HoldCapture(payload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	if approval != nil {
+		return c.JSON(http.StatusAccepted, approval)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// HoldReleaseHandler
+// Returns held funds to payer, payment moves to HoldReleased
+func HoldReleaseHandler(c echo.Context) error {
+	ctx := c.(common.Context)
+
+	if err := ctx.SDK.HoldRelease(c.Param(`id`)); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.NoContent(http.StatusOK)
+}
@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo"
+	"s7ab-platform-hyperledger/platform/s7ticket/api/common"
+)
+
+// SearchItineraryHandler
+// Returns payments of itinerary with PNR
+func SearchItineraryHandler(c echo.Context) error {
+	ctx := c.(common.Context)
+
+	payments, err := ctx.SDK.PaymentsByPNR(c.Param(`pnr`))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, payments)
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"s7ab-platform-hyperledger/platform/s7ticket/api/common"
+	"s7ab-platform-hyperledger/platform/s7ticket/entities"
+)
+
+// BreachRecorder returns merchant SDK recording limit breaches of rejected agent payments and function to release it
+// Breach is recorded by merchant, so offending agent can't skip it, breaches aren't recorded if it is nil
+var BreachRecorder func() (*common.PaymentSDK, func(), error)
+
+// recordLimitBreach records breach of agent payment rejected by limit, creation error is returned
+// Failure of recording is added to creation error
+func recordLimitBreach(agentId string, payload entities.PaymentCreatePayload, createErr error) error {
+	if createErr == nil || BreachRecorder == nil || !strings.Contains(createErr.Error(), entities.ErrLimitExceeded.Error()) {
+		return createErr
+	}
+
+	s, release, err := BreachRecorder()
+	if err != nil {
+		return fmt.Errorf("%s, limit breach isn't recorded: %s", createErr, err)
+	}
+	defer release()
+
+	if _, err = s.RecordLimitBreach(agentId, payload); err != nil {
+		return fmt.Errorf("%s, limit breach isn't recorded: %s", createErr, err)
+	}
+	return createErr
+}
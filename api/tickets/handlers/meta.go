@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo"
+	"s7ab-platform-hyperledger/platform/s7ticket/api/common"
+	"s7ab-platform-hyperledger/platform/s7ticket/entities"
+)
+
+// GetPaymentMetaHandler
+// Returns typed payment meta
+func GetPaymentMetaHandler(c echo.Context) error {
+	ctx := c.(common.Context)
+
+	meta, err := ctx.SDK.PaymentMeta(c.Param(`id`))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, meta)
+}
+
+// SetPaymentMetaHandler
+// Sets payment meta values, empty value removes key
+func SetPaymentMetaHandler(c echo.Context) error {
+	ctx := c.(common.Context)
+
+	var payload entities.MetaPutPayload
+	if err := c.Bind(&payload); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	payload.PaymentId = c.Param(`id`)
+
+	if err := ctx.SDK.SetPaymentMeta(payload); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// GetMetaSchemaHandler
+// Returns allowed meta keys with size limits and writer roles
+func GetMetaSchemaHandler(c echo.Context) error {
+	ctx := c.(common.Context)
+
+	schema, err := ctx.SDK.MetaSchema()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, schema)
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo"
+	"s7ab-platform-hyperledger/platform/s7ticket/api/common"
+	apiEntities "s7ab-platform-hyperledger/platform/s7ticket/api/tickets/entities"
+)
+
+// UpdatePaymentHandler
+// Changes payment state and waits for commit, change waiting for second approver is accepted with pending approval
+// Second approver must sign with another certificate of organization, so approval is confirmed by another client identity
+func UpdatePaymentHandler(c echo.Context) error {
+	ctx := c.(common.Context)
+
+	var request apiEntities.RequestUpdateState
+	if err := c.Bind(&request); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	request.PaymentId = c.Param(`id`)
+
+	approval, err := ctx.SDK.UpdatePaymentState(request, nil)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	if approval != nil {
+		return c.JSON(http.StatusAccepted, approval)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo"
+	"s7ab-platform-hyperledger/platform/s7ticket/api/common"
+	apiEntities "s7ab-platform-hyperledger/platform/s7ticket/api/tickets/entities"
+	"s7ab-platform-hyperledger/platform/s7ticket/entities"
+)
+
+// hotDate converts RFC3339 time or YYYY-MM-DD date to YYYYMMDD
+func hotDate(date string) string {
+	if len(date) < 10 {
+		return fmt.Sprintf("%-8s", date)
+	}
+	return strings.Replace(date[:10], `-`, ``, -1)
+}
+
+// formatSalesReportHOT writes report as fixed width records named after BSP HOT records:
+// BFH01 file header, BCH02 period header, BKT06 transaction per line, BOT93 totals per currency, BFT99 trailer
+func formatSalesReportHOT(report *entities.SalesReport) []byte {
+	var buf bytes.Buffer
+	records := 0
+	write := func(format string, args ...interface{}) {
+		fmt.Fprintf(&buf, format+"\n", args...)
+		records++
+	}
+
+	write("BFH01%-8s%-20s%-40s", hotDate(report.CreatedAt), report.AgentId, report.Id)
+	write("BCH02%-8s%-8s%-6s", hotDate(report.PeriodStart), hotDate(report.PeriodEnd), report.State)
+
+	for i, line := range report.Lines {
+		write("BKT06%06d%-4s%-15s%-6s%-8s%-3s%011d%011d%011d%011d%011d",
+			i+1, line.TransactionCode, line.TicketNumber, line.PNR, hotDate(line.Date), line.Currency,
+			line.Amount, line.Fare, line.Taxes, line.Commission, line.Remittance)
+	}
+
+	for _, total := range report.Totals {
+		write("BOT93%-3s%06d%06d%06d%015d%015d%+015d%+015d%+015d",
+			total.Currency, total.Issues, total.Exchanges, total.Refunds, total.GrossAmount, total.RefundedAmount,
+			total.NetTaxes, total.NetCommission, total.NetRemittance)
+	}
+
+	write("BFT99%06d%-64s", records+1, report.Hash)
+	return buf.Bytes()
+}
+
+// CreateSalesReportHandler
+// Builds or rebuilds draft sales report of agent period
+func CreateSalesReportHandler(c echo.Context) error {
+	ctx := c.(common.Context)
+
+	var payload entities.SalesReportPayload
+	if err := c.Bind(&payload); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	report, err := ctx.SDK.SalesReportCreate(payload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, report)
+}
+
+// ListSalesReportsHandler
+// Returns sales reports visible to organization, agentId query param filters reports of one agent
+func ListSalesReportsHandler(c echo.Context) error {
+	ctx := c.(common.Context)
+
+	reports, err := ctx.SDK.SalesReports(c.QueryParam(`agentId`))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, reports)
+}
+
+// GetSalesReportHandler
+// Returns sales report as JSON or as HOT-style flat file with format=hot query param
+func GetSalesReportHandler(c echo.Context) error {
+	ctx := c.(common.Context)
+
+	report, err := ctx.SDK.SalesReport(c.Param(`id`))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+
+	if c.QueryParam(`format`) == `hot` {
+		return c.Blob(http.StatusOK, echo.MIMETextPlainCharsetUTF8, formatSalesReportHOT(report))
+	}
+	return c.JSON(http.StatusOK, report)
+}
+
+// SignSalesReportHandler
+// Co-signs sales report hash by merchant or reported agent
+func SignSalesReportHandler(c echo.Context) error {
+	ctx := c.(common.Context)
+
+	var request apiEntities.RequestReportSign
+	if err := c.Bind(&request); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := ctx.SDK.SalesReportSign(c.Param(`id`), request.Hash); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.NoContent(http.StatusOK)
+}
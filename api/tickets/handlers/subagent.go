@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo"
+	"s7ab-platform-hyperledger/platform/s7ticket/api/common"
+	"s7ab-platform-hyperledger/platform/s7ticket/entities"
+)
+
+// ListSubAgentsHandler
+// Returns points of sale of agent, agent sees only own points of sale
+func ListSubAgentsHandler(c echo.Context) error {
+	ctx := c.(common.Context)
+
+	subAgents, err := ctx.SDK.SubAgents(c.Param(`id`))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, subAgents)
+}
+
+// SetSubAgentHandler
+// Adds or replaces point of sale of agent with permissions and limits
+func SetSubAgentHandler(c echo.Context) error {
+	ctx := c.(common.Context)
+
+	var subAgent entities.SubAgent
+	if err := c.Bind(&subAgent); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	subAgent.AgentId = c.Param(`id`)
+
+	saved, err := ctx.SDK.SubAgentSet(subAgent)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, saved)
+}
+
+// ListSubAgentPaymentsHandler
+// Returns payments created by points of sale of agent, subAgentId query param filters payments of one point of sale
+func ListSubAgentPaymentsHandler(c echo.Context) error {
+	ctx := c.(common.Context)
+
+	payments, err := ctx.SDK.SubAgentPayments(c.QueryParam(`subAgentId`))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, payments)
+}
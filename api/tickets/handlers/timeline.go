@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo"
+	"s7ab-platform-hyperledger/platform/s7ticket/api/common"
+)
+
+// GetPaymentTimeline
+// Returns payment history with actor, role and changed fields on every step
+func GetPaymentTimeline(c echo.Context) error {
+	ctx := c.(common.Context)
+
+	timeline, err := ctx.SDK.PaymentTimeline(c.Param(`id`))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, timeline)
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"s7ab-platform-hyperledger/platform/core/logger"
+	"s7ab-platform-hyperledger/platform/s7ticket/api/common"
+	"s7ab-platform-hyperledger/platform/s7ticket/entities"
+)
+
+func TestHandlers(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Handlers Suite")
+}
+
+// backendFunc answers queries and invokes of SDK with one function
+type backendFunc func(fn string, args []string) ([]byte, error)
+
+func (f backendFunc) Query(chaincode string, fn string, args []string) ([]byte, error) {
+	return f(fn, args)
+}
+
+func (f backendFunc) Invoke(chaincode string, fn string, args []string) ([]byte, error) {
+	return f(fn, args)
+}
+
+var _ = Describe("Timeline", func() {
+	It("Return chaincode history of payment", func() {
+		history := []entities.PaymentHistoryEntry{
+			{TxId: `tx1`, Actor: `Org4MSP`, ActorRole: `AGENT`, State: &entities.Payment{Id: `p1`, State: entities.CheckFundsRequest}},
+			{TxId: `tx2`, IsDelete: true, PreviousState: &entities.Payment{Id: `p1`, State: entities.CheckFundsRequest}},
+		}
+		s := common.NewPaymentSDK(backendFunc(func(fn string, args []string) ([]byte, error) {
+			Expect(fn).To(Equal(`/history`))
+			Expect(args).To(Equal([]string{`p1`}))
+			return json.Marshal(history)
+		}))
+
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, `/payment/p1/timeline`, nil), rec)
+		c.SetParamNames(`id`)
+		c.SetParamValues(`p1`)
+		Expect(GetPaymentTimeline(common.NewContext(c, s, logger.NewZapLogger(nil)))).To(Succeed())
+
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		var timeline []entities.PaymentHistoryEntry
+		Expect(json.Unmarshal(rec.Body.Bytes(), &timeline)).To(Succeed())
+		Expect(timeline).To(Equal(history))
+	})
+})
@@ -0,0 +1,1101 @@
+package tickets
+
+// OpenAPISpec is OpenAPI 3 document of tickets REST module
+// Paths are relative to module url path, every route of setRouter must be described here
+const OpenAPISpec = `
+openapi: 3.0.0
+info:
+  title: Tickets payments API
+  version: 1.0.0
+paths:
+  /merchant:
+    get:
+      summary: Merchant of tickets chaincode
+      responses:
+        '200':
+          description: Merchant organization
+          content:
+            application/json:
+              schema: {$ref: '#/components/schemas/Member'}
+        default: {$ref: '#/components/responses/Error'}
+  /agent:
+    get:
+      summary: Agent of current organization
+      responses:
+        '200':
+          description: Agent organization
+          content:
+            application/json:
+              schema: {$ref: '#/components/schemas/Member'}
+        default: {$ref: '#/components/responses/Error'}
+  /agent/list:
+    get:
+      summary: Agents added by merchant
+      responses:
+        '200':
+          description: Agent organizations
+          content:
+            application/json:
+              schema:
+                type: array
+                items: {$ref: '#/components/schemas/Member'}
+        default: {$ref: '#/components/responses/Error'}
+  /agent/add:
+    post:
+      summary: Add agent, allowed only for merchant
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema: {$ref: '#/components/schemas/RequestAgentAdd'}
+      responses:
+        '200': {description: Agent added}
+        default: {$ref: '#/components/responses/Error'}
+  /agent/{id}/subagent:
+    get:
+      summary: Points of sale of agent, agent sees only own points of sale
+      parameters:
+        - {$ref: '#/components/parameters/AgentId'}
+      responses:
+        '200':
+          description: Points of sale
+          content:
+            application/json:
+              schema:
+                type: array
+                items: {$ref: '#/components/schemas/SubAgent'}
+        default: {$ref: '#/components/responses/Error'}
+    post:
+      summary: Add or replace point of sale of agent with permissions and limits, allowed only for merchant
+      parameters:
+        - {$ref: '#/components/parameters/AgentId'}
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema: {$ref: '#/components/schemas/SubAgent'}
+      responses:
+        '200':
+          description: Point of sale
+          content:
+            application/json:
+              schema: {$ref: '#/components/schemas/SubAgent'}
+        default: {$ref: '#/components/responses/Error'}
+  /subagent/payment:
+    get:
+      summary: Payments created by points of sale of agent, point of sale certificate sees only own payments
+      parameters:
+        - {name: subAgentId, in: query, schema: {type: string}}
+      responses:
+        '200':
+          description: Payments
+          content:
+            application/json:
+              schema:
+                type: array
+                items: {$ref: '#/components/schemas/Payment'}
+        default: {$ref: '#/components/responses/Error'}
+  /sync/payment:
+    post:
+      summary: Create payment and wait for commit, allowed only for agent
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema: {$ref: '#/components/schemas/PaymentCreatePayload'}
+      responses:
+        '200':
+          description: Created payment
+          content:
+            application/json:
+              schema: {$ref: '#/components/schemas/ResponseCreatePayment'}
+        default: {$ref: '#/components/responses/Error'}
+  /sync/payment/{id}:
+    post:
+      summary: Change payment state and wait for commit, allowed for agent, bank and merchant releasing payment in RiskReview
+      parameters:
+        - {$ref: '#/components/parameters/PaymentId'}
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema: {$ref: '#/components/schemas/RequestUpdateState'}
+      responses:
+        '200': {description: Payment state changed}
+        '202': {$ref: '#/components/responses/PendingApproval'}
+        default: {$ref: '#/components/responses/Error'}
+  /async/payment:
+    post:
+      summary: Submit payment creation without waiting for commit, allowed only for agent
+      parameters:
+        - {$ref: '#/components/parameters/CallbackUrl'}
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema: {$ref: '#/components/schemas/PaymentCreatePayload'}
+      responses:
+        '202':
+          description: Submitted operation
+          content:
+            application/json:
+              schema: {$ref: '#/components/schemas/Operation'}
+        default: {$ref: '#/components/responses/Error'}
+  /async/payment/{id}:
+    post:
+      summary: Submit payment state change without waiting for commit, allowed for agent, bank and merchant
+      description: Change above approval threshold only opens approval, it is shown in pendingApproval of payment
+      parameters:
+        - {$ref: '#/components/parameters/PaymentId'}
+        - {$ref: '#/components/parameters/CallbackUrl'}
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema: {$ref: '#/components/schemas/RequestUpdateState'}
+      responses:
+        '202':
+          description: Submitted operation
+          content:
+            application/json:
+              schema: {$ref: '#/components/schemas/Operation'}
+        default: {$ref: '#/components/responses/Error'}
+  /operations/{id}:
+    get:
+      summary: Asynchronous operation submitted by current organization
+      parameters:
+        - {name: id, in: path, required: true, schema: {type: string}}
+      responses:
+        '200':
+          description: Operation
+          content:
+            application/json:
+              schema: {$ref: '#/components/schemas/Operation'}
+        default: {$ref: '#/components/responses/Error'}
+  /sync/payment/{id}/hold:
+    post:
+      summary: Hold payment funds until expiry instead of check funds success, allowed only for payer bank
+      parameters:
+        - {$ref: '#/components/parameters/PaymentId'}
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema: {$ref: '#/components/schemas/HoldConfirmPayload'}
+      responses:
+        '200': {description: Funds held}
+        default: {$ref: '#/components/responses/Error'}
+  /sync/payment/{id}/capture:
+    post:
+      summary: Debit full or partial amount of held funds, allowed only for payer agent
+      parameters:
+        - {$ref: '#/components/parameters/PaymentId'}
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema: {$ref: '#/components/schemas/HoldCapturePayload'}
+      responses:
+        '200': {description: 'Hold captured, payment moved to DebitRequest'}
+        '202': {$ref: '#/components/responses/PendingApproval'}
+        default: {$ref: '#/components/responses/Error'}
+  /sync/payment/{id}/release:
+    post:
+      summary: Release held funds, allowed for payer agent, merchant and payer bank after hold expiry
+      parameters:
+        - {$ref: '#/components/parameters/PaymentId'}
+      responses:
+        '200': {description: Hold released}
+        default: {$ref: '#/components/responses/Error'}
+  /sync/payment/{id}/issue:
+    post:
+      summary: Record number of ticket issued for debited payment, allowed only for payer agent
+      parameters:
+        - {$ref: '#/components/parameters/PaymentId'}
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema: {$ref: '#/components/schemas/IssuePayload'}
+      responses:
+        '200': {description: Ticket issued}
+        default: {$ref: '#/components/responses/Error'}
+  /sync/payment/{id}/cancel:
+    post:
+      summary: Cancel payment before debit request, allowed only for payer agent
+      parameters:
+        - {$ref: '#/components/parameters/PaymentId'}
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema: {$ref: '#/components/schemas/CancelPayload'}
+      responses:
+        '200': {description: Payment moved to TicketCanceled}
+        default: {$ref: '#/components/responses/Error'}
+  /sync/payment/{id}/void:
+    post:
+      summary: Void issued ticket during void window with refund, allowed only for merchant
+      parameters:
+        - {$ref: '#/components/parameters/PaymentId'}
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema: {$ref: '#/components/schemas/CancelPayload'}
+      responses:
+        '200': {description: Payment moved to Refunded}
+        default: {$ref: '#/components/responses/Error'}
+  /sync/payment/{id}/dispute:
+    post:
+      summary: Open dispute against debited payment, allowed only for payer bank
+      parameters:
+        - {$ref: '#/components/parameters/PaymentId'}
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema: {$ref: '#/components/schemas/DisputeOpenPayload'}
+      responses:
+        '201':
+          description: Opened dispute
+          content:
+            application/json:
+              schema: {$ref: '#/components/schemas/Dispute'}
+        default: {$ref: '#/components/responses/Error'}
+  /sync/history/{id}:
+    get:
+      summary: Payment history
+      parameters:
+        - {$ref: '#/components/parameters/PaymentId'}
+      responses:
+        '200':
+          description: Payment history entries
+          content:
+            application/json:
+              schema:
+                type: array
+                items: {$ref: '#/components/schemas/PaymentHistoryEntry'}
+        default: {$ref: '#/components/responses/Error'}
+  /payment/{id}/timeline:
+    get:
+      summary: Payment history with changed fields on every step
+      parameters:
+        - {$ref: '#/components/parameters/PaymentId'}
+      responses:
+        '200':
+          description: Payment history entries
+          content:
+            application/json:
+              schema:
+                type: array
+                items: {$ref: '#/components/schemas/PaymentHistoryEntry'}
+        default: {$ref: '#/components/responses/Error'}
+  /itinerary/{pnr}:
+    get:
+      summary: Payments of itinerary with PNR visible to organization
+      parameters:
+        - {name: pnr, in: path, required: true, schema: {type: string}}
+      responses:
+        '200':
+          description: Payments
+          content:
+            application/json:
+              schema:
+                type: array
+                items: {$ref: '#/components/schemas/Payment'}
+        default: {$ref: '#/components/responses/Error'}
+  /payment/{id}/meta:
+    get:
+      summary: Typed payment meta
+      parameters:
+        - {$ref: '#/components/parameters/PaymentId'}
+      responses:
+        '200':
+          description: Payment meta
+          content:
+            application/json:
+              schema: {$ref: '#/components/schemas/PaymentMeta'}
+        default: {$ref: '#/components/responses/Error'}
+    post:
+      summary: Set payment meta values allowed for role by meta schema, empty value removes key
+      parameters:
+        - {$ref: '#/components/parameters/PaymentId'}
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema: {$ref: '#/components/schemas/MetaPutPayload'}
+      responses:
+        '200': {description: Meta updated}
+        default: {$ref: '#/components/responses/Error'}
+  /payment/{id}:
+    get:
+      summary: Payment
+      parameters:
+        - {$ref: '#/components/parameters/PaymentId'}
+      responses:
+        '200':
+          description: Payment
+          content:
+            application/json:
+              schema: {$ref: '#/components/schemas/Payment'}
+        default: {$ref: '#/components/responses/Error'}
+  /payment:
+    get:
+      summary: Payments list
+      parameters:
+        - {name: limit, in: query, schema: {type: integer, minimum: 0}}
+        - {name: offset, in: query, schema: {type: integer, minimum: 0}}
+      responses:
+        '200':
+          description: Payments
+          content:
+            application/json:
+              schema:
+                type: array
+                items: {$ref: '#/components/schemas/Payment'}
+        default: {$ref: '#/components/responses/Error'}
+  /dispute:
+    get:
+      summary: Disputes visible to organization
+      parameters:
+        - {name: paymentId, in: query, schema: {type: string}}
+      responses:
+        '200':
+          description: Disputes
+          content:
+            application/json:
+              schema:
+                type: array
+                items: {$ref: '#/components/schemas/Dispute'}
+        default: {$ref: '#/components/responses/Error'}
+  /dispute/{id}:
+    get:
+      summary: Dispute with evidence hashes and deadlines
+      parameters:
+        - {$ref: '#/components/parameters/DisputeId'}
+      responses:
+        '200':
+          description: Dispute
+          content:
+            application/json:
+              schema: {$ref: '#/components/schemas/Dispute'}
+        default: {$ref: '#/components/responses/Error'}
+  /dispute/{id}/respond:
+    post:
+      summary: Respond to dispute with evidence hashes until response deadline, allowed only for merchant
+      parameters:
+        - {$ref: '#/components/parameters/DisputeId'}
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema: {$ref: '#/components/schemas/DisputeRespondPayload'}
+      responses:
+        '200': {description: Dispute moved to RESPONDED}
+        default: {$ref: '#/components/responses/Error'}
+  /dispute/{id}/resolve:
+    post:
+      summary: Accept dispute with refund or reject it, allowed for payer bank and merchant
+      parameters:
+        - {$ref: '#/components/parameters/DisputeId'}
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema: {$ref: '#/components/schemas/DisputeResolvePayload'}
+      responses:
+        '200': {description: Dispute resolved}
+        default: {$ref: '#/components/responses/Error'}
+  /report:
+    get:
+      summary: Sales reports visible to organization, agent sees only own reports
+      parameters:
+        - {name: agentId, in: query, schema: {type: string}}
+      responses:
+        '200':
+          description: Sales reports
+          content:
+            application/json:
+              schema:
+                type: array
+                items: {$ref: '#/components/schemas/SalesReport'}
+        default: {$ref: '#/components/responses/Error'}
+    post:
+      summary: Build or rebuild draft sales report of agent period, allowed only for merchant
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema: {$ref: '#/components/schemas/SalesReportPayload'}
+      responses:
+        '200':
+          description: Draft sales report
+          content:
+            application/json:
+              schema: {$ref: '#/components/schemas/SalesReport'}
+        default: {$ref: '#/components/responses/Error'}
+  /report/{id}:
+    get:
+      summary: Sales report as JSON or as HOT-style fixed width file with format=hot
+      parameters:
+        - {$ref: '#/components/parameters/ReportId'}
+        - {name: format, in: query, schema: {type: string, enum: [json, hot]}}
+      responses:
+        '200':
+          description: Sales report
+          content:
+            application/json:
+              schema: {$ref: '#/components/schemas/SalesReport'}
+            text/plain:
+              schema: {type: string}
+        default: {$ref: '#/components/responses/Error'}
+  /report/{id}/sign:
+    post:
+      summary: Co-sign sales report hash, report signed by merchant and agent closes period
+      parameters:
+        - {$ref: '#/components/parameters/ReportId'}
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema: {$ref: '#/components/schemas/RequestReportSign'}
+      responses:
+        '200': {description: Report signed}
+        default: {$ref: '#/components/responses/Error'}
+  /blocklist:
+    get:
+      summary: Blocklist entries, allowed for merchant and bank
+      parameters:
+        - {name: type, in: query, schema: {$ref: '#/components/schemas/BlocklistEntryType'}}
+      responses:
+        '200':
+          description: Blocklist entries
+          content:
+            application/json:
+              schema:
+                type: array
+                items: {$ref: '#/components/schemas/BlocklistEntry'}
+        default: {$ref: '#/components/responses/Error'}
+    post:
+      summary: Add or replace blocklist entries, allowed for merchant and bank
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              type: array
+              minItems: 1
+              items: {$ref: '#/components/schemas/BlocklistEntry'}
+      responses:
+        '200':
+          description: Added entries
+          content:
+            application/json:
+              schema:
+                type: array
+                items: {$ref: '#/components/schemas/BlocklistEntry'}
+        default: {$ref: '#/components/responses/Error'}
+  /blocklist/import:
+    post:
+      summary: Add or replace blocklist entries from CSV, allowed for merchant and bank
+      description: Columns are type, value, reason and expiresAt, last two are optional, header row is skipped. Body is limited to 1 MiB
+      requestBody:
+        required: true
+        content:
+          text/plain:
+            schema: {type: string}
+      responses:
+        '200':
+          description: Added entries
+          content:
+            application/json:
+              schema:
+                type: array
+                items: {$ref: '#/components/schemas/BlocklistEntry'}
+        '413': {$ref: '#/components/responses/Error'}
+        default: {$ref: '#/components/responses/Error'}
+  /blocklist/{type}/{value}:
+    delete:
+      summary: Remove blocklist entry, allowed for merchant and bank added it
+      parameters:
+        - {name: type, in: path, required: true, schema: {type: string}}
+        - {name: value, in: path, required: true, schema: {type: string}}
+      responses:
+        '200': {description: Entry removed}
+        default: {$ref: '#/components/responses/Error'}
+  /system/meta/schema:
+    get:
+      summary: Allowed payment meta keys with size limits and writer roles
+      responses:
+        '200':
+          description: Meta schema
+          content:
+            application/json:
+              schema:
+                type: array
+                items: {$ref: '#/components/schemas/MetaField'}
+        default: {$ref: '#/components/responses/Error'}
+  /system/config:
+    get:
+      summary: Tickets chaincode config
+      responses:
+        '200':
+          description: Config
+          content:
+            application/json:
+              schema: {$ref: '#/components/schemas/Config'}
+        default: {$ref: '#/components/responses/Error'}
+    post:
+      summary: Replace tickets chaincode config, allowed only for config owner
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema: {$ref: '#/components/schemas/Config'}
+      responses:
+        '200': {description: Config updated}
+        default: {$ref: '#/components/responses/Error'}
+components:
+  parameters:
+    PaymentId:
+      name: id
+      in: path
+      required: true
+      schema: {type: string}
+    DisputeId:
+      name: id
+      in: path
+      required: true
+      schema: {type: string}
+    AgentId:
+      name: id
+      in: path
+      required: true
+      schema: {type: string}
+    ReportId:
+      name: id
+      in: path
+      required: true
+      schema: {type: string}
+    CallbackUrl:
+      name: callbackUrl
+      in: query
+      description: Finished operation is posted to this http or https url of allowed host, body is signed in X-Tickets-Signature header
+      schema: {type: string, format: uri}
+  responses:
+    Error:
+      description: Error
+      content:
+        application/json:
+          schema:
+            type: object
+            properties:
+              message: {type: string}
+    PendingApproval:
+      description: >-
+        Change is above approval threshold and waits for second approver. Second approver must sign with another
+        certificate of the same organization, API signs with one client identity of organization, so second approval
+        is submitted with another client identity, repeating request through API is rejected
+      content:
+        application/json:
+          schema: {$ref: '#/components/schemas/PaymentApprovalEvent'}
+  schemas:
+    PaymentState:
+      type: string
+      enum: [CheckFundsRequest, CheckFundsInProgress, CheckFundsSuccess, CheckFundsFail, TicketIssuanceTimeout,
+        DebitRequest, DebitInProgress, DebitSuccess, DebitFail, TicketCanceled, Refunded, RiskReview, RiskRejected,
+        FundsHeld, HoldReleased]
+    Member:
+      type: object
+      properties:
+        organizationId: {type: string}
+    RequestAgentAdd:
+      type: object
+      required: [agent_id]
+      properties:
+        agent_id: {type: string}
+    RequestUpdateState:
+      type: object
+      required: [state]
+      properties:
+        payment_id: {type: string}
+        state: {$ref: '#/components/schemas/PaymentState'}
+        leg_id: {type: string}
+        reason: {type: string}
+    ResponseCreatePayment:
+      type: object
+      properties:
+        id: {type: string}
+        state: {type: string}
+    PaymentCreatePayload:
+      type: object
+      required: [paymentId, amount, payerNumber, payerAccount, recipientNumber, recipientAccount]
+      properties:
+        paymentId: {type: string}
+        agent_id: {type: string}
+        amount: {type: integer, minimum: 1}
+        currency: {type: string}
+        internationalFlight:
+          type: boolean
+          description: ignored, internationalFlight of payment is derived from itinerary
+        paymentType: {type: string}
+        payerId: {type: string}
+        payerAccount: {type: string}
+        payerNumber: {type: string}
+        recipientId: {type: string}
+        recipientAccount: {type: string}
+        recipientNumber: {type: string}
+        vat: {type: boolean}
+        legs:
+          type: array
+          nullable: true
+          items: {$ref: '#/components/schemas/PaymentLegPayload'}
+        itinerary:
+          allOf: [{$ref: '#/components/schemas/Itinerary'}]
+          nullable: true
+          description: internationalFlight is derived from segment countries, payment without itinerary is domestic
+    Passenger:
+      type: object
+      required: [lastName, firstName, type]
+      properties:
+        lastName: {type: string, pattern: '^[A-Z][A-Z -]*$'}
+        firstName: {type: string, pattern: '^[A-Z][A-Z -]*$'}
+        type: {type: string, enum: [ADT, CHD, INF]}
+    FlightSegment:
+      type: object
+      required: [carrier, flightNumber, origin, originCountry, destination, destinationCountry, departureDate]
+      properties:
+        carrier: {type: string, pattern: '^([A-Z][A-Z0-9]|[0-9][A-Z])$'}
+        flightNumber: {type: string, pattern: '^[0-9]{1,4}[A-Z]?$'}
+        origin: {type: string, pattern: '^[A-Z]{3}$'}
+        originCountry: {type: string, pattern: '^[A-Z]{2}$'}
+        destination: {type: string, pattern: '^[A-Z]{3}$'}
+        destinationCountry: {type: string, pattern: '^[A-Z]{2}$'}
+        departureDate: {type: string, format: date}
+    FareTax:
+      type: object
+      required: [code, amount]
+      properties:
+        code: {type: string, pattern: '^[A-Z0-9]{2}$'}
+        amount: {type: integer, minimum: 0}
+    Fare:
+      type: object
+      properties:
+        baseFare: {type: integer, minimum: 0}
+        taxes:
+          type: array
+          nullable: true
+          items: {$ref: '#/components/schemas/FareTax'}
+    Itinerary:
+      type: object
+      required: [pnr, passengers, segments, fare]
+      properties:
+        pnr: {type: string, pattern: '^[A-Z0-9]{6}$'}
+        passengers:
+          type: array
+          minItems: 1
+          items: {$ref: '#/components/schemas/Passenger'}
+        segments:
+          type: array
+          minItems: 1
+          items: {$ref: '#/components/schemas/FlightSegment'}
+        fare: {$ref: '#/components/schemas/Fare'}
+    PaymentLegPayload:
+      type: object
+      required: [bankOrgId, account, amount]
+      properties:
+        bankOrgId: {type: string}
+        account:
+          type: string
+          description: agent settlement account at leg bank, account other than agent primary one is confirmed by leg bank
+        amount: {type: integer, minimum: 1}
+    PaymentLeg:
+      type: object
+      properties:
+        id: {type: string}
+        bankOrgId: {type: string}
+        account: {type: string}
+        amount: {type: integer}
+        state: {$ref: '#/components/schemas/PaymentState'}
+    PaymentHold:
+      type: object
+      properties:
+        bankOrgId: {type: string}
+        amount: {type: integer}
+        expiresAt: {type: string}
+        confirmedAt: {type: string}
+        captured: {type: integer}
+        capturedAt: {type: string}
+        releasedBy: {type: string}
+        releasedAt: {type: string}
+    HoldConfirmPayload:
+      type: object
+      required: [expiresAt]
+      properties:
+        paymentId: {type: string}
+        expiresAt: {type: string, format: date-time}
+    HoldCapturePayload:
+      type: object
+      properties:
+        paymentId: {type: string}
+        amount: {type: integer, minimum: 0, description: 'Captured amount, full hold is captured if it is 0'}
+    IssuePayload:
+      type: object
+      required: [ticketNumber]
+      properties:
+        paymentId: {type: string}
+        ticketNumber: {type: string}
+    CancelPayload:
+      type: object
+      properties:
+        paymentId: {type: string}
+        reason: {type: string}
+    DisputeState:
+      type: string
+      enum: [OPENED, RESPONDED, ACCEPTED, REJECTED]
+    DisputeEvidence:
+      type: object
+      required: [hash]
+      properties:
+        hash: {type: string, pattern: '^[0-9a-fA-F]{64}$', description: Hex encoded sha256 of evidence document}
+        description: {type: string}
+    Dispute:
+      type: object
+      properties:
+        disputeId: {type: string}
+        paymentId: {type: string}
+        payerOrgId: {type: string}
+        bankOrgId: {type: string}
+        state: {$ref: '#/components/schemas/DisputeState'}
+        amount:
+          type: integer
+          description: amount funded by bank which opened dispute, accepted dispute refunds only legs of this bank
+        currency: {type: string}
+        reason: {type: string}
+        evidence:
+          type: array
+          nullable: true
+          items: {$ref: '#/components/schemas/DisputeEvidence'}
+        merchantReply: {type: string}
+        resolution: {type: string}
+        openedAt: {type: string}
+        respondBy: {type: string}
+        respondedAt: {type: string}
+        resolveBy: {type: string}
+        resolvedAt: {type: string}
+        resolvedBy: {type: string}
+        resolvedByRole: {type: string}
+    DisputeOpenPayload:
+      type: object
+      properties:
+        paymentId: {type: string}
+        reason: {type: string}
+    DisputeRespondPayload:
+      type: object
+      required: [evidence]
+      properties:
+        disputeId: {type: string}
+        evidence:
+          type: array
+          minItems: 1
+          items: {$ref: '#/components/schemas/DisputeEvidence'}
+        reply: {type: string}
+    DisputeResolvePayload:
+      type: object
+      required: [state]
+      properties:
+        disputeId: {type: string}
+        state: {type: string, enum: [ACCEPTED, REJECTED]}
+        resolution: {type: string}
+    SalesReportState:
+      type: string
+      enum: [DRAFT, CLOSED]
+    SalesReportLine:
+      type: object
+      properties:
+        paymentId: {type: string}
+        ticketNumber: {type: string}
+        pnr: {type: string}
+        transactionCode: {type: string, enum: [TKTT, EXCH, RFND]}
+        date: {type: string}
+        currency: {type: string}
+        amount: {type: integer}
+        fare: {type: integer}
+        taxes: {type: integer}
+        commission: {type: integer}
+        remittance: {type: integer}
+    SalesReportTotal:
+      type: object
+      properties:
+        currency: {type: string}
+        issues: {type: integer}
+        exchanges: {type: integer}
+        refunds: {type: integer}
+        grossAmount: {type: integer}
+        refundedAmount: {type: integer}
+        netTaxes: {type: integer}
+        netCommission: {type: integer}
+        netRemittance: {type: integer}
+    SalesReportSignature:
+      type: object
+      properties:
+        orgId: {type: string}
+        role: {type: string, enum: [AGENT, MERCHANT]}
+        hash: {type: string}
+        signedAt: {type: string}
+    SalesReport:
+      type: object
+      properties:
+        reportId: {type: string}
+        agentId: {type: string}
+        periodStart: {type: string, format: date}
+        periodEnd: {type: string, format: date}
+        state: {$ref: '#/components/schemas/SalesReportState'}
+        lines:
+          type: array
+          nullable: true
+          items: {$ref: '#/components/schemas/SalesReportLine'}
+        totals:
+          type: array
+          nullable: true
+          items: {$ref: '#/components/schemas/SalesReportTotal'}
+        hash: {type: string}
+        signatures:
+          type: array
+          nullable: true
+          items: {$ref: '#/components/schemas/SalesReportSignature'}
+        createdAt: {type: string}
+        closedAt: {type: string}
+    SalesReportPayload:
+      type: object
+      required: [agentId, periodStart, periodEnd]
+      properties:
+        agentId: {type: string}
+        periodStart: {type: string, format: date}
+        periodEnd: {type: string, format: date, description: Exclusive end of period}
+    RequestReportSign:
+      type: object
+      required: [hash]
+      properties:
+        hash: {type: string, pattern: '^[0-9a-f]{64}$'}
+    PaymentApproval:
+      type: object
+      properties:
+        state: {$ref: '#/components/schemas/PaymentState'}
+        legId: {type: string, description: Leg of split payment changed by bank, approval is not set for whole payment}
+        amount: {type: integer, description: Payment or leg amount approved by first signer}
+        role: {type: string, enum: [AGENT, BANK]}
+        orgId: {type: string}
+        firstSigner: {type: string}
+        secondSigner: {type: string}
+        requestedAt: {type: string}
+        expiresAt: {type: string}
+        approvedAt: {type: string}
+    PaymentApprovalEvent:
+      type: object
+      properties:
+        paymentId: {type: string}
+        approval: {$ref: '#/components/schemas/PaymentApproval'}
+    AgentLimit:
+      type: object
+      properties:
+        windowSeconds: {type: integer, minimum: 0}
+        maxCount: {type: integer}
+        maxAmount: {type: integer}
+    SubAgent:
+      type: object
+      required: [subAgentId]
+      properties:
+        subAgentId: {type: string, minLength: 1}
+        agentId: {type: string}
+        name: {type: string}
+        permissions:
+          type: array
+          nullable: true
+          items: {type: string, enum: [CREATE, ISSUE, CANCEL]}
+        maxAmount: {type: integer, description: 'Max amount of single payment, no limit if zero'}
+        limit: {$ref: '#/components/schemas/AgentLimit'}
+        disabled: {type: boolean}
+        updatedAt: {type: string}
+    MetaField:
+      type: object
+      properties:
+        key: {type: string}
+        description: {type: string}
+        maxSize: {type: integer}
+        pattern: {type: string}
+        writers:
+          type: array
+          items: {type: string, enum: [AGENT, MERCHANT, BANK]}
+    PaymentMeta:
+      type: object
+      properties:
+        pnr: {type: string}
+        passengerName: {type: string}
+        flight: {type: string}
+        route: {type: string}
+    MetaPutPayload:
+      type: object
+      required: [values]
+      properties:
+        paymentId: {type: string}
+        values:
+          type: object
+          minProperties: 1
+          additionalProperties: {type: string}
+    Payment:
+      type: object
+      properties:
+        schemaVersion: {type: integer}
+        paymentId: {type: string}
+        ticket_number: {type: string}
+        state: {$ref: '#/components/schemas/PaymentState'}
+        amount: {type: integer}
+        currency: {type: string}
+        internationalFlight: {type: boolean}
+        paymentType: {type: string}
+        vat: {type: boolean}
+        purpose: {type: string}
+        meta:
+          type: object
+          nullable: true
+          additionalProperties: {type: string, format: byte}
+        feeScheduleVersion: {type: integer}
+        feeRuleId: {type: string}
+        agentCommission: {type: integer}
+        merchantFee: {type: integer}
+        netAmount: {type: integer}
+        payerOrgId: {type: string}
+        payerBankOrgId: {type: string}
+        payerId: {type: string}
+        payerAccount: {type: string}
+        payerNumber: {type: string}
+        recipientOrgId: {type: string}
+        recipientBankOrgId: {type: string}
+        recipientId: {type: string}
+        recipientAccount: {type: string}
+        recipientNumber: {type: string}
+        retryCount: {type: integer}
+        lastFailureReason: {type: string}
+        issuedAt: {type: string}
+        cancelReason: {type: string, enum: ['', DEBIT_FAIL, AGENT_CANCEL, MERCHANT_VOID, CHARGEBACK]}
+        canceledAt: {type: string}
+        disputeId: {type: string}
+        disputeState:
+          type: string
+          enum: ['', OPENED, RESPONDED, ACCEPTED, REJECTED]
+        hold:
+          allOf: [{$ref: '#/components/schemas/PaymentHold'}]
+          nullable: true
+        legs:
+          type: array
+          nullable: true
+          items: {$ref: '#/components/schemas/PaymentLeg'}
+        itinerary:
+          allOf: [{$ref: '#/components/schemas/Itinerary'}]
+          nullable: true
+        settlementBatchId: {type: string}
+        refundSettlementBatchId: {type: string}
+        settled: {type: boolean}
+        salesReportId: {type: string}
+        refundReportId: {type: string}
+        riskRulesVersion: {type: integer}
+        riskFlags:
+          type: array
+          nullable: true
+          items: {type: string}
+        pendingApproval:
+          allOf: [{$ref: '#/components/schemas/PaymentApproval'}]
+          nullable: true
+        approvals:
+          type: array
+          nullable: true
+          items: {$ref: '#/components/schemas/PaymentApproval'}
+        subAgentId: {type: string}
+        updatedBy: {type: string}
+        updatedByRole: {type: string}
+    PaymentFieldChange:
+      type: object
+      properties:
+        field: {type: string}
+        from: {}
+        to: {}
+    PaymentHistoryEntry:
+      type: object
+      properties:
+        txId: {type: string}
+        timestamp: {type: string, format: date-time}
+        isDelete: {type: boolean}
+        actor: {type: string}
+        actorRole: {type: string}
+        previousState:
+          allOf: [{$ref: '#/components/schemas/Payment'}]
+          nullable: true
+        state:
+          allOf: [{$ref: '#/components/schemas/Payment'}]
+          nullable: true
+        changes:
+          type: array
+          nullable: true
+          items: {$ref: '#/components/schemas/PaymentFieldChange'}
+    Operation:
+      type: object
+      properties:
+        id: {type: string}
+        kind: {type: string, enum: [CREATE_PAYMENT, UPDATE_PAYMENT]}
+        paymentId: {type: string}
+        stage: {type: string, enum: [SUBMITTED, INVOKING, COMMITTED, FAILED], description: 'INVOKING lasts from send to network until commit or failure, endorsement and ordering are not reported separately'}
+        error: {type: string}
+        approval:
+          allOf: [{$ref: '#/components/schemas/PaymentApprovalEvent'}]
+          description: 'Set when committed change only opened four-eyes approval, payment state is changed after second signer confirms it'
+        callbackUrl: {type: string}
+        org: {type: string}
+        createdAt: {type: string, format: date-time}
+        updatedAt: {type: string, format: date-time}
+    BlocklistEntryType:
+      type: string
+      enum: [ACCOUNT, ITN, PAYER_ID]
+    BlocklistEntry:
+      type: object
+      required: [type, value]
+      properties:
+        type: {$ref: '#/components/schemas/BlocklistEntryType'}
+        value: {type: string, minLength: 1}
+        reason: {type: string}
+        expiresAt: {type: string, description: 'RFC3339 time, entry never expires if empty'}
+        addedBy: {type: string}
+        addedByRole: {type: string}
+        addedAt: {type: string}
+    Config:
+      type: object
+      required: [merchant, defaultCurrency]
+      properties:
+        merchant: {type: string}
+        owner: {type: string}
+        defaultCurrency: {type: string}
+        issuanceTimeout: {type: integer, minimum: 0}
+        minPaymentAmount: {type: integer, minimum: 0}
+        maxPaymentAmount: {type: integer, minimum: 0}
+        maxRetries: {type: integer, minimum: 0, description: 'Agent retries after failed check or debit, 0 disables retries, 3 if omitted'}
+        voidWindow: {type: integer, minimum: 0, description: 'Seconds after issuance when merchant can void ticket, 0 disables voids, 86400 if omitted'}
+        disputeResponseWindow: {type: integer, minimum: 0, description: Seconds given to merchant to respond to dispute}
+        disputeResolutionWindow: {type: integer, minimum: 0, description: Seconds given to payer bank to resolve answered dispute}
+        enforceAttributes: {type: boolean, description: 'Reject changes from certificates without tickets.role attribute, otherwise such certificates have admin access'}
+        stateAccess:
+          type: object
+          nullable: true
+          description: Certificate access level required to change payment to state, states not listed need operator access
+          additionalProperties: {type: string, enum: [viewer, operator, admin]}
+        approvalThresholds:
+          type: object
+          nullable: true
+          description: Payment amount per role above which DebitRequest of agent and DebitSuccess of bank need two approvers
+          properties:
+            AGENT: {type: integer, minimum: 0}
+            BANK: {type: integer, minimum: 0}
+          additionalProperties: false
+        approvalTimeout: {type: integer, minimum: 0, description: Seconds given to second approver}
+`
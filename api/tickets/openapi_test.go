@@ -0,0 +1,213 @@
+package tickets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"s7ab-platform-hyperledger/platform/core/logger"
+	"s7ab-platform-hyperledger/platform/s7ticket/api/auth"
+	"s7ab-platform-hyperledger/platform/s7ticket/api/common"
+	"s7ab-platform-hyperledger/platform/s7ticket/api/openapi"
+	"s7ab-platform-hyperledger/platform/s7ticket/api/operations"
+	apiEntities "s7ab-platform-hyperledger/platform/s7ticket/api/tickets/entities"
+	"s7ab-platform-hyperledger/platform/s7ticket/entities"
+)
+
+func TestTicketsAPI(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Tickets API Suite")
+}
+
+// jsonFields returns json names of struct fields, fields of embedded structs are included
+func jsonFields(t reflect.Type) (fields []string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous {
+			fields = append(fields, jsonFields(f.Type)...)
+			continue
+		}
+		name := strings.Split(f.Tag.Get(`json`), `,`)[0]
+		if name != `` && name != `-` {
+			fields = append(fields, name)
+		}
+	}
+	sort.Strings(fields)
+	return
+}
+
+// principalAuthenticator authenticates every request as principal
+type principalAuthenticator auth.Principal
+
+func (p principalAuthenticator) Authenticate(r *http.Request) (*auth.Principal, error) {
+	principal := auth.Principal(p)
+	return &principal, nil
+}
+
+// fakeBackend answers chaincode functions with stored payloads
+type fakeBackend map[string]interface{}
+
+func (b fakeBackend) Query(chaincode string, fn string, args []string) ([]byte, error) {
+	payload, ok := b[fn]
+	if !ok {
+		return nil, errors.New(`function not found: ` + fn)
+	}
+	return json.Marshal(payload)
+}
+
+func (b fakeBackend) Invoke(chaincode string, fn string, args []string) ([]byte, error) {
+	return b.Query(chaincode, fn, args)
+}
+
+var _ = Describe("OpenAPI", func() {
+
+	var validator *openapi.Validator
+
+	BeforeEach(func() {
+		var err error
+		validator, err = openapi.Load([]byte(OpenAPISpec), DefaultUrlPath)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("Document is valid", func() {
+		Expect(validator.Swagger.Validate(context.Background())).To(Succeed())
+	})
+
+	It("Describes every route and only existing routes", func() {
+		e := echo.New()
+		NewModule(e, DefaultUrlPath)
+
+		routes := map[string]bool{}
+		for _, r := range e.Routes() {
+			path := strings.TrimPrefix(r.Path, DefaultUrlPath)
+			if !strings.HasPrefix(r.Path, DefaultUrlPath+`/`) || path == `/openapi.yaml` || strings.HasSuffix(path, `*`) {
+				continue
+			}
+			segments := strings.Split(path, `/`)
+			for i, s := range segments {
+				if strings.HasPrefix(s, `:`) {
+					segments[i] = `{` + s[1:] + `}`
+				}
+			}
+			routes[r.Method+` `+strings.Join(segments, `/`)] = true
+		}
+
+		documented := map[string]bool{}
+		for path, item := range validator.Swagger.Paths {
+			for method := range item.Operations() {
+				documented[method+` `+path] = true
+			}
+		}
+
+		Expect(documented).To(Equal(routes))
+	})
+
+	It("Serve handlers responses matching document and reject invalid requests", func() {
+		retries, voidWindow := uint(3), uint(86400)
+		backend := fakeBackend{
+			`/config/get`: entities.Config{Merchant: `Org3MSP`, Owner: `Org3MSP`, DefaultCurrency: `RUB`,
+				MaxRetries: &retries, VoidWindow: &voidWindow},
+			`/history`: []entities.PaymentHistoryEntry{
+				{TxId: `tx1`, Timestamp: `2030-01-01T00:00:00Z`, Actor: `Org4MSP`, ActorRole: auth.RoleAgent,
+					State: &entities.Payment{Id: `p1`, State: entities.CheckFundsRequest}},
+			},
+			`/config/update`: nil,
+		}
+
+		e := echo.New()
+		merchant := principalAuthenticator{Subject: `merchant`, Org: `Org3MSP`, Role: auth.RoleMerchant, Method: `test`}
+		resolve := func(echo.Context) (*common.PaymentSDK, error) { return common.NewPaymentSDK(backend), nil }
+		setRouter(e.Group(DefaultUrlPath, auth.Middleware(merchant), common.Middleware(resolve, logger.NewZapLogger(nil)),
+			validator.Middleware(true)))
+
+		serve := func(method string, path string, body string) *httptest.ResponseRecorder {
+			r := httptest.NewRequest(method, DefaultUrlPath+path, strings.NewReader(body))
+			if body != `` {
+				r.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, r)
+			return rec
+		}
+
+		Expect(serve(http.MethodGet, `/system/config`, ``).Code).To(Equal(http.StatusOK))
+		Expect(serve(http.MethodGet, `/payment/p1/timeline`, ``).Code).To(Equal(http.StatusOK))
+		Expect(serve(http.MethodPost, `/system/config`, `{"merchant":"Org3MSP","defaultCurrency":"RUB"}`).Code).To(Equal(http.StatusOK))
+
+		Expect(serve(http.MethodPost, `/system/config`, `{"merchant":"Org3MSP"}`).Code).To(Equal(http.StatusBadRequest))
+		Expect(serve(http.MethodPost, `/system/config`, `{"merchant":"`+strings.Repeat(`M`, openapi.DefaultMaxBodySize)+`"}`).Code).
+			To(Equal(http.StatusRequestEntityTooLarge))
+
+		// unknown payment state doesn't match document, strict validation replaces response
+		backend[`/history`] = []entities.PaymentHistoryEntry{{TxId: `tx2`, State: &entities.Payment{Id: `p1`, State: `Unknown`}}}
+		Expect(serve(http.MethodGet, `/payment/p1/timeline`, ``).Code).To(Equal(http.StatusInternalServerError))
+	})
+
+	It("Schemas match entities", func() {
+		schemas := map[string]interface{}{
+			`BlocklistEntry`:        entities.BlocklistEntry{},
+			`Operation`:             operations.Operation{},
+			`Payment`:               entities.Payment{},
+			`PaymentCreatePayload`:  entities.PaymentCreatePayload{},
+			`PaymentLegPayload`:     entities.PaymentLegPayload{},
+			`PaymentLeg`:            entities.PaymentLeg{},
+			`PaymentHold`:           entities.PaymentHold{},
+			`HoldConfirmPayload`:    entities.HoldConfirmPayload{},
+			`HoldCapturePayload`:    entities.HoldCapturePayload{},
+			`IssuePayload`:          entities.IssuePayload{},
+			`CancelPayload`:         entities.CancelPayload{},
+			`Dispute`:               entities.Dispute{},
+			`DisputeEvidence`:       entities.DisputeEvidence{},
+			`DisputeOpenPayload`:    entities.DisputeOpenPayload{},
+			`DisputeRespondPayload`: entities.DisputeRespondPayload{},
+			`DisputeResolvePayload`: entities.DisputeResolvePayload{},
+			`SalesReport`:           entities.SalesReport{},
+			`SalesReportLine`:       entities.SalesReportLine{},
+			`SalesReportTotal`:      entities.SalesReportTotal{},
+			`SalesReportSignature`:  entities.SalesReportSignature{},
+			`SalesReportPayload`:    entities.SalesReportPayload{},
+			`PaymentApproval`:       entities.PaymentApproval{},
+			`PaymentApprovalEvent`:  entities.PaymentApprovalEvent{},
+			`AgentLimit`:            entities.AgentLimit{},
+			`SubAgent`:              entities.SubAgent{},
+			`MetaField`:             entities.MetaField{},
+			`PaymentMeta`:           entities.PaymentMeta{},
+			`MetaPutPayload`:        entities.MetaPutPayload{},
+			`Itinerary`:             entities.Itinerary{},
+			`Passenger`:             entities.Passenger{},
+			`FlightSegment`:         entities.FlightSegment{},
+			`Fare`:                  entities.Fare{},
+			`FareTax`:               entities.FareTax{},
+			`PaymentHistoryEntry`:   entities.PaymentHistoryEntry{},
+			`PaymentFieldChange`:    entities.PaymentFieldChange{},
+			`Config`:                entities.Config{},
+			`RequestAgentAdd`:       apiEntities.RequestAgentAdd{},
+			`RequestUpdateState`:    apiEntities.RequestUpdateState{},
+			`RequestReportSign`:     apiEntities.RequestReportSign{},
+			`ResponseCreatePayment`: apiEntities.ResponseCreatePayment{},
+		}
+
+		for name, entity := range schemas {
+			schema, ok := validator.Swagger.Components.Schemas[name]
+			Expect(ok).To(BeTrue(), name)
+
+			var properties []string
+			for property := range schema.Value.Properties {
+				properties = append(properties, property)
+			}
+			sort.Strings(properties)
+
+			Expect(properties).To(Equal(jsonFields(reflect.TypeOf(entity))), name)
+		}
+	})
+})
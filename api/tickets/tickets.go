@@ -2,6 +2,8 @@ package tickets
 
 import (
 	"github.com/labstack/echo"
+	"s7ab-platform-hyperledger/platform/s7ticket/api/auth"
+	"s7ab-platform-hyperledger/platform/s7ticket/api/openapi"
 	"s7ab-platform-hyperledger/platform/s7ticket/api/tickets/handlers"
 )
 
@@ -9,32 +11,98 @@ const (
 	DefaultUrlPath = `/tickets`
 )
 
+// NewModule registers tickets routes, m must contain auth.Middleware,
+// routes changing ledger are allowed only for principals with specific role
+// Requests are validated against OpenAPISpec, document is served on urlPath/openapi.yaml
 func NewModule(e *echo.Echo, urlPath string, m ...echo.MiddlewareFunc) {
 	if urlPath == `` {
 		urlPath = DefaultUrlPath
 	}
-	g := e.Group(urlPath, m...)
+
+	validator, err := openapi.Load([]byte(OpenAPISpec), urlPath)
+	if err != nil {
+		panic(`invalid tickets openapi document: ` + err.Error())
+	}
+	e.GET(urlPath+`/openapi.yaml`, openapi.Handler([]byte(OpenAPISpec)))
+
+	g := e.Group(urlPath, append(m, validator.Middleware(false))...)
 	setRouter(g)
 }
 
+// PaymentRateLimit limits payment creation routes, requests are not limited by default
+var PaymentRateLimit echo.MiddlewareFunc = func(next echo.HandlerFunc) echo.HandlerFunc {
+	return next
+}
+
 func setRouter(g *echo.Group) {
+	merchant := auth.RequireRole(auth.RoleMerchant)
+	agent := auth.RequireRole(auth.RoleAgent)
+	// merchant changes state of payments held in RiskReview only, chaincode checks role of every transition
+	stateChange := auth.RequireRole(auth.RoleAgent, auth.RoleBank, auth.RoleMerchant)
+	merchantOrBank := auth.RequireRole(auth.RoleMerchant, auth.RoleBank)
+	agentOrMerchant := auth.RequireRole(auth.RoleAgent, auth.RoleMerchant)
 
 	g.GET(`/merchant`, handlers.GetMerchantHandler)
 
 	g.GET("/agent", handlers.AgentHandler)
 	g.GET("/agent/list", handlers.AgentListHandler)
 
-	g.POST("/agent/add", handlers.AddAgentHandler)
+	g.POST("/agent/add", handlers.AddAgentHandler, merchant)
+	// Точки продаж агента, точка продаж определяется атрибутом сертификата клиента
+	g.GET(`/agent/:id/subagent`, handlers.ListSubAgentsHandler, agentOrMerchant)
+	g.POST(`/agent/:id/subagent`, handlers.SetSubAgentHandler, merchant)
+	g.GET(`/subagent/payment`, handlers.ListSubAgentPaymentsHandler, agent)
 	// Отправка запроса на платеж в синхронном режиме
-	g.POST(`/sync/payment`, handlers.CreateSyncPaymentHandler)
+	g.POST(`/sync/payment`, handlers.CreateSyncPaymentHandler, agent, PaymentRateLimit)
 	// Выписка или аннулирование билета
-	g.POST(`/sync/payment/:id`, handlers.UpdatePaymentHandler)
+	g.POST(`/sync/payment/:id`, handlers.UpdatePaymentHandler, stateChange)
+	// Отправка запроса на платеж в асинхронном режиме, статус доступен в /operations/:id
+	g.POST(`/async/payment`, handlers.CreateAsyncPaymentHandler, agent, PaymentRateLimit)
+	// Выписка или аннулирование билета в асинхронном режиме
+	g.POST(`/async/payment/:id`, handlers.UpdateAsyncPaymentHandler, stateChange)
+	// Получение статуса асинхронной операции
+	g.GET(`/operations/:id`, handlers.GetOperationHandler)
+	// Резервирование средств банком плательщика, списание и освобождение резерва
+	g.POST(`/sync/payment/:id/hold`, handlers.HoldConfirmHandler, auth.RequireRole(auth.RoleBank))
+	g.POST(`/sync/payment/:id/capture`, handlers.HoldCaptureHandler, agent)
+	g.POST(`/sync/payment/:id/release`, handlers.HoldReleaseHandler, stateChange)
+	// Выписка билета агентом, отмена платежа до списания и аннулирование билета продавцом
+	g.POST(`/sync/payment/:id/issue`, handlers.IssueHandler, agent)
+	g.POST(`/sync/payment/:id/cancel`, handlers.CancelHandler, agent)
+	g.POST(`/sync/payment/:id/void`, handlers.VoidHandler, merchant)
+	// Споры по списанным платежам: открытие банком плательщика, ответ продавца и решение
+	g.POST(`/sync/payment/:id/dispute`, handlers.OpenDisputeHandler, auth.RequireRole(auth.RoleBank))
+	g.GET(`/dispute`, handlers.ListDisputesHandler)
+	g.GET(`/dispute/:id`, handlers.GetDisputeHandler)
+	g.POST(`/dispute/:id/respond`, handlers.RespondDisputeHandler, merchant)
+	g.POST(`/dispute/:id/resolve`, handlers.ResolveDisputeHandler, merchantOrBank)
 	// Получение истории state билета
 	g.GET(`/sync/history/:id`, handlers.GetPaymentHistory)
+	// Получение истории изменений платежа с разницей между версиями
+	g.GET(`/payment/:id/timeline`, handlers.GetPaymentTimeline)
+	// Поиск платежей по PNR бронирования
+	g.GET(`/itinerary/:pnr`, handlers.SearchItineraryHandler)
+	// Метаданные платежа: PNR, пассажир, рейс и маршрут
+	g.GET(`/payment/:id/meta`, handlers.GetPaymentMetaHandler)
+	g.POST(`/payment/:id/meta`, handlers.SetPaymentMetaHandler, stateChange)
+	g.GET(`/system/meta/schema`, handlers.GetMetaSchemaHandler)
 	// Получение информации о платеже
 	g.GET(`/payment/:id`, handlers.GetPaymentHandler)
 	// Получение списка платежек
 	g.GET(`/payment`, handlers.ListPaymentHandler)
-	// Системный метод разруливания кто мерчант, а кто агент
-	g.POST(`/system/init`, handlers.MerchantInitHandler)
+	// Черный список плательщиков: счета, ИНН и идентификаторы
+	g.GET(`/blocklist`, handlers.ListBlocklistHandler, merchantOrBank)
+	g.POST(`/blocklist`, handlers.AddBlocklistHandler, merchantOrBank)
+	// Импорт черного списка из CSV
+	g.POST(`/blocklist/import`, handlers.ImportBlocklistHandler, merchantOrBank)
+	g.DELETE(`/blocklist/:type/:value`, handlers.RemoveBlocklistHandler, merchantOrBank)
+	// Отчеты продаж агентов за период, подписание отчета продавцом и агентом закрывает период
+	g.POST(`/report`, handlers.CreateSalesReportHandler, merchant)
+	g.GET(`/report`, handlers.ListSalesReportsHandler, agentOrMerchant)
+	g.GET(`/report/:id`, handlers.GetSalesReportHandler, agentOrMerchant)
+	g.POST(`/report/:id/sign`, handlers.SignSalesReportHandler, agentOrMerchant)
+	// Получение настроек чейнкода
+	g.GET(`/system/config`, handlers.GetConfigHandler)
+	// Изменение настроек чейнкода владельцем, владельца проверяет чейнкод
+	g.POST(`/system/config`, handlers.UpdateConfigHandler)
 }
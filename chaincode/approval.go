@@ -0,0 +1,111 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/lib/cid"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	platformEntities "s7ab-platform-hyperledger/platform/core/entities"
+	"s7ab-platform-hyperledger/platform/s7ticket/entities"
+)
+
+// approvalStates are state changes which need four-eyes approval above threshold of role
+var approvalStates = map[entities.PaymentState]string{
+	entities.DebitRequest: RoleAgent,
+	entities.DebitSuccess: RoleBank,
+}
+
+// needsApproval returns true if state change of role needs second approver for amount of payment or leg
+func needsApproval(config *entities.Config, amount uint, state entities.PaymentState, invokerRole string) bool {
+	if approvalStates[state] != invokerRole {
+		return false
+	}
+	threshold := config.ApprovalThresholds[invokerRole]
+	return threshold != 0 && amount > threshold
+}
+
+// approvalExpired returns true if second approver didn't confirm approval in time
+func approvalExpired(approval *entities.PaymentApproval, now time.Time) bool {
+	expiresAt, err := time.Parse(time.RFC3339Nano, approval.ExpiresAt)
+	return err != nil || !now.Before(expiresAt)
+}
+
+// approveStateChange records invoker as signer of payment or leg state change above approval threshold,
+// leg change is approved with leg amount and nil leg means change of whole payment
+// First signer opens pending approval and true is returned, state is changed only when different certificate
+// of the same organization signs it before expiration. Expired approval or approval of other state, leg or amount
+// is replaced by new one, payment has one pending approval at a time
+func (t Ticket) approveStateChange(stub shim.ChaincodeStubInterface, payment *entities.Payment, state entities.PaymentState,
+	leg *entities.PaymentLeg, invoker *platformEntities.Member, invokerRole string) (pending bool, err error) {
+
+	config, err := t.getConfig(stub)
+	if err != nil {
+		return
+	}
+
+	amount, legId := payment.Amount, ``
+	if leg != nil {
+		amount, legId = leg.Amount, leg.Id
+	}
+
+	if !needsApproval(config, amount, state, invokerRole) {
+		// pending approval of another leg waits for its second signer
+		if payment.PendingApproval != nil && payment.PendingApproval.LegId == legId {
+			payment.PendingApproval = nil
+		}
+		return
+	}
+
+	signer, err := cid.GetID(stub)
+	if err != nil {
+		return
+	}
+
+	now, err := txNow(stub)
+	if err != nil {
+		return
+	}
+
+	approval := payment.PendingApproval
+	if approval != nil && approval.State == state && approval.LegId == legId && approval.Amount == amount &&
+		approval.OrgId == invoker.OrganizationId && !approvalExpired(approval, now) {
+		if approval.FirstSigner == signer {
+			return false, fmt.Errorf("state %s must be approved by another certificate of %s", state, invoker.OrganizationId)
+		}
+		approval.SecondSigner = signer
+		approval.ApprovedAt = now.Format(time.RFC3339Nano)
+		payment.Approvals = append(payment.Approvals, *approval)
+		payment.PendingApproval = nil
+		return
+	}
+
+	payment.UpdatedBy = invoker.OrganizationId
+	payment.UpdatedByRole = invokerRole
+	payment.PendingApproval = &entities.PaymentApproval{
+		State:       state,
+		LegId:       legId,
+		Amount:      amount,
+		Role:        invokerRole,
+		OrgId:       invoker.OrganizationId,
+		FirstSigner: signer,
+		RequestedAt: now.Format(time.RFC3339Nano),
+		ExpiresAt:   now.Add(time.Duration(config.ApprovalTimeout) * time.Second).Format(time.RFC3339Nano),
+	}
+	return true, nil
+}
+
+// putPendingApproval saves payment waiting for second approver and emits approval event
+// Event is returned as transaction result, so client can tell pending change from applied one
+func (t Ticket) putPendingApproval(stub shim.ChaincodeStubInterface, payment *entities.Payment) ([]byte, error) {
+	if err := t.putPayment(stub, payment); err != nil {
+		return nil, err
+	}
+
+	approvalBytes, err := json.Marshal(entities.PaymentApprovalEvent{PaymentId: payment.Id, Approval: *payment.PendingApproval})
+	if err != nil {
+		return nil, err
+	}
+	return approvalBytes, stub.SetEvent(entities.PaymentApprovalRequested, approvalBytes)
+}
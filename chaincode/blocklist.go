@@ -0,0 +1,274 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"s7ab-platform-hyperledger/platform/s7ticket/entities"
+)
+
+var blocklistEntryTypes = map[entities.BlocklistEntryType]bool{
+	entities.BlockAccount: true,
+	entities.BlockItn:     true,
+	entities.BlockPayerId: true,
+}
+
+func (t Ticket) getBlocklistKey(stub shim.ChaincodeStubInterface, entryType entities.BlocklistEntryType, value string) (string, error) {
+	return stub.CreateCompositeKey(t.blocklistKey, []string{string(entryType), value})
+}
+
+func validateBlocklistEntry(entry entities.BlocklistEntry) error {
+	if !blocklistEntryTypes[entry.Type] {
+		return fmt.Errorf("unknown blocklist entry type: %s", entry.Type)
+	}
+	if entry.Value == `` {
+		return errors.New(`blocklist entry value is empty`)
+	}
+	if entry.ExpiresAt != `` {
+		if _, err := time.Parse(time.RFC3339, entry.ExpiresAt); err != nil {
+			return fmt.Errorf("invalid blocklist entry expiry %s: %s", entry.ExpiresAt, err)
+		}
+	}
+	return nil
+}
+
+// blocklistEntryActive returns false if entry expired before now
+func blocklistEntryActive(entry entities.BlocklistEntry, now time.Time) bool {
+	if entry.ExpiresAt == `` {
+		return true
+	}
+	expiresAt, err := time.Parse(time.RFC3339, entry.ExpiresAt)
+	return err != nil || now.Before(expiresAt)
+}
+
+type blocklistCheck struct {
+	entryType entities.BlocklistEntryType
+	value     string
+}
+
+// checkBlocklist returns error if payer account, itn or payer id is in active blocklist entry
+func (t Ticket) checkBlocklist(stub shim.ChaincodeStubInterface, payload entities.PaymentCreatePayload) error {
+	now, err := txNow(stub)
+	if err != nil {
+		return err
+	}
+
+	checks := []blocklistCheck{
+		{entities.BlockAccount, payload.PayerAccount},
+		{entities.BlockItn, payload.PayerNumber},
+		{entities.BlockPayerId, payload.PayerId},
+	}
+	for _, leg := range payload.Legs {
+		checks = append(checks, blocklistCheck{entities.BlockAccount, leg.Account})
+	}
+
+	for _, check := range checks {
+		if check.value == `` {
+			continue
+		}
+
+		key, err := t.getBlocklistKey(stub, check.entryType, check.value)
+		if err != nil {
+			return err
+		}
+		entryBytes, err := stub.GetState(key)
+		if err != nil {
+			return err
+		}
+		if entryBytes == nil {
+			continue
+		}
+
+		var entry entities.BlocklistEntry
+		if err = json.Unmarshal(entryBytes, &entry); err != nil {
+			return err
+		}
+		if blocklistEntryActive(entry, now) {
+			return fmt.Errorf("payment rejected, payer %s %s is blocklisted: %s", entry.Type, entry.Value, entry.Reason)
+		}
+	}
+	return nil
+}
+
+// canMaintainBlocklist returns error if invoker is neither merchant nor bank
+func (t Ticket) canMaintainBlocklist(stub shim.ChaincodeStubInterface) (invokerId string, invokerRole string, err error) {
+	_, invoker, invokerRole, err := t.getActors(stub)
+	if err != nil {
+		return
+	}
+	if invokerRole != RoleMerchant && invokerRole != RoleBank {
+		err = fmt.Errorf("only merchant or bank can maintain blocklist, your role is: %s", invokerRole)
+		return
+	}
+	return invoker.OrganizationId, invokerRole, nil
+}
+
+// canChangeBlocklistEntry returns error if invoker is neither merchant nor organization added entry
+func canChangeBlocklistEntry(entry entities.BlocklistEntry, invokerId string, invokerRole string) error {
+	if invokerRole != RoleMerchant && entry.AddedBy != invokerId {
+		return fmt.Errorf("blocklist entry %s %s can be changed only by merchant or %s", entry.Type, entry.Value, entry.AddedBy)
+	}
+	return nil
+}
+
+// getBlocklistEntry returns entry stored by key, nil if there is no entry
+func getBlocklistEntry(stub shim.ChaincodeStubInterface, key string) (*entities.BlocklistEntry, error) {
+	entryBytes, err := stub.GetState(key)
+	if err != nil || entryBytes == nil {
+		return nil, err
+	}
+	var entry entities.BlocklistEntry
+	if err = json.Unmarshal(entryBytes, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// Add blocklist entries, allowed only from merchant and bank, arg[0] - json array of entries
+// Existing entry with same type and value is replaced, only merchant can replace entry of another organization
+func (t Ticket) blocklistAdd(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) != 1 {
+		return t.WriteError(fmt.Sprintf("arguments count mismatch: %v", args))
+	}
+
+	invokerId, invokerRole, err := t.canMaintainBlocklist(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	var entries []entities.BlocklistEntry
+	if err = json.Unmarshal([]byte(args[0]), &entries); err != nil {
+		return t.WriteError(err)
+	}
+	if len(entries) == 0 {
+		return t.WriteError(`blocklist entries are empty`)
+	}
+
+	addedAt, err := txTime(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	for i := range entries {
+		if err = validateBlocklistEntry(entries[i]); err != nil {
+			return t.WriteError(fmt.Sprintf("entry %d: %s", i, err))
+		}
+		entries[i].AddedBy = invokerId
+		entries[i].AddedByRole = invokerRole
+		entries[i].AddedAt = addedAt
+
+		key, err := t.getBlocklistKey(stub, entries[i].Type, entries[i].Value)
+		if err != nil {
+			return t.WriteError(err)
+		}
+		current, err := getBlocklistEntry(stub, key)
+		if err != nil {
+			return t.WriteError(err)
+		}
+		if current != nil {
+			if err = canChangeBlocklistEntry(*current, invokerId, invokerRole); err != nil {
+				return t.WriteError(err)
+			}
+		}
+		if err = putJSON(stub, key, entries[i]); err != nil {
+			return t.WriteError(err)
+		}
+	}
+
+	entriesBytes, err := json.Marshal(entries)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if err = stub.SetEvent(entities.BlocklistEntriesAdded, entriesBytes); err != nil {
+		return t.WriteError(err)
+	}
+	return t.WriteSuccess(entriesBytes)
+}
+
+// Remove blocklist entry, allowed only from merchant and bank added entry, arg[0] - entry type, arg[1] - value
+func (t Ticket) blocklistRemove(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) != 2 {
+		return t.WriteError(fmt.Sprintf("arguments count mismatch: %v", args))
+	}
+
+	invokerId, invokerRole, err := t.canMaintainBlocklist(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	key, err := t.getBlocklistKey(stub, entities.BlocklistEntryType(args[0]), args[1])
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	entry, err := getBlocklistEntry(stub, key)
+	if err != nil {
+		return t.WriteError(err)
+	}
+	if entry == nil {
+		return t.WriteError(fmt.Sprintf("blocklist entry not found: %s %s", args[0], args[1]))
+	}
+	if err = canChangeBlocklistEntry(*entry, invokerId, invokerRole); err != nil {
+		return t.WriteError(err)
+	}
+
+	entryBytes, err := json.Marshal(entry)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if err = stub.DelState(key); err != nil {
+		return t.WriteError(err)
+	}
+
+	if err = stub.SetEvent(entities.BlocklistEntryRemoved, entryBytes); err != nil {
+		return t.WriteError(err)
+	}
+	return t.WriteSuccess(nil)
+}
+
+// List blocklist entries including expired ones, allowed only from merchant and bank, arg[0] - optional entry type
+func (t Ticket) blocklistList(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+
+	if _, _, err := t.canMaintainBlocklist(stub); err != nil {
+		return t.WriteError(err)
+	}
+
+	attributes := []string{}
+	if len(args) > 0 && args[0] != `` {
+		attributes = append(attributes, args[0])
+	}
+
+	iter, err := stub.GetStateByPartialCompositeKey(t.blocklistKey, attributes)
+	if err != nil {
+		return t.WriteError(err)
+	}
+	defer iter.Close()
+
+	entries := []entities.BlocklistEntry{}
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return t.WriteError(err)
+		}
+		var entry entities.BlocklistEntry
+		if err = json.Unmarshal(kv.Value, &entry); err != nil {
+			return t.WriteError(err)
+		}
+		entries = append(entries, entry)
+	}
+
+	entriesBytes, err := json.Marshal(entries)
+	if err != nil {
+		return t.WriteError(err)
+	}
+	return t.WriteSuccess(entriesBytes)
+}
@@ -0,0 +1,254 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"s7ab-platform-hyperledger/platform/s7ticket/entities"
+)
+
+// agentCancelStates are states before debit request, agent can cancel payment in them
+// Held funds are returned with /hold/release instead
+var agentCancelStates = map[entities.PaymentState]bool{
+	entities.CheckFundsRequest:    true,
+	entities.CheckFundsInProgress: true,
+	entities.CheckFundsSuccess:    true,
+	entities.CheckFundsFail:       true,
+	entities.RiskReview:           true,
+}
+
+// voidable returns error if ticket can't be voided at now
+func voidable(payment *entities.Payment, voidWindow uint, now time.Time) error {
+	if payment.State != entities.DebitSuccess || payment.IssuedAt == `` {
+		return fmt.Errorf("only issued ticket can be voided, payment state: %s", payment.State)
+	}
+
+	issuedAt, err := time.Parse(time.RFC3339Nano, payment.IssuedAt)
+	if err != nil {
+		return err
+	}
+	if !now.Before(issuedAt.Add(time.Duration(voidWindow) * time.Second)) {
+		return fmt.Errorf("void window of %d seconds after issuance is over", voidWindow)
+	}
+	return nil
+}
+
+// setPaymentState moves payment and its legs to state
+func setPaymentState(payment *entities.Payment, state entities.PaymentState) {
+	payment.State = state
+	for i := range payment.Legs {
+		payment.Legs[i].State = state
+	}
+}
+
+func (t Ticket) setCancelEvent(stub shim.ChaincodeStubInterface, name string, payment *entities.Payment,
+	previousState entities.PaymentState, reason string) error {
+
+	eventBytes, err := json.Marshal(entities.PaymentCanceledEvent{
+		PaymentId:       payment.Id,
+		PayerOrgId:      payment.PayerOrgId,
+		PayerBankOrgId:  payment.PayerBankOrgId,
+		PayerBankOrgIds: paymentBanks(payment),
+		PreviousState:   previousState,
+		CurrentState:    payment.State,
+		ReasonCode:      payment.CancelReason,
+		Reason:          reason,
+		Amount:          payment.Amount,
+		Currency:        payment.Currency,
+	})
+	if err != nil {
+		return err
+	}
+	return stub.SetEvent(name, eventBytes)
+}
+
+// Issue ticket of debited payment, allowed only from payer agent, arg[0] - issue json
+func (t Ticket) issue(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) != 1 {
+		return t.WriteError(fmt.Sprintf("arguments count mismatch: %v", args))
+	}
+
+	var payload entities.IssuePayload
+	if err := json.Unmarshal([]byte(args[0]), &payload); err != nil {
+		return t.WriteError(err)
+	}
+	if payload.TicketNumber == `` {
+		return t.WriteError(`ticket number is empty`)
+	}
+
+	_, invoker, invokerRole, err := t.getActors(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	payment, err := t.getPayment(stub, payload.PaymentId)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if invokerRole != RoleAgent || invoker.OrganizationId != payment.PayerOrgId {
+		return t.WriteError(fmt.Sprintf("only payer agent can issue ticket, your role is: %s", invokerRole))
+	}
+
+	if err = t.checkSubAgentPayment(stub, payment, entities.SubAgentIssue); err != nil {
+		return t.WriteError(err)
+	}
+
+	if payment.State != entities.DebitSuccess {
+		return t.WriteError(fmt.Sprintf("ticket can be issued only after debit, payment state: %s", payment.State))
+	}
+	if payment.TicketNumber != `` {
+		return t.WriteError(fmt.Sprintf("ticket already issued: %s", payment.TicketNumber))
+	}
+
+	if payment.IssuedAt, err = txTime(stub); err != nil {
+		return t.WriteError(err)
+	}
+	payment.TicketNumber = payload.TicketNumber
+	payment.UpdatedBy = invoker.OrganizationId
+	payment.UpdatedByRole = invokerRole
+
+	if err = t.putPayment(stub, payment); err != nil {
+		return t.WriteError(err)
+	}
+
+	paymentBytes, err := json.Marshal(payment)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if err = stub.SetEvent(entities.TicketIssued, paymentBytes); err != nil {
+		return t.WriteError(err)
+	}
+	return t.WriteSuccess(nil)
+}
+
+// Cancel payment before debit, allowed only from payer agent, arg[0] - cancel json
+func (t Ticket) cancel(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) != 1 {
+		return t.WriteError(fmt.Sprintf("arguments count mismatch: %v", args))
+	}
+
+	var payload entities.CancelPayload
+	if err := json.Unmarshal([]byte(args[0]), &payload); err != nil {
+		return t.WriteError(err)
+	}
+
+	_, invoker, invokerRole, err := t.getActors(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	payment, err := t.getPayment(stub, payload.PaymentId)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if invokerRole != RoleAgent || invoker.OrganizationId != payment.PayerOrgId {
+		return t.WriteError(fmt.Sprintf("only payer agent can cancel payment, your role is: %s", invokerRole))
+	}
+
+	if err = t.checkSubAgentPayment(stub, payment, entities.SubAgentCancel); err != nil {
+		return t.WriteError(err)
+	}
+
+	if err = t.checkPeriodLock(stub, payment); err != nil {
+		return t.WriteError(err)
+	}
+
+	if !agentCancelStates[payment.State] {
+		return t.WriteError(fmt.Sprintf("payment can't be canceled in state: %s", payment.State))
+	}
+
+	previousState := payment.State
+	setPaymentState(payment, entities.TicketCanceled)
+	payment.CancelReason = entities.CancelReasonAgentCancel
+	if payment.CanceledAt, err = txTime(stub); err != nil {
+		return t.WriteError(err)
+	}
+	payment.UpdatedBy = invoker.OrganizationId
+	payment.UpdatedByRole = invokerRole
+
+	if err = t.putPayment(stub, payment); err != nil {
+		return t.WriteError(err)
+	}
+
+	if err = t.setCancelEvent(stub, entities.TicketPaymentCanceledByAgent, payment, previousState, payload.Reason); err != nil {
+		return t.WriteError(err)
+	}
+	return t.WriteSuccess(nil)
+}
+
+// Void issued ticket during void window, allowed only from merchant, arg[0] - cancel json
+// Payment is refunded, funds are returned to payer bank in next settlement batch
+func (t Ticket) void(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) != 1 {
+		return t.WriteError(fmt.Sprintf("arguments count mismatch: %v", args))
+	}
+
+	var payload entities.CancelPayload
+	if err := json.Unmarshal([]byte(args[0]), &payload); err != nil {
+		return t.WriteError(err)
+	}
+
+	_, invoker, invokerRole, err := t.getActors(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if invokerRole != RoleMerchant {
+		return t.WriteError(fmt.Sprintf("only merchant can void ticket, your role is: %s", invokerRole))
+	}
+
+	payment, err := t.getPayment(stub, payload.PaymentId)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if err = checkAmountAccess(stub, invokerRole, payment.Amount); err != nil {
+		return t.WriteError(err)
+	}
+
+	config, err := t.getConfig(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	now, err := txNow(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if err = voidable(payment, config.VoidSeconds(), now); err != nil {
+		return t.WriteError(err)
+	}
+
+	if disputeOpen(payment.DisputeState) {
+		return t.WriteError(fmt.Sprintf("ticket with open dispute can't be voided: %s", payment.DisputeId))
+	}
+	if payment.DisputeState == entities.DisputeAccepted {
+		return t.WriteError(fmt.Sprintf("ticket partially refunded by dispute can't be voided: %s", payment.DisputeId))
+	}
+
+	previousState := payment.State
+	setPaymentState(payment, entities.Refunded)
+	payment.CancelReason = entities.CancelReasonVoid
+	payment.CanceledAt = now.Format(time.RFC3339Nano)
+	payment.UpdatedBy = invoker.OrganizationId
+	payment.UpdatedByRole = invokerRole
+
+	if err = t.putPayment(stub, payment); err != nil {
+		return t.WriteError(err)
+	}
+
+	if err = t.setCancelEvent(stub, entities.TicketVoidedByMerchant, payment, previousState, payload.Reason); err != nil {
+		return t.WriteError(err)
+	}
+	return t.WriteSuccess(nil)
+}
@@ -0,0 +1,245 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"s7ab-platform-hyperledger/platform/s7ticket/entities"
+)
+
+const (
+	defaultIssuanceTimeout = 24 * 60 * 60
+	defaultMaxRetries      = 3
+	defaultVoidWindow      = 24 * 60 * 60
+
+	defaultDisputeResponseWindow   = 7 * 24 * 60 * 60
+	defaultDisputeResolutionWindow = 7 * 24 * 60 * 60
+
+	defaultApprovalTimeout = 60 * 60
+)
+
+func (t Ticket) getConfig(stub shim.ChaincodeStubInterface) (config *entities.Config, err error) {
+	configBytes, err := stub.GetState(t.configKey)
+	if err != nil {
+		return
+	}
+	if configBytes == nil {
+		return nil, errors.New(`config not set in state`)
+	}
+	err = json.Unmarshal(configBytes, &config)
+	return
+}
+
+// parseConfig decodes config payload and sets defaults, owner defaults to transaction creator
+func (t Ticket) parseConfig(stub shim.ChaincodeStubInterface, payload string) (config entities.Config, err error) {
+	if err = json.Unmarshal([]byte(payload), &config); err != nil {
+		return config, fmt.Errorf("invalid config payload: %s", err)
+	}
+
+	if config.Owner == `` {
+		creator, err := t.GetCreator(stub)
+		if err != nil {
+			return config, err
+		}
+		config.Owner = creator.MspID
+	}
+
+	if config.IssuanceTimeout == 0 {
+		config.IssuanceTimeout = defaultIssuanceTimeout
+	}
+
+	// explicit zero disables retries
+	if config.MaxRetries == nil {
+		maxRetries := uint(defaultMaxRetries)
+		config.MaxRetries = &maxRetries
+	}
+
+	// explicit zero disables voids
+	if config.VoidWindow == nil {
+		voidWindow := uint(defaultVoidWindow)
+		config.VoidWindow = &voidWindow
+	}
+
+	if config.DisputeResponseWindow == 0 {
+		config.DisputeResponseWindow = defaultDisputeResponseWindow
+	}
+
+	if config.DisputeResolutionWindow == 0 {
+		config.DisputeResolutionWindow = defaultDisputeResolutionWindow
+	}
+
+	if config.ApprovalTimeout == 0 {
+		config.ApprovalTimeout = defaultApprovalTimeout
+	}
+	return
+}
+
+// validateConfig checks config values, merchant must be registered in organizations chaincode
+func (t Ticket) validateConfig(stub shim.ChaincodeStubInterface, config entities.Config) error {
+	if config.Merchant == `` {
+		return errors.New(`merchant is empty`)
+	}
+
+	if config.DefaultCurrency == `` {
+		return errors.New(`default currency is empty`)
+	}
+
+	if config.MaxPaymentAmount != 0 && config.MaxPaymentAmount < config.MinPaymentAmount {
+		return errors.New(`max payment amount is less than min payment amount`)
+	}
+
+	for role := range config.ApprovalThresholds {
+		if role != RoleAgent && role != RoleBank {
+			return fmt.Errorf("approval threshold is set only for agent and bank, got: %s", role)
+		}
+	}
+
+	for state, access := range config.StateAccess {
+		if accessLevels[access] == 0 {
+			return fmt.Errorf("unknown access level %s of state %s", access, state)
+		}
+	}
+
+	if _, err := t.getMember(stub, config.Merchant); err != nil {
+		return fmt.Errorf("invalid merchant: %s", err)
+	}
+	return nil
+}
+
+// validatePaymentAmount checks payment amount against config limits, zero limit is not checked
+func validatePaymentAmount(config *entities.Config, amount uint) error {
+	if amount == 0 {
+		return errors.New(`payment amount is empty`)
+	}
+	if amount < config.MinPaymentAmount {
+		return fmt.Errorf("payment amount is less than min amount: %d", config.MinPaymentAmount)
+	}
+	if config.MaxPaymentAmount != 0 && amount > config.MaxPaymentAmount {
+		return fmt.Errorf("payment amount exceeds max amount: %d", config.MaxPaymentAmount)
+	}
+	return nil
+}
+
+func (t Ticket) putConfig(stub shim.ChaincodeStubInterface, config entities.Config) error {
+	configBytes, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	if err = stub.PutState(t.configKey, configBytes); err != nil {
+		return err
+	}
+	return stub.PutState(t.merchantKey, []byte(config.Merchant))
+}
+
+// seedConfig sets config of upgraded version which stored only merchant, merchant stays config owner
+// Default currency is left empty, payments keep currency of create payload as before
+func (t Ticket) seedConfig(stub shim.ChaincodeStubInterface, merchantId string) error {
+	config, err := t.parseConfig(stub, `{}`)
+	if err != nil {
+		return err
+	}
+	config.Merchant = merchantId
+	config.Owner = merchantId
+	return t.putConfig(stub, config)
+}
+
+// initConfig sets config from init payload, arg[0] - config json
+// Payload can be omitted on upgrade, current config is kept in this case,
+// config of version without it is seeded from stored merchant
+func (t Ticket) initConfig(stub shim.ChaincodeStubInterface) error {
+	_, args := stub.GetFunctionAndParameters()
+
+	if len(args) == 0 {
+		if exists, err := stub.GetState(t.configKey); err != nil || exists != nil {
+			return err
+		}
+		merchantId, err := stub.GetState(t.merchantKey)
+		if err != nil {
+			return err
+		}
+		if merchantId == nil {
+			return errors.New(`init payload is empty`)
+		}
+		return t.seedConfig(stub, string(merchantId))
+	}
+
+	if len(args) != 1 {
+		return fmt.Errorf("arguments count mismatch: %v", args)
+	}
+
+	config, err := t.parseConfig(stub, args[0])
+	if err != nil {
+		return err
+	}
+
+	if err = t.validateConfig(stub, config); err != nil {
+		return err
+	}
+	return t.putConfig(stub, config)
+}
+
+// Update chaincode config, allowed only from config owner, merchant can't be changed, arg[0] - config json
+func (t Ticket) configUpdate(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) != 1 {
+		return t.WriteError(fmt.Sprintf("arguments count mismatch: %v", args))
+	}
+
+	current, err := t.getConfig(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	creator, err := t.GetCreator(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if creator.MspID != current.Owner {
+		return t.WriteError(fmt.Sprintf("only owner can update config, owner: %s", current.Owner))
+	}
+
+	config, err := t.parseConfig(stub, args[0])
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if config.Merchant != current.Merchant {
+		return t.WriteError(fmt.Sprintf("merchant can't be changed, merchant: %s", current.Merchant))
+	}
+
+	if err = t.validateConfig(stub, config); err != nil {
+		return t.WriteError(err)
+	}
+
+	if err = t.putConfig(stub, config); err != nil {
+		return t.WriteError(err)
+	}
+
+	eventBytes, err := json.Marshal(entities.ConfigUpdatedEvent{Previous: *current, Current: config})
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if err = stub.SetEvent(entities.ConfigUpdated, eventBytes); err != nil {
+		return t.WriteError(err)
+	}
+	return t.WriteSuccess(nil)
+}
+
+func (t Ticket) configGet(stub shim.ChaincodeStubInterface) pb.Response {
+	config, err := t.getConfig(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	configBytes, err := json.Marshal(config)
+	if err != nil {
+		return t.WriteError(err)
+	}
+	return t.WriteSuccess(configBytes)
+}
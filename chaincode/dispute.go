@@ -0,0 +1,484 @@
+package chaincode
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"s7ab-platform-hyperledger/platform/s7ticket/entities"
+)
+
+// disputeOpen returns true if dispute isn't resolved yet
+func disputeOpen(state entities.DisputeState) bool {
+	return state == entities.DisputeOpened || state == entities.DisputeResponded
+}
+
+// deadlinePassed returns true if RFC3339 deadline is not after now
+func deadlinePassed(deadline string, now time.Time) bool {
+	t, err := time.Parse(time.RFC3339, deadline)
+	return err != nil || !now.Before(t)
+}
+
+// disputeWindow converts window seconds to duration
+// Configs stored before disputes were introduced have zero windows, fallback is used for them
+func disputeWindow(seconds, fallback uint) time.Duration {
+	if seconds == 0 {
+		seconds = fallback
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// disputedAmount returns amount funded by bank, legs of split payment funded by other banks are not disputed
+func disputedAmount(payment *entities.Payment, bankOrgId string) (amount uint) {
+	for _, leg := range paymentFunding(payment) {
+		if leg.BankOrgId == bankOrgId {
+			amount += leg.Amount
+		}
+	}
+	return
+}
+
+// refundDisputedLegs refunds legs funded by bank, payment is refunded when all its legs are refunded
+func refundDisputedLegs(payment *entities.Payment, bankOrgId string) {
+	if len(payment.Legs) == 0 {
+		setPaymentState(payment, entities.Refunded)
+		return
+	}
+
+	refunded := true
+	for i := range payment.Legs {
+		if payment.Legs[i].BankOrgId == bankOrgId {
+			payment.Legs[i].State = entities.Refunded
+		}
+		refunded = refunded && payment.Legs[i].State == entities.Refunded
+	}
+	if refunded {
+		payment.State = entities.Refunded
+	}
+}
+
+// validateEvidence checks that every evidence has sha256 hash of document
+func validateEvidence(evidence []entities.DisputeEvidence) error {
+	if len(evidence) == 0 {
+		return errors.New(`dispute response requires evidence`)
+	}
+	for _, e := range evidence {
+		if hash, err := hex.DecodeString(e.Hash); err != nil || len(hash) != 32 {
+			return fmt.Errorf("evidence hash must be hex encoded sha256: %s", e.Hash)
+		}
+	}
+	return nil
+}
+
+// canResolveDispute returns error if role can't move dispute to state at now
+// Bank accepts unanswered case after response deadline and resolves answered case until resolution deadline,
+// merchant accepts case at any time and rejects answered case which bank didn't resolve in time
+func canResolveDispute(dispute *entities.Dispute, role string, state entities.DisputeState, now time.Time) error {
+	if !disputeOpen(dispute.State) {
+		return fmt.Errorf("dispute is already resolved: %s", dispute.State)
+	}
+	if state != entities.DisputeAccepted && state != entities.DisputeRejected {
+		return fmt.Errorf("dispute can be resolved only as %s or %s", entities.DisputeAccepted, entities.DisputeRejected)
+	}
+
+	switch role {
+	case RoleBank:
+		if dispute.State == entities.DisputeOpened && state == entities.DisputeAccepted && !deadlinePassed(dispute.RespondBy, now) {
+			return fmt.Errorf("merchant can respond to dispute until %s", dispute.RespondBy)
+		}
+		if dispute.State == entities.DisputeResponded && deadlinePassed(dispute.ResolveBy, now) {
+			return fmt.Errorf("dispute resolution deadline %s is over", dispute.ResolveBy)
+		}
+	case RoleMerchant:
+		if state == entities.DisputeRejected &&
+			(dispute.State != entities.DisputeResponded || !deadlinePassed(dispute.ResolveBy, now)) {
+			return errors.New(`merchant can reject only answered dispute after resolution deadline`)
+		}
+	default:
+		return fmt.Errorf("only payer bank or merchant can resolve dispute, your role is: %s", role)
+	}
+	return nil
+}
+
+func (t Ticket) getDisputeKey(stub shim.ChaincodeStubInterface, disputeId string) (string, error) {
+	return stub.CreateCompositeKey(t.disputeKey, []string{disputeId})
+}
+
+func (t Ticket) getDispute(stub shim.ChaincodeStubInterface, disputeId string) (dispute *entities.Dispute, err error) {
+	key, err := t.getDisputeKey(stub, disputeId)
+	if err != nil {
+		return
+	}
+
+	disputeBytes, err := stub.GetState(key)
+	if err != nil {
+		return
+	}
+	if disputeBytes == nil {
+		return nil, fmt.Errorf("dispute not found with id %s", disputeId)
+	}
+
+	err = json.Unmarshal(disputeBytes, &dispute)
+	return
+}
+
+func (t Ticket) putDispute(stub shim.ChaincodeStubInterface, dispute *entities.Dispute) error {
+	key, err := t.getDisputeKey(stub, dispute.Id)
+	if err != nil {
+		return err
+	}
+	return putJSON(stub, key, dispute)
+}
+
+// canViewDispute returns true for merchant, bank which opened dispute and payer agent
+func canViewDispute(dispute *entities.Dispute, invokerId, invokerRole string) bool {
+	switch invokerRole {
+	case RoleMerchant:
+		return true
+	case RoleBank:
+		return dispute.BankOrgId == invokerId
+	case RoleAgent:
+		return dispute.PayerOrgId == invokerId
+	}
+	return false
+}
+
+func (t Ticket) setDisputeEvent(stub shim.ChaincodeStubInterface, name string, dispute *entities.Dispute,
+	payment *entities.Payment, deadline string) error {
+
+	var hashes []string
+	for _, e := range dispute.Evidence {
+		hashes = append(hashes, e.Hash)
+	}
+
+	eventBytes, err := json.Marshal(entities.DisputeEvent{
+		DisputeId:      dispute.Id,
+		PaymentId:      dispute.PaymentId,
+		PayerOrgId:     dispute.PayerOrgId,
+		BankOrgId:      dispute.BankOrgId,
+		State:          dispute.State,
+		PaymentState:   payment.State,
+		Deadline:       deadline,
+		EvidenceHashes: hashes,
+	})
+	if err != nil {
+		return err
+	}
+	return stub.SetEvent(name, eventBytes)
+}
+
+// Open dispute against debited payment, allowed only from payer bank, arg[0] - dispute open json
+// Dispute id is transaction id, it is returned in response. Dispute of split payment covers legs of opening bank
+func (t Ticket) disputeOpen(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) != 1 {
+		return t.WriteError(fmt.Sprintf("arguments count mismatch: %v", args))
+	}
+
+	var payload entities.DisputeOpenPayload
+	if err := json.Unmarshal([]byte(args[0]), &payload); err != nil {
+		return t.WriteError(err)
+	}
+
+	_, invoker, invokerRole, err := t.getActors(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	payment, err := t.getPayment(stub, payload.PaymentId)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if invokerRole != RoleBank || !isPaymentBank(payment, invoker.OrganizationId) {
+		return t.WriteError(fmt.Sprintf("only payer bank can open dispute, your role is: %s", invokerRole))
+	}
+
+	if payment.State != entities.DebitSuccess {
+		return t.WriteError(fmt.Sprintf("dispute can be opened only for debited payment, payment state: %s", payment.State))
+	}
+	if payment.DisputeId != `` {
+		return t.WriteError(fmt.Sprintf("payment already disputed: %s", payment.DisputeId))
+	}
+
+	config, err := t.getConfig(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	now, err := txNow(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	dispute := &entities.Dispute{
+		Id:         stub.GetTxID(),
+		PaymentId:  payment.Id,
+		PayerOrgId: payment.PayerOrgId,
+		BankOrgId:  invoker.OrganizationId,
+		State:      entities.DisputeOpened,
+		Amount:     disputedAmount(payment, invoker.OrganizationId),
+		Currency:   payment.Currency,
+		Reason:     payload.Reason,
+		OpenedAt:   now.Format(time.RFC3339Nano),
+		RespondBy:  now.Add(disputeWindow(config.DisputeResponseWindow, defaultDisputeResponseWindow)).Format(time.RFC3339),
+	}
+
+	payment.DisputeId = dispute.Id
+	payment.DisputeState = dispute.State
+	payment.UpdatedBy = invoker.OrganizationId
+	payment.UpdatedByRole = invokerRole
+
+	if err = t.putDispute(stub, dispute); err != nil {
+		return t.WriteError(err)
+	}
+	if err = t.putPayment(stub, payment); err != nil {
+		return t.WriteError(err)
+	}
+
+	if err = t.setDisputeEvent(stub, entities.PaymentDisputeOpened, dispute, payment, dispute.RespondBy); err != nil {
+		return t.WriteError(err)
+	}
+
+	disputeBytes, err := json.Marshal(dispute)
+	if err != nil {
+		return t.WriteError(err)
+	}
+	return t.WriteSuccess(disputeBytes)
+}
+
+// Respond to dispute with evidence hashes, allowed only from merchant until response deadline,
+// arg[0] - dispute respond json
+func (t Ticket) disputeRespond(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) != 1 {
+		return t.WriteError(fmt.Sprintf("arguments count mismatch: %v", args))
+	}
+
+	var payload entities.DisputeRespondPayload
+	if err := json.Unmarshal([]byte(args[0]), &payload); err != nil {
+		return t.WriteError(err)
+	}
+
+	_, invoker, invokerRole, err := t.getActors(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if invokerRole != RoleMerchant {
+		return t.WriteError(fmt.Sprintf("only merchant can respond to dispute, your role is: %s", invokerRole))
+	}
+
+	if err = validateEvidence(payload.Evidence); err != nil {
+		return t.WriteError(err)
+	}
+
+	dispute, err := t.getDispute(stub, payload.DisputeId)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if dispute.State != entities.DisputeOpened {
+		return t.WriteError(fmt.Sprintf("dispute is not waiting for response: %s", dispute.State))
+	}
+
+	now, err := txNow(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+	if deadlinePassed(dispute.RespondBy, now) {
+		return t.WriteError(fmt.Sprintf("dispute response deadline %s is over", dispute.RespondBy))
+	}
+
+	config, err := t.getConfig(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	payment, err := t.getPayment(stub, dispute.PaymentId)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	dispute.State = entities.DisputeResponded
+	dispute.Evidence = payload.Evidence
+	dispute.MerchantReply = payload.Reply
+	dispute.RespondedAt = now.Format(time.RFC3339Nano)
+	dispute.ResolveBy = now.Add(disputeWindow(config.DisputeResolutionWindow, defaultDisputeResolutionWindow)).Format(time.RFC3339)
+	payment.DisputeState = dispute.State
+	payment.UpdatedBy = invoker.OrganizationId
+	payment.UpdatedByRole = invokerRole
+
+	if err = t.putDispute(stub, dispute); err != nil {
+		return t.WriteError(err)
+	}
+	if err = t.putPayment(stub, payment); err != nil {
+		return t.WriteError(err)
+	}
+
+	if err = t.setDisputeEvent(stub, entities.PaymentDisputeResponded, dispute, payment, dispute.ResolveBy); err != nil {
+		return t.WriteError(err)
+	}
+	return t.WriteSuccess(nil)
+}
+
+// Resolve dispute, allowed from payer bank which opened it and merchant, arg[0] - dispute resolve json
+// Accepted dispute refunds legs of bank which opened it, funds are returned to payer bank in next settlement batch
+func (t Ticket) disputeResolve(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) != 1 {
+		return t.WriteError(fmt.Sprintf("arguments count mismatch: %v", args))
+	}
+
+	var payload entities.DisputeResolvePayload
+	if err := json.Unmarshal([]byte(args[0]), &payload); err != nil {
+		return t.WriteError(err)
+	}
+
+	_, invoker, invokerRole, err := t.getActors(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	dispute, err := t.getDispute(stub, payload.DisputeId)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if invokerRole == RoleBank && dispute.BankOrgId != invoker.OrganizationId {
+		return t.WriteError(`only bank which opened dispute can resolve it`)
+	}
+
+	now, err := txNow(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if err = canResolveDispute(dispute, invokerRole, payload.State, now); err != nil {
+		return t.WriteError(err)
+	}
+
+	payment, err := t.getPayment(stub, dispute.PaymentId)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if payload.State == entities.DisputeAccepted {
+		if payment.State != entities.DebitSuccess {
+			return t.WriteError(fmt.Sprintf("disputed payment can't be refunded in state: %s", payment.State))
+		}
+		if err = checkAmountAccess(stub, invokerRole, dispute.Amount); err != nil {
+			return t.WriteError(err)
+		}
+		refundDisputedLegs(payment, dispute.BankOrgId)
+		if payment.State == entities.Refunded {
+			payment.CancelReason = entities.CancelReasonChargeback
+			payment.CanceledAt = now.Format(time.RFC3339Nano)
+		}
+	}
+
+	dispute.State = payload.State
+	dispute.Resolution = payload.Resolution
+	dispute.ResolvedAt = now.Format(time.RFC3339Nano)
+	dispute.ResolvedBy = invoker.OrganizationId
+	dispute.ResolvedByRole = invokerRole
+	payment.DisputeState = dispute.State
+	payment.UpdatedBy = invoker.OrganizationId
+	payment.UpdatedByRole = invokerRole
+
+	if err = t.putDispute(stub, dispute); err != nil {
+		return t.WriteError(err)
+	}
+	if err = t.putPayment(stub, payment); err != nil {
+		return t.WriteError(err)
+	}
+
+	if err = t.setDisputeEvent(stub, entities.PaymentDisputeResolved, dispute, payment, ``); err != nil {
+		return t.WriteError(err)
+	}
+	return t.WriteSuccess(nil)
+}
+
+// Get dispute, allowed for merchant and involved agent and bank, arg[0] - dispute id
+func (t Ticket) disputeGet(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) != 1 {
+		return t.WriteError(fmt.Sprintf("arguments count mismatch: %v", args))
+	}
+
+	_, invoker, invokerRole, err := t.getActors(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if invokerRole == RoleUnknown {
+		return t.WriteError(fmt.Sprintf("disputes are available only for merchant, bank and agent, your role is: %s", invokerRole))
+	}
+
+	dispute, err := t.getDispute(stub, args[0])
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if !canViewDispute(dispute, invoker.OrganizationId, invokerRole) {
+		return t.WriteError(fmt.Sprintf("dispute not found with id %s", args[0]))
+	}
+
+	disputeBytes, err := json.Marshal(dispute)
+	if err != nil {
+		return t.WriteError(err)
+	}
+	return t.WriteSuccess(disputeBytes)
+}
+
+// List disputes visible to invoker, optional arg[0] - payment id, empty id lists disputes of all payments
+func (t Ticket) disputeList(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) > 1 {
+		return t.WriteError(fmt.Sprintf("arguments count mismatch: %v", args))
+	}
+
+	_, invoker, invokerRole, err := t.getActors(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if invokerRole == RoleUnknown {
+		return t.WriteError(fmt.Sprintf("disputes are available only for merchant, bank and agent, your role is: %s", invokerRole))
+	}
+
+	disputes := []entities.Dispute{}
+
+	iter, err := stub.GetStateByPartialCompositeKey(t.disputeKey, []string{})
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	defer iter.Close()
+	for iter.HasNext() {
+		v, err := iter.Next()
+		if err != nil {
+			return t.WriteError(err)
+		}
+		var dispute entities.Dispute
+		if err = json.Unmarshal(v.Value, &dispute); err != nil {
+			return t.WriteError(err)
+		}
+		if len(args) == 1 && args[0] != `` && dispute.PaymentId != args[0] {
+			continue
+		}
+		if canViewDispute(&dispute, invoker.OrganizationId, invokerRole) {
+			disputes = append(disputes, dispute)
+		}
+	}
+
+	result, err := json.Marshal(disputes)
+	if err != nil {
+		return t.WriteError(err)
+	}
+	return t.WriteSuccess(result)
+}
@@ -0,0 +1,144 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"s7ab-platform-hyperledger/platform/s7ticket/entities"
+)
+
+const bpsDivider = 10000
+
+// getFeeSchedule returns current fee schedule, empty schedule if merchant not set it yet
+func (t Ticket) getFeeSchedule(stub shim.ChaincodeStubInterface) (schedule entities.FeeSchedule, err error) {
+	scheduleBytes, err := stub.GetState(t.feeScheduleKey)
+	if err != nil || scheduleBytes == nil {
+		return
+	}
+	err = json.Unmarshal(scheduleBytes, &schedule)
+	return
+}
+
+func validateFeeSchedule(schedule entities.FeeSchedule) error {
+	ids := make(map[string]bool)
+	for _, rule := range schedule.Rules {
+		if rule.Id == `` {
+			return errors.New(`fee rule id is empty`)
+		}
+		if ids[rule.Id] {
+			return fmt.Errorf("fee rule id is duplicated: %s", rule.Id)
+		}
+		ids[rule.Id] = true
+
+		if rule.MaxAmount != 0 && rule.MaxAmount < rule.MinAmount {
+			return fmt.Errorf("fee rule %s max amount is less than min amount", rule.Id)
+		}
+		if rule.CommissionBps+rule.MerchantFeeBps > bpsDivider {
+			return fmt.Errorf("fee rule %s percents exceed 100%%", rule.Id)
+		}
+	}
+	return nil
+}
+
+func feeRuleMatches(rule entities.FeeRule, payment *entities.Payment) bool {
+	if rule.InternationalFlight != nil && *rule.InternationalFlight != payment.InternationalFlight {
+		return false
+	}
+	if rule.PaymentType != `` && rule.PaymentType != payment.PaymentType {
+		return false
+	}
+	if rule.Currency != `` && rule.Currency != payment.Currency {
+		return false
+	}
+	if payment.Amount < rule.MinAmount {
+		return false
+	}
+	return rule.MaxAmount == 0 || payment.Amount <= rule.MaxAmount
+}
+
+// calculateFees applies first matched fee rule to payment amount
+// Percents are rounded down, so result is same on every endorsing peer
+func calculateFees(schedule entities.FeeSchedule, payment *entities.Payment) (fees entities.PaymentFees, err error) {
+	fees.FeeScheduleVersion = schedule.Version
+	fees.NetAmount = payment.Amount
+
+	for _, rule := range schedule.Rules {
+		if !feeRuleMatches(rule, payment) {
+			continue
+		}
+
+		fees.FeeRuleId = rule.Id
+		fees.AgentCommission = payment.Amount*rule.CommissionBps/bpsDivider + rule.CommissionFixed
+		fees.MerchantFee = payment.Amount*rule.MerchantFeeBps/bpsDivider + rule.MerchantFeeFixed
+
+		if fees.AgentCommission+fees.MerchantFee > payment.Amount {
+			return fees, fmt.Errorf("fees exceed payment amount, rule: %s", rule.Id)
+		}
+		fees.NetAmount = payment.Amount - fees.AgentCommission - fees.MerchantFee
+		return
+	}
+	return
+}
+
+// Set fee schedule, allowed only from merchant, arg[0] - fee schedule json
+// Schedule version is incremented on every update
+func (t Ticket) feesSet(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) != 1 {
+		return t.WriteError(fmt.Sprintf("arguments count mismatch: %v", args))
+	}
+
+	_, _, invokerRole, err := t.getActors(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if invokerRole != RoleMerchant {
+		return t.WriteError(fmt.Sprintf("only merchant can set fee schedule, your role is: %s", invokerRole))
+	}
+
+	var schedule entities.FeeSchedule
+	if err = json.Unmarshal([]byte(args[0]), &schedule); err != nil {
+		return t.WriteError(err)
+	}
+
+	if err = validateFeeSchedule(schedule); err != nil {
+		return t.WriteError(err)
+	}
+
+	current, err := t.getFeeSchedule(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+	schedule.Version = current.Version + 1
+
+	scheduleBytes, err := json.Marshal(schedule)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if err = stub.PutState(t.feeScheduleKey, scheduleBytes); err != nil {
+		return t.WriteError(err)
+	}
+
+	if err = stub.SetEvent(entities.FeeScheduleUpdated, scheduleBytes); err != nil {
+		return t.WriteError(err)
+	}
+	return t.WriteSuccess(scheduleBytes)
+}
+
+func (t Ticket) feesGet(stub shim.ChaincodeStubInterface) pb.Response {
+	schedule, err := t.getFeeSchedule(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	scheduleBytes, err := json.Marshal(schedule)
+	if err != nil {
+		return t.WriteError(err)
+	}
+	return t.WriteSuccess(scheduleBytes)
+}
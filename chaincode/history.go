@@ -0,0 +1,146 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"s7ab-platform-hyperledger/platform/s7ticket/entities"
+)
+
+// history returns typed payment timeline, arg[0] - payment id
+// Every entry holds previous and new payment state with list of changed fields
+func (t Ticket) history(stub shim.ChaincodeStubInterface) pb.Response {
+	key, err := t.GetKey(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	iterator, err := stub.GetHistoryForKey(key)
+	if err != nil {
+		return t.WriteError(err)
+	}
+	defer iterator.Close()
+
+	var (
+		entries  []entities.PaymentHistoryEntry
+		previous *entities.Payment
+	)
+
+	for iterator.HasNext() {
+		modification, err := iterator.Next()
+		if err != nil {
+			return t.WriteError(err)
+		}
+
+		entry := entities.PaymentHistoryEntry{
+			TxId:          modification.TxId,
+			IsDelete:      modification.IsDelete,
+			PreviousState: previous,
+		}
+
+		if modification.Timestamp != nil {
+			entry.Timestamp = formatTimestamp(modification.Timestamp)
+		}
+
+		if !modification.IsDelete {
+			var current entities.Payment
+			if err = json.Unmarshal(modification.Value, &current); err != nil {
+				return t.WriteError(err)
+			}
+			entry.State = &current
+			entry.Actor = current.UpdatedBy
+			entry.ActorRole = current.UpdatedByRole
+		}
+
+		if entry.Changes, err = diffPayments(entry.PreviousState, entry.State); err != nil {
+			return t.WriteError(err)
+		}
+
+		entries = append(entries, entry)
+		previous = entry.State
+	}
+
+	entriesBytes, err := json.Marshal(entries)
+	if err != nil {
+		return t.WriteError(err)
+	}
+	return t.WriteSuccess(entriesBytes)
+}
+
+// diffPayments returns changed payment fields sorted by json field name
+// nil payment is treated as absent state, so creation and deletion report every field
+func diffPayments(from, to *entities.Payment) (changes []entities.PaymentFieldChange, err error) {
+	fromFields, err := paymentFields(from)
+	if err != nil {
+		return
+	}
+	toFields, err := paymentFields(to)
+	if err != nil {
+		return
+	}
+
+	var names []string
+	for name := range fromFields {
+		names = append(names, name)
+	}
+	for name := range toFields {
+		if _, ok := fromFields[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if reflect.DeepEqual(fromFields[name], toFields[name]) {
+			continue
+		}
+		changes = append(changes, entities.PaymentFieldChange{
+			Field: name,
+			From:  fromFields[name],
+			To:    toFields[name],
+		})
+	}
+	return
+}
+
+func paymentFields(payment *entities.Payment) (fields map[string]interface{}, err error) {
+	fields = make(map[string]interface{})
+	if payment == nil {
+		return
+	}
+
+	paymentBytes, err := json.Marshal(payment)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(paymentBytes, &fields)
+	return
+}
+
+// formatTimestamp formats ledger timestamp as RFC 3339 with nanoseconds
+func formatTimestamp(ts *timestamp.Timestamp) string {
+	return time.Unix(ts.Seconds, int64(ts.Nanos)).UTC().Format(time.RFC3339Nano)
+}
+
+// txNow returns transaction timestamp in UTC
+func txNow(stub shim.ChaincodeStubInterface) (time.Time, error) {
+	ts, err := stub.GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(ts.Seconds, int64(ts.Nanos)).UTC(), nil
+}
+
+// txTime returns transaction timestamp formatted by formatTimestamp
+func txTime(stub shim.ChaincodeStubInterface) (string, error) {
+	ts, err := stub.GetTxTimestamp()
+	if err != nil {
+		return ``, err
+	}
+	return formatTimestamp(ts), nil
+}
@@ -0,0 +1,292 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"s7ab-platform-hyperledger/platform/s7ticket/entities"
+)
+
+// holdStates are changed only with /hold routes, they need expiry and capture amount
+var holdStates = map[entities.PaymentState]bool{
+	entities.FundsHeld:    true,
+	entities.HoldReleased: true,
+}
+
+// holdExpired returns true if hold expiry is before now
+func holdExpired(hold *entities.PaymentHold, now time.Time) bool {
+	expiresAt, err := time.Parse(time.RFC3339, hold.ExpiresAt)
+	return err != nil || !now.Before(expiresAt)
+}
+
+// capturePayment debits amount of hold, zero amount captures full hold
+// Payment amount becomes captured amount and fees are recalculated for it
+func capturePayment(payment *entities.Payment, amount uint, schedule entities.FeeSchedule) error {
+	if amount == 0 {
+		amount = payment.Hold.Amount
+	}
+	if amount > payment.Hold.Amount {
+		return fmt.Errorf("capture amount %d exceeds hold amount %d", amount, payment.Hold.Amount)
+	}
+
+	payment.Hold.Captured = amount
+	payment.Amount = amount
+
+	fees, err := calculateFees(schedule, payment)
+	if err != nil {
+		return err
+	}
+	payment.PaymentFees = fees
+	payment.State = entities.DebitRequest
+	return nil
+}
+
+// checkHoldAccess checks hold change as updateState checks state change: point of sale of agent,
+// amount and state access of invoker certificate
+func (t Ticket) checkHoldAccess(stub shim.ChaincodeStubInterface, payment *entities.Payment, invokerRole string,
+	state entities.PaymentState, amount uint) error {
+
+	if invokerRole == RoleAgent {
+		if err := t.checkSubAgentPayment(stub, payment, ``); err != nil {
+			return err
+		}
+	}
+
+	if err := checkAmountAccess(stub, invokerRole, amount); err != nil {
+		return err
+	}
+
+	config, err := t.getConfig(stub)
+	if err != nil {
+		return err
+	}
+	return checkStateAccess(stub, config, invokerRole, state)
+}
+
+func (t Ticket) setHoldEvent(stub shim.ChaincodeStubInterface, name string, payment *entities.Payment) error {
+	eventBytes, err := json.Marshal(entities.PaymentHoldEvent{
+		PaymentId:       payment.Id,
+		PayerOrgId:      payment.PayerOrgId,
+		PayerBankOrgId:  payment.PayerBankOrgId,
+		PayerBankOrgIds: paymentBanks(payment),
+		State:           payment.State,
+		Hold:            *payment.Hold,
+	})
+	if err != nil {
+		return err
+	}
+	return stub.SetEvent(name, eventBytes)
+}
+
+// Confirm hold of payment funds, allowed only from payer bank, arg[0] - hold confirm json
+// Replaces CheckFundsSuccess for payments which agent debits later
+func (t Ticket) holdConfirm(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) != 1 {
+		return t.WriteError(fmt.Sprintf("arguments count mismatch: %v", args))
+	}
+
+	var payload entities.HoldConfirmPayload
+	if err := json.Unmarshal([]byte(args[0]), &payload); err != nil {
+		return t.WriteError(err)
+	}
+
+	_, invoker, invokerRole, err := t.getActors(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	payment, err := t.getPayment(stub, payload.PaymentId)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if len(payment.Legs) > 0 {
+		return t.WriteError(`hold is not supported for split payment`)
+	}
+
+	if err = t.canChangePaymentState(payment, entities.FundsHeld, invoker, invokerRole); err != nil {
+		return t.WriteError(err)
+	}
+
+	if err = t.checkHoldAccess(stub, payment, invokerRole, entities.FundsHeld, payment.Amount); err != nil {
+		return t.WriteError(err)
+	}
+
+	now, err := txNow(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	expiresAt, err := time.Parse(time.RFC3339, payload.ExpiresAt)
+	if err != nil {
+		return t.WriteError(fmt.Sprintf("invalid hold expiry: %s", err))
+	}
+	if !now.Before(expiresAt) {
+		return t.WriteError(`hold expiry is in the past`)
+	}
+
+	payment.Hold = &entities.PaymentHold{
+		BankOrgId:   invoker.OrganizationId,
+		Amount:      payment.Amount,
+		ExpiresAt:   expiresAt.UTC().Format(time.RFC3339),
+		ConfirmedAt: now.Format(time.RFC3339Nano),
+	}
+	payment.State = entities.FundsHeld
+	payment.UpdatedBy = invoker.OrganizationId
+	payment.UpdatedByRole = invokerRole
+
+	if err = t.putPayment(stub, payment); err != nil {
+		return t.WriteError(err)
+	}
+
+	if err = t.setHoldEvent(stub, entities.PaymentHoldConfirmed, payment); err != nil {
+		return t.WriteError(err)
+	}
+	return t.WriteSuccess(nil)
+}
+
+// Capture held funds, allowed only from payer agent before hold expiry, arg[0] - hold capture json
+// Payment moves to DebitRequest with captured amount, capture above approval threshold of agent needs second signer
+func (t Ticket) holdCapture(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) != 1 {
+		return t.WriteError(fmt.Sprintf("arguments count mismatch: %v", args))
+	}
+
+	var payload entities.HoldCapturePayload
+	if err := json.Unmarshal([]byte(args[0]), &payload); err != nil {
+		return t.WriteError(err)
+	}
+
+	_, invoker, invokerRole, err := t.getActors(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	payment, err := t.getPayment(stub, payload.PaymentId)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if invokerRole != RoleAgent || invoker.OrganizationId != payment.PayerOrgId {
+		return t.WriteError(fmt.Sprintf("only payer agent can capture hold, your role is: %s", invokerRole))
+	}
+
+	if payment.State != entities.FundsHeld {
+		return t.WriteError(fmt.Sprintf("can't capture payment in state: %s", payment.State))
+	}
+
+	now, err := txNow(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+	if holdExpired(payment.Hold, now) {
+		return t.WriteError(fmt.Sprintf("hold expired at %s", payment.Hold.ExpiresAt))
+	}
+
+	schedule, err := t.getFeeSchedule(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	// capture is debit request of agent, captured amount above approval threshold needs second signer
+	captured := *payment
+	hold := *payment.Hold
+	captured.Hold = &hold
+	if err = capturePayment(&captured, payload.Amount, schedule); err != nil {
+		return t.WriteError(err)
+	}
+
+	if err = t.checkHoldAccess(stub, payment, invokerRole, entities.DebitRequest, captured.Amount); err != nil {
+		return t.WriteError(err)
+	}
+
+	if pending, err := t.approveStateChange(stub, &captured, entities.DebitRequest, nil, invoker, invokerRole); err != nil {
+		return t.WriteError(err)
+	} else if pending {
+		payment.PendingApproval = captured.PendingApproval
+		payment.UpdatedBy = invoker.OrganizationId
+		payment.UpdatedByRole = invokerRole
+		approvalBytes, err := t.putPendingApproval(stub, payment)
+		if err != nil {
+			return t.WriteError(err)
+		}
+		return t.WriteSuccess(approvalBytes)
+	}
+
+	payment = &captured
+	payment.Hold.CapturedAt = now.Format(time.RFC3339Nano)
+	payment.UpdatedBy = invoker.OrganizationId
+	payment.UpdatedByRole = invokerRole
+
+	if err = t.putPayment(stub, payment); err != nil {
+		return t.WriteError(err)
+	}
+
+	if err = t.setHoldEvent(stub, entities.PaymentHoldCaptured, payment); err != nil {
+		return t.WriteError(err)
+	}
+	return t.WriteSuccess(nil)
+}
+
+// Release held funds, allowed from payer agent, payer bank and merchant, arg[0] - payment id
+// Payer bank can release only expired hold
+func (t Ticket) holdRelease(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) != 1 {
+		return t.WriteError(fmt.Sprintf("arguments count mismatch: %v", args))
+	}
+
+	_, invoker, invokerRole, err := t.getActors(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	payment, err := t.getPayment(stub, args[0])
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if payment.State != entities.FundsHeld {
+		return t.WriteError(fmt.Sprintf("can't release payment in state: %s", payment.State))
+	}
+
+	now, err := txNow(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	switch {
+	case invokerRole == RoleAgent && invoker.OrganizationId == payment.PayerOrgId:
+	case invokerRole == RoleMerchant:
+	case invokerRole == RoleBank && isPaymentBank(payment, invoker.OrganizationId):
+		if !holdExpired(payment.Hold, now) {
+			return t.WriteError(`bank can release only expired hold`)
+		}
+	default:
+		return t.WriteError(fmt.Sprintf("only payer agent, payer bank or merchant can release hold, your role is: %s", invokerRole))
+	}
+
+	if err = t.checkHoldAccess(stub, payment, invokerRole, entities.HoldReleased, payment.Amount); err != nil {
+		return t.WriteError(err)
+	}
+
+	payment.State = entities.HoldReleased
+	payment.Hold.ReleasedBy = invoker.OrganizationId
+	payment.Hold.ReleasedAt = now.Format(time.RFC3339Nano)
+	payment.UpdatedBy = invoker.OrganizationId
+	payment.UpdatedByRole = invokerRole
+
+	if err = t.putPayment(stub, payment); err != nil {
+		return t.WriteError(err)
+	}
+
+	if err = t.setHoldEvent(stub, entities.PaymentHoldReleased, payment); err != nil {
+		return t.WriteError(err)
+	}
+	return t.WriteSuccess(nil)
+}
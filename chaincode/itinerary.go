@@ -0,0 +1,174 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"s7ab-platform-hyperledger/platform/s7ticket/entities"
+)
+
+// IATA and ISO code formats of itinerary
+var (
+	pnrFormat           = regexp.MustCompile(entities.PNRPattern)
+	carrierFormat       = regexp.MustCompile(`^([A-Z][A-Z0-9]|[0-9][A-Z])$`)
+	flightNumberFormat  = regexp.MustCompile(`^[0-9]{1,4}[A-Z]?$`)
+	airportFormat       = regexp.MustCompile(`^[A-Z]{3}$`)
+	countryFormat       = regexp.MustCompile(`^[A-Z]{2}$`)
+	passengerNameFormat = regexp.MustCompile(`^[A-Z][A-Z -]*$`)
+	taxCodeFormat       = regexp.MustCompile(`^[A-Z0-9]{2}$`)
+)
+
+const departureDateLayout = `2006-01-02`
+
+var passengerTypes = map[string]bool{
+	entities.PassengerAdult:  true,
+	entities.PassengerChild:  true,
+	entities.PassengerInfant: true,
+}
+
+func validatePassenger(i int, p entities.Passenger) error {
+	if !passengerNameFormat.MatchString(p.LastName) || !passengerNameFormat.MatchString(p.FirstName) {
+		return fmt.Errorf("passenger %d: name must be in uppercase latin letters", i)
+	}
+	if !passengerTypes[p.Type] {
+		return fmt.Errorf("passenger %d: unknown passenger type: %s", i, p.Type)
+	}
+	return nil
+}
+
+func validateSegment(i int, s entities.FlightSegment) error {
+	switch {
+	case !carrierFormat.MatchString(s.Carrier):
+		return fmt.Errorf("segment %d: invalid carrier code: %s", i, s.Carrier)
+	case !flightNumberFormat.MatchString(s.FlightNumber):
+		return fmt.Errorf("segment %d: invalid flight number: %s", i, s.FlightNumber)
+	case !airportFormat.MatchString(s.Origin) || !airportFormat.MatchString(s.Destination):
+		return fmt.Errorf("segment %d: invalid airport code: %s-%s", i, s.Origin, s.Destination)
+	case s.Origin == s.Destination:
+		return fmt.Errorf("segment %d: origin and destination are the same: %s", i, s.Origin)
+	case !countryFormat.MatchString(s.OriginCountry) || !countryFormat.MatchString(s.DestinationCountry):
+		return fmt.Errorf("segment %d: invalid country code: %s-%s", i, s.OriginCountry, s.DestinationCountry)
+	}
+	return nil
+}
+
+// validateItinerary checks itinerary codes, segments order and fare breakdown of payment amount
+func validateItinerary(itinerary entities.Itinerary, amount uint) error {
+	if !pnrFormat.MatchString(itinerary.PNR) {
+		return fmt.Errorf("invalid pnr: %s", itinerary.PNR)
+	}
+
+	if len(itinerary.Passengers) == 0 {
+		return errors.New(`itinerary has no passengers`)
+	}
+	for i, p := range itinerary.Passengers {
+		if err := validatePassenger(i, p); err != nil {
+			return err
+		}
+	}
+
+	if len(itinerary.Segments) == 0 {
+		return errors.New(`itinerary has no segments`)
+	}
+	var previous time.Time
+	for i, s := range itinerary.Segments {
+		if err := validateSegment(i, s); err != nil {
+			return err
+		}
+		departure, err := time.Parse(departureDateLayout, s.DepartureDate)
+		if err != nil {
+			return fmt.Errorf("segment %d: invalid departure date: %s", i, s.DepartureDate)
+		}
+		if departure.Before(previous) {
+			return fmt.Errorf("segment %d: departs before previous segment", i)
+		}
+		previous = departure
+	}
+
+	for _, tax := range itinerary.Fare.Taxes {
+		if !taxCodeFormat.MatchString(tax.Code) {
+			return fmt.Errorf("invalid tax code: %s", tax.Code)
+		}
+	}
+	if total := itinerary.Fare.Total(); total != amount {
+		return fmt.Errorf("fare total %d doesn't match payment amount %d", total, amount)
+	}
+	return nil
+}
+
+// putPNRIndex adds payment to index of payments by PNR
+func (t Ticket) putPNRIndex(stub shim.ChaincodeStubInterface, payment *entities.Payment) error {
+	key, err := stub.CreateCompositeKey(t.pnrIndexKey, []string{payment.Itinerary.PNR, payment.Id})
+	if err != nil {
+		return err
+	}
+	return stub.PutState(key, []byte{0x00})
+}
+
+// canViewPayment returns true for merchant, payer agent and bank funding payment
+func canViewPayment(payment *entities.Payment, invokerId, invokerRole string) bool {
+	switch invokerRole {
+	case RoleMerchant:
+		return true
+	case RoleAgent:
+		return payment.PayerOrgId == invokerId
+	case RoleBank:
+		return isPaymentBank(payment, invokerId)
+	}
+	return false
+}
+
+// Search payments by PNR of itinerary, arg[0] - pnr
+// Only payments visible to invoker are returned
+func (t Ticket) itinerarySearch(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) != 1 {
+		return t.WriteError(fmt.Sprintf("arguments count mismatch: %v", args))
+	}
+
+	_, invoker, invokerRole, err := t.getActors(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if invokerRole == RoleUnknown {
+		return t.WriteError(fmt.Sprintf("itinerary search is available only for merchant, bank and agent, your role is: %s", invokerRole))
+	}
+
+	payments := []entities.Payment{}
+
+	iter, err := stub.GetStateByPartialCompositeKey(t.pnrIndexKey, []string{args[0]})
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	defer iter.Close()
+	for iter.HasNext() {
+		v, err := iter.Next()
+		if err != nil {
+			return t.WriteError(err)
+		}
+		_, attrs, err := stub.SplitCompositeKey(v.Key)
+		if err != nil {
+			return t.WriteError(err)
+		}
+		payment, err := t.getPayment(stub, attrs[1])
+		if err != nil {
+			return t.WriteError(err)
+		}
+		if canViewPayment(payment, invoker.OrganizationId, invokerRole) {
+			payments = append(payments, *payment)
+		}
+	}
+
+	result, err := json.Marshal(payments)
+	if err != nil {
+		return t.WriteError(err)
+	}
+	return t.WriteSuccess(result)
+}
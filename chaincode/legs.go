@@ -0,0 +1,312 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	platformEntities "s7ab-platform-hyperledger/platform/core/entities"
+	"s7ab-platform-hyperledger/platform/s7ticket/entities"
+)
+
+// legStateOrder is order of leg states without failures, payment state is least advanced leg state
+var legStateOrder = map[entities.PaymentState]int{
+	entities.CheckFundsRequest:    0,
+	entities.CheckFundsInProgress: 1,
+	entities.CheckFundsSuccess:    2,
+	entities.DebitRequest:         3,
+	entities.DebitInProgress:      4,
+	entities.DebitSuccess:         5,
+}
+
+// legFailStates are failed payment states, legs of other banks are still processed in them
+var legFailStates = map[entities.PaymentState]bool{
+	entities.CheckFundsFail: true,
+	entities.DebitFail:      true,
+}
+
+func (t Ticket) getAgentAccountKey(stub shim.ChaincodeStubInterface, agentId, bankOrgId, account string) (string, error) {
+	return stub.CreateCompositeKey(t.agentAccountKey, []string{agentId, bankOrgId, account})
+}
+
+// getAgentAccount returns account registered by agent, nil if account is not registered
+func (t Ticket) getAgentAccount(stub shim.ChaincodeStubInterface, agentId, bankOrgId, account string) (*entities.AgentAccount, error) {
+	key, err := t.getAgentAccountKey(stub, agentId, bankOrgId, account)
+	if err != nil {
+		return nil, err
+	}
+	accountBytes, err := stub.GetState(key)
+	if err != nil || accountBytes == nil {
+		return nil, err
+	}
+	var agentAccount entities.AgentAccount
+	if err = json.Unmarshal(accountBytes, &agentAccount); err != nil {
+		return nil, err
+	}
+	return &agentAccount, nil
+}
+
+// validatePaymentLegs checks that leg banks are banks, leg accounts are settlement accounts of agent
+// and leg amounts sum to payment amount
+// Leg account is agent settlement account at agent bank or account registered by agent and confirmed by leg bank
+func (t Ticket) validatePaymentLegs(stub shim.ChaincodeStubInterface, invoker *platformEntities.Member,
+	payload entities.PaymentCreatePayload) error {
+	var total uint
+	for i, leg := range payload.Legs {
+		if leg.Amount == 0 {
+			return fmt.Errorf("payment leg %d amount is zero", i+1)
+		}
+		if leg.Account == `` {
+			return fmt.Errorf("payment leg %d account is empty", i+1)
+		}
+		if _, err := t.getBank(stub, leg.BankOrgId); err != nil {
+			return fmt.Errorf("payment leg %d bank: %s", i+1, err)
+		}
+		if leg.BankOrgId != invoker.BankOrganizationId || leg.Account != invoker.Requisites.SettlementAccount {
+			agentAccount, err := t.getAgentAccount(stub, invoker.OrganizationId, leg.BankOrgId, leg.Account)
+			if err != nil {
+				return err
+			}
+			if agentAccount == nil || !agentAccount.Confirmed {
+				return fmt.Errorf("payment leg %d account %s isn't confirmed settlement account of agent at bank %s",
+					i+1, leg.Account, leg.BankOrgId)
+			}
+		}
+		total += leg.Amount
+	}
+
+	if total != payload.Amount {
+		return fmt.Errorf("payment legs amount %d mismatch payment amount %d", total, payload.Amount)
+	}
+	return nil
+}
+
+// newPaymentLegs returns legs in CheckFundsRequest state, leg ids are numbers starting from 1
+func newPaymentLegs(payload []entities.PaymentLegPayload) (legs []entities.PaymentLeg) {
+	for i, leg := range payload {
+		legs = append(legs, entities.PaymentLeg{
+			Id:        strconv.Itoa(i + 1),
+			BankOrgId: leg.BankOrgId,
+			Account:   leg.Account,
+			Amount:    leg.Amount,
+			State:     entities.CheckFundsRequest,
+		})
+	}
+	return
+}
+
+// derivePaymentState returns failed state if any leg failed, least advanced leg state otherwise
+func derivePaymentState(legs []entities.PaymentLeg) entities.PaymentState {
+	state := entities.DebitSuccess
+	for _, leg := range legs {
+		if leg.State == entities.CheckFundsFail || leg.State == entities.DebitFail {
+			return leg.State
+		}
+		if legStateOrder[leg.State] < legStateOrder[state] {
+			state = leg.State
+		}
+	}
+	return state
+}
+
+// changeLegState moves leg of invoker bank to state and derives payment state from legs
+// Leg is found by id or as the only leg of invoker bank, legs are changed after another leg failed
+// to let their banks finish debit before payment is retried or canceled
+func (t Ticket) changeLegState(payment *entities.Payment, legId string, state entities.PaymentState,
+	invoker *platformEntities.Member) (*entities.PaymentLeg, error) {
+
+	if _, ok := legStateOrder[payment.State]; !ok && !legFailStates[payment.State] {
+		return nil, fmt.Errorf("payment legs can't be changed in state: %s", payment.State)
+	}
+
+	var leg *entities.PaymentLeg
+	for i := range payment.Legs {
+		if payment.Legs[i].BankOrgId != invoker.OrganizationId || (legId != `` && payment.Legs[i].Id != legId) {
+			continue
+		}
+		if leg != nil {
+			return nil, errors.New(`bank funds several legs of payment, leg_id is required`)
+		}
+		leg = &payment.Legs[i]
+	}
+	if leg == nil {
+		return nil, errors.New(`bank can't process leg of another bank`)
+	}
+
+	if !t.roleCanChangeState(RoleBank, leg.State) {
+		return nil, fmt.Errorf(`role can't change leg from state: %s, role: %s`, leg.State, RoleBank)
+	}
+
+	if err := t.createFSM(leg.State).Event(string(state)); err != nil {
+		return nil, fmt.Errorf("can't change leg %s state from: %s, to: %s", leg.Id, leg.State, state)
+	}
+
+	leg.State = state
+	payment.State = derivePaymentState(payment.Legs)
+	return leg, nil
+}
+
+// cancelPaymentLegs moves legs of canceled payment, debited legs are refunded and the rest are canceled
+// Payment can't be canceled while any leg bank is debiting
+func cancelPaymentLegs(payment *entities.Payment) error {
+	for _, leg := range payment.Legs {
+		if leg.State == entities.DebitRequest || leg.State == entities.DebitInProgress {
+			return fmt.Errorf("payment leg %s is debited by bank %s, wait for debit result", leg.Id, leg.BankOrgId)
+		}
+	}
+	for i := range payment.Legs {
+		if payment.Legs[i].State == entities.DebitSuccess {
+			payment.Legs[i].State = entities.Refunded
+		} else {
+			payment.Legs[i].State = entities.TicketCanceled
+		}
+	}
+	return nil
+}
+
+// Register settlement account of agent at bank for split payment legs, allowed only from agent
+// arg[0] - json of account payload, account funds legs after bank confirmed it
+func (t Ticket) agentAccountAdd(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) != 1 {
+		return t.WriteError(fmt.Sprintf("arguments count mismatch: %v", args))
+	}
+
+	_, invoker, invokerRole, err := t.getActors(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+	if invokerRole != RoleAgent {
+		return t.WriteError(fmt.Sprintf("only agent can add settlement account, your role is: %s", invokerRole))
+	}
+
+	var payload entities.AgentAccountPayload
+	if err = json.Unmarshal([]byte(args[0]), &payload); err != nil {
+		return t.WriteError(err)
+	}
+	if payload.Account == `` {
+		return t.WriteError(`settlement account is empty`)
+	}
+	if _, err = t.getBank(stub, payload.BankOrgId); err != nil {
+		return t.WriteError(err)
+	}
+
+	key, err := t.getAgentAccountKey(stub, invoker.OrganizationId, payload.BankOrgId, payload.Account)
+	if err != nil {
+		return t.WriteError(err)
+	}
+	if existing, err := stub.GetState(key); err != nil {
+		return t.WriteError(err)
+	} else if existing != nil {
+		return t.WriteError(fmt.Sprintf("settlement account %s at bank %s already added", payload.Account, payload.BankOrgId))
+	}
+
+	agentAccount := entities.AgentAccount{
+		AgentId:   invoker.OrganizationId,
+		BankOrgId: payload.BankOrgId,
+		Account:   payload.Account,
+	}
+	if err = putJSON(stub, key, agentAccount); err != nil {
+		return t.WriteError(err)
+	}
+
+	accountBytes, err := json.Marshal(agentAccount)
+	if err != nil {
+		return t.WriteError(err)
+	}
+	return t.WriteSuccess(accountBytes)
+}
+
+// Confirm settlement account of agent, allowed only from bank of account, arg[0] - agent MSP id, arg[1] - account
+func (t Ticket) agentAccountConfirm(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) != 2 {
+		return t.WriteError(fmt.Sprintf("arguments count mismatch: %v", args))
+	}
+
+	_, invoker, invokerRole, err := t.getActors(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+	if invokerRole != RoleBank {
+		return t.WriteError(fmt.Sprintf("only bank can confirm settlement account, your role is: %s", invokerRole))
+	}
+
+	agentAccount, err := t.getAgentAccount(stub, args[0], invoker.OrganizationId, args[1])
+	if err != nil {
+		return t.WriteError(err)
+	}
+	if agentAccount == nil {
+		return t.WriteError(fmt.Sprintf("settlement account %s of agent %s at bank %s not found",
+			args[1], args[0], invoker.OrganizationId))
+	}
+
+	agentAccount.Confirmed = true
+	key, err := t.getAgentAccountKey(stub, agentAccount.AgentId, agentAccount.BankOrgId, agentAccount.Account)
+	if err != nil {
+		return t.WriteError(err)
+	}
+	if err = putJSON(stub, key, agentAccount); err != nil {
+		return t.WriteError(err)
+	}
+
+	accountBytes, err := json.Marshal(agentAccount)
+	if err != nil {
+		return t.WriteError(err)
+	}
+	return t.WriteSuccess(accountBytes)
+}
+
+// List settlement accounts of agent, agent lists own accounts, bank lists accounts at bank
+// Merchant lists accounts of agent in arg[0] or all accounts
+func (t Ticket) agentAccountList(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+
+	_, invoker, invokerRole, err := t.getActors(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	attributes := []string{}
+	switch invokerRole {
+	case RoleAgent:
+		attributes = append(attributes, invoker.OrganizationId)
+	case RoleMerchant, RoleBank:
+		if len(args) > 0 && args[0] != `` {
+			attributes = append(attributes, args[0])
+		}
+	default:
+		return t.WriteError(fmt.Sprintf("unknown invoker can't list settlement accounts, your role is: %s", invokerRole))
+	}
+
+	iter, err := stub.GetStateByPartialCompositeKey(t.agentAccountKey, attributes)
+	if err != nil {
+		return t.WriteError(err)
+	}
+	defer iter.Close()
+
+	accounts := []entities.AgentAccount{}
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return t.WriteError(err)
+		}
+		var agentAccount entities.AgentAccount
+		if err = json.Unmarshal(kv.Value, &agentAccount); err != nil {
+			return t.WriteError(err)
+		}
+		if invokerRole == RoleBank && agentAccount.BankOrgId != invoker.OrganizationId {
+			continue
+		}
+		accounts = append(accounts, agentAccount)
+	}
+
+	accountsBytes, err := json.Marshal(accounts)
+	if err != nil {
+		return t.WriteError(err)
+	}
+	return t.WriteSuccess(accountsBytes)
+}
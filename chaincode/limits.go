@@ -0,0 +1,323 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"s7ab-platform-hyperledger/platform/s7ticket/entities"
+)
+
+// ErrLimitExceeded prefixes error returned by create when agent exceeds payment limit
+var ErrLimitExceeded = entities.ErrLimitExceeded
+
+func (t Ticket) getAgentUsageKey(stub shim.ChaincodeStubInterface, agentId string) (string, error) {
+	return stub.CreateCompositeKey(t.agentUsageKey, []string{agentId})
+}
+
+// getPaymentLimits returns current limits, empty limits if merchant not set them yet
+func (t Ticket) getPaymentLimits(stub shim.ChaincodeStubInterface) (limits entities.PaymentLimits, err error) {
+	limitsBytes, err := stub.GetState(t.limitsKey)
+	if err != nil || limitsBytes == nil {
+		return
+	}
+	err = json.Unmarshal(limitsBytes, &limits)
+	return
+}
+
+func (t Ticket) getAgentUsage(stub shim.ChaincodeStubInterface, agentId string) (usage entities.AgentUsage, err error) {
+	usage.AgentId = agentId
+
+	key, err := t.getAgentUsageKey(stub, agentId)
+	if err != nil {
+		return
+	}
+	usageBytes, err := stub.GetState(key)
+	if err != nil || usageBytes == nil {
+		return
+	}
+	err = json.Unmarshal(usageBytes, &usage)
+	return
+}
+
+func validateAgentLimit(limit entities.AgentLimit) error {
+	if limit.WindowSeconds < 0 {
+		return errors.New(`limit window is negative`)
+	}
+	if limit.WindowSeconds == 0 && (limit.MaxCount != 0 || limit.MaxAmount != 0) {
+		return errors.New(`limit window is not set`)
+	}
+	return nil
+}
+
+// agentLimit returns limit of agent, default limit if agent has no own one
+func agentLimit(limits entities.PaymentLimits, agentId string) entities.AgentLimit {
+	if limit, ok := limits.Agents[agentId]; ok {
+		return limit
+	}
+	return limits.Default
+}
+
+// applyAgentLimit drops usage entries outside window ending at now and adds payment
+// Breach is returned if payment exceeds limit, usage isn't changed in this case
+func applyAgentLimit(limit entities.AgentLimit, usage entities.AgentUsage, payment entities.AgentUsageEntry) (
+	entities.AgentUsage, *entities.LimitBreachEvent) {
+
+	var (
+		entries []entities.AgentUsageEntry
+		count   uint = 1
+		amount       = payment.Amount
+	)
+	for _, e := range usage.Entries {
+		if e.Timestamp <= payment.Timestamp-limit.WindowSeconds {
+			continue
+		}
+		entries = append(entries, e)
+		count++
+		amount += e.Amount
+	}
+
+	breach := &entities.LimitBreachEvent{AgentId: usage.AgentId, PaymentId: payment.PaymentId, WindowSeconds: limit.WindowSeconds}
+	switch {
+	case limit.MaxCount != 0 && count > limit.MaxCount:
+		breach.Limit, breach.Max, breach.Requested = entities.LimitCount, limit.MaxCount, count
+		return usage, breach
+	case limit.MaxAmount != 0 && amount > limit.MaxAmount:
+		breach.Limit, breach.Max, breach.Requested = entities.LimitAmount, limit.MaxAmount, amount
+		return usage, breach
+	}
+
+	usage.Entries = append(entries, payment)
+	return usage, nil
+}
+
+// checkAgentLimit counts payment in agent window and returns ErrLimitExceeded if limit is reached
+// Agents without limit are not tracked
+func (t Ticket) checkAgentLimit(stub shim.ChaincodeStubInterface, agentId string, payment *entities.Payment) error {
+	limits, err := t.getPaymentLimits(stub)
+	if err != nil {
+		return err
+	}
+
+	limit := agentLimit(limits, agentId)
+	if limit.WindowSeconds == 0 {
+		return nil
+	}
+
+	ts, err := stub.GetTxTimestamp()
+	if err != nil {
+		return err
+	}
+
+	usage, err := t.getAgentUsage(stub, agentId)
+	if err != nil {
+		return err
+	}
+
+	usage, breach := applyAgentLimit(limit, usage, entities.AgentUsageEntry{
+		PaymentId: payment.Id, Timestamp: ts.Seconds, Amount: payment.Amount})
+	if breach != nil {
+		// event of failed transaction isn't delivered, breach is recorded by /limits/breach in own transaction
+		return fmt.Errorf("%s: %s limit %d of agent %s, requested %d in %d seconds",
+			ErrLimitExceeded, breach.Limit, breach.Max, agentId, breach.Requested, breach.WindowSeconds)
+	}
+
+	key, err := t.getAgentUsageKey(stub, agentId)
+	if err != nil {
+		return err
+	}
+	usageBytes, err := json.Marshal(usage)
+	if err != nil {
+		return err
+	}
+	return stub.PutState(key, usageBytes)
+}
+
+// Set payment limits of agents, allowed only from merchant, arg[0] - limits json
+// Limits version is incremented on every update
+func (t Ticket) limitsSet(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) != 1 {
+		return t.WriteError(fmt.Sprintf("arguments count mismatch: %v", args))
+	}
+
+	_, _, invokerRole, err := t.getActors(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if invokerRole != RoleMerchant {
+		return t.WriteError(fmt.Sprintf("only merchant can set payment limits, your role is: %s", invokerRole))
+	}
+
+	var limits entities.PaymentLimits
+	if err = json.Unmarshal([]byte(args[0]), &limits); err != nil {
+		return t.WriteError(err)
+	}
+
+	if err = validateAgentLimit(limits.Default); err != nil {
+		return t.WriteError(err)
+	}
+	for agentId, limit := range limits.Agents {
+		if err = validateAgentLimit(limit); err != nil {
+			return t.WriteError(fmt.Sprintf("agent %s: %s", agentId, err))
+		}
+	}
+
+	current, err := t.getPaymentLimits(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+	limits.Version = current.Version + 1
+
+	limitsBytes, err := json.Marshal(limits)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if err = stub.PutState(t.limitsKey, limitsBytes); err != nil {
+		return t.WriteError(err)
+	}
+
+	if err = stub.SetEvent(entities.PaymentLimitsUpdated, limitsBytes); err != nil {
+		return t.WriteError(err)
+	}
+	return t.WriteSuccess(limitsBytes)
+}
+
+// Get payment limits, merchant gets limits of every agent, agent gets only own limit
+func (t Ticket) limitsGet(stub shim.ChaincodeStubInterface) pb.Response {
+	_, invoker, invokerRole, err := t.getActors(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	limits, err := t.getPaymentLimits(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	switch invokerRole {
+	case RoleMerchant:
+	case RoleAgent:
+		limits = entities.PaymentLimits{Version: limits.Version,
+			Agents: map[string]entities.AgentLimit{invoker.OrganizationId: agentLimit(limits, invoker.OrganizationId)}}
+	default:
+		return t.WriteError(fmt.Sprintf("payment limits are available only for merchant and agent, your role is: %s", invokerRole))
+	}
+
+	limitsBytes, err := json.Marshal(limits)
+	if err != nil {
+		return t.WriteError(err)
+	}
+	return t.WriteSuccess(limitsBytes)
+}
+
+// Record agent limit breach of rejected payment, so LimitBreached event is delivered with committed transaction
+// Allowed only from merchant, so offending agent can't skip it, breach is checked against current usage
+// arg[0] - agent id, arg[1] - payment create payload json
+func (t Ticket) limitsBreach(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) != 2 {
+		return t.WriteError(fmt.Sprintf("arguments count mismatch: %v", args))
+	}
+	agentId := args[0]
+
+	_, _, invokerRole, err := t.getActors(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if invokerRole != RoleMerchant {
+		return t.WriteError(fmt.Sprintf("only merchant can record limit breach, your role is: %s", invokerRole))
+	}
+
+	var payload entities.PaymentCreatePayload
+	if err = json.Unmarshal([]byte(args[1]), &payload); err != nil {
+		return t.WriteError(err)
+	}
+
+	limits, err := t.getPaymentLimits(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	ts, err := stub.GetTxTimestamp()
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	usage, err := t.getAgentUsage(stub, agentId)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	limit := agentLimit(limits, agentId)
+	_, breach := applyAgentLimit(limit, usage, entities.AgentUsageEntry{
+		PaymentId: payload.Id, Timestamp: ts.Seconds, Amount: payload.Amount})
+	if limit.WindowSeconds == 0 || breach == nil {
+		return t.WriteError(fmt.Sprintf("payment %s doesn't exceed limit of agent %s", payload.Id, agentId))
+	}
+
+	key, err := stub.CreateCompositeKey(t.limitBreachKey, []string{agentId, payload.Id})
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	breachBytes, err := json.Marshal(breach)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if err = stub.PutState(key, breachBytes); err != nil {
+		return t.WriteError(err)
+	}
+
+	if err = stub.SetEvent(entities.LimitBreached, breachBytes); err != nil {
+		return t.WriteError(err)
+	}
+	return t.WriteSuccess(breachBytes)
+}
+
+// Get payment usage of agent in current window, arg[0] - agent id, current agent if omitted
+// Usage is available to merchant and to agent itself
+func (t Ticket) limitsUsage(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+
+	_, invoker, invokerRole, err := t.getActors(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	var agentId string
+	if len(args) > 0 {
+		agentId = args[0]
+	}
+
+	switch invokerRole {
+	case RoleMerchant:
+		if agentId == `` {
+			return t.WriteError(`agent id is empty`)
+		}
+	case RoleAgent:
+		if agentId != `` && agentId != invoker.OrganizationId {
+			return t.WriteError(fmt.Sprintf("agent can't view usage of another agent: %s", agentId))
+		}
+		agentId = invoker.OrganizationId
+	default:
+		return t.WriteError(fmt.Sprintf("payment usage is available only for merchant and agent, your role is: %s", invokerRole))
+	}
+
+	usage, err := t.getAgentUsage(stub, agentId)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	usageBytes, err := json.Marshal(usage)
+	if err != nil {
+		return t.WriteError(err)
+	}
+	return t.WriteSuccess(usageBytes)
+}
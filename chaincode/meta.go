@@ -0,0 +1,220 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	platformEntities "s7ab-platform-hyperledger/platform/core/entities"
+	"s7ab-platform-hyperledger/platform/s7ticket/entities"
+)
+
+// getMetaField returns schema of meta key, keys not listed in schema are not allowed
+func getMetaField(key string) (*entities.MetaField, error) {
+	for i := range entities.MetaSchema {
+		if entities.MetaSchema[i].Key == key {
+			return &entities.MetaSchema[i], nil
+		}
+	}
+	return nil, fmt.Errorf("meta key is not allowed: %s", key)
+}
+
+// canWriteMeta returns error if invoker can't set meta key of payment
+func canWriteMeta(field *entities.MetaField, payment *entities.Payment, invokerId, invokerRole string) error {
+	for _, role := range field.Writers {
+		if role != invokerRole {
+			continue
+		}
+		switch {
+		case role == RoleAgent && invokerId != payment.PayerOrgId:
+			return fmt.Errorf("agent can't set meta of payment of another agent: %s", payment.PayerOrgId)
+		case role == RoleBank && !isPaymentBank(payment, invokerId):
+			return fmt.Errorf("bank can't set meta of payment of another bank")
+		}
+		return nil
+	}
+	return fmt.Errorf("role %s can't set meta key: %s", invokerRole, field.Key)
+}
+
+// validateMetaValue checks value size and pattern, empty value removes key and isn't checked
+func validateMetaValue(field *entities.MetaField, value string) error {
+	if value == `` {
+		return nil
+	}
+	if len(value) > field.MaxSize {
+		return fmt.Errorf("meta %s exceeds %d bytes", field.Key, field.MaxSize)
+	}
+	if field.Pattern != `` && !regexp.MustCompile(field.Pattern).MatchString(value) {
+		return fmt.Errorf("meta %s doesn't match pattern %s", field.Key, field.Pattern)
+	}
+	return nil
+}
+
+// metaSize returns summary size of meta values, value being set replaces current one
+func metaSize(meta map[string][]byte, key string, value []byte) int {
+	size := len(value)
+	for k, v := range meta {
+		if k != key {
+			size += len(v)
+		}
+	}
+	return size
+}
+
+// checkMeta validates meta value set by invoker against schema and size limit of payment meta
+func checkMeta(payment *entities.Payment, invoker *platformEntities.Member, invokerRole, key, value string) error {
+	field, err := getMetaField(key)
+	if err != nil {
+		return err
+	}
+	if err = canWriteMeta(field, payment, invoker.OrganizationId, invokerRole); err != nil {
+		return err
+	}
+	if err = validateMetaValue(field, value); err != nil {
+		return err
+	}
+	if metaSize(payment.Meta, key, []byte(value)) > entities.MaxMetaSize {
+		return fmt.Errorf("payment meta exceeds %d bytes", entities.MaxMetaSize)
+	}
+	return nil
+}
+
+// Set single payment meta value, arg[0] - payment id, arg[1] - meta key, arg[2] - value
+// Empty value removes key
+func (t Ticket) setMeta(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) != 3 {
+		return t.WriteError(fmt.Sprintf("arguments count mismatch: %v", args))
+	}
+	return t.putMeta(stub, entities.MetaPutPayload{PaymentId: args[0], Values: map[string]string{args[1]: args[2]}})
+}
+
+// Put several payment meta values in one transaction, arg[0] - meta put json
+// Empty value removes key
+func (t Ticket) metaPut(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) != 1 {
+		return t.WriteError(fmt.Sprintf("arguments count mismatch: %v", args))
+	}
+
+	var payload entities.MetaPutPayload
+	if err := json.Unmarshal([]byte(args[0]), &payload); err != nil {
+		return t.WriteError(err)
+	}
+	return t.putMeta(stub, payload)
+}
+
+// putMeta checks every value against schema and stores them in payment meta
+func (t Ticket) putMeta(stub shim.ChaincodeStubInterface, payload entities.MetaPutPayload) pb.Response {
+	if len(payload.Values) == 0 {
+		return t.WriteError(`meta values are empty`)
+	}
+
+	_, invoker, invokerRole, err := t.getActors(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if invokerRole == RoleUnknown {
+		return t.WriteError(fmt.Sprintf("meta can be set only by merchant, bank and agent, your role is: %s", invokerRole))
+	}
+
+	payment, err := t.getPayment(stub, payload.PaymentId)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	// reported payments are locked for agent and bank
+	if invokerRole != RoleMerchant {
+		if err = t.checkPeriodLock(stub, payment); err != nil {
+			return t.WriteError(err)
+		}
+	}
+
+	if payment.Meta == nil {
+		payment.Meta = make(map[string][]byte)
+	}
+
+	// keys are applied in order to make error deterministic for endorsers
+	var keys []string
+	for key := range payload.Values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := payload.Values[key]
+		if err = checkMeta(payment, invoker, invokerRole, key, value); err != nil {
+			return t.WriteError(err)
+		}
+		if value == `` {
+			delete(payment.Meta, key)
+		} else {
+			payment.Meta[key] = []byte(value)
+		}
+	}
+
+	payment.UpdatedBy = invoker.OrganizationId
+	payment.UpdatedByRole = invokerRole
+
+	if err = t.putPayment(stub, payment); err != nil {
+		return t.WriteError(err)
+	}
+
+	eventBytes, err := json.Marshal(entities.PaymentMetaUpdatedEvent{
+		PaymentId: payment.Id,
+		Keys:      keys,
+		UpdatedBy: invoker.OrganizationId,
+	})
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if err = stub.SetEvent(entities.PaymentMetaUpdated, eventBytes); err != nil {
+		return t.WriteError(err)
+	}
+	return t.WriteSuccess(nil)
+}
+
+// Get typed payment meta, allowed for merchant, payer agent and payer bank, arg[0] - payment id
+func (t Ticket) metaFields(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) != 1 {
+		return t.WriteError(fmt.Sprintf("arguments count mismatch: %v", args))
+	}
+
+	_, invoker, invokerRole, err := t.getActors(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if invokerRole == RoleUnknown {
+		return t.WriteError(fmt.Sprintf("payment meta is available only for merchant, agent and bank, your role is: %s", invokerRole))
+	}
+
+	payment, err := t.getPayment(stub, args[0])
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if !canViewPayment(payment, invoker.OrganizationId, invokerRole) {
+		return t.WriteError(fmt.Sprintf("payment meta of %s is available only for merchant, payer agent and payer bank", payment.Id))
+	}
+
+	metaBytes, err := json.Marshal(entities.NewPaymentMeta(payment.Meta))
+	if err != nil {
+		return t.WriteError(err)
+	}
+	return t.WriteSuccess(metaBytes)
+}
+
+func (t Ticket) metaSchema(stub shim.ChaincodeStubInterface) pb.Response {
+	schemaBytes, err := json.Marshal(entities.MetaSchema)
+	if err != nil {
+		return t.WriteError(err)
+	}
+	return t.WriteSuccess(schemaBytes)
+}
@@ -0,0 +1,209 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"s7ab-platform-hyperledger/platform/s7ticket/entities"
+)
+
+const defaultMigrationChunk = 100
+
+// paymentMigration upgrades payment from Version-1 to Version schema
+type paymentMigration struct {
+	Version     uint
+	Description string
+	Apply       func(payment *entities.Payment) error
+}
+
+// paymentMigrations must be ordered by version, last version is entities.PaymentSchemaVersion
+var paymentMigrations = []paymentMigration{
+	{
+		Version:     1,
+		Description: `init meta, set net amount for payments created before fee schedule`,
+		Apply: func(payment *entities.Payment) error {
+			if payment.Meta == nil {
+				payment.Meta = make(map[string][]byte)
+			}
+			if payment.FeeScheduleVersion == 0 && payment.NetAmount == 0 {
+				payment.NetAmount = payment.Amount
+			}
+			return nil
+		},
+	},
+}
+
+// migratePayment applies every migration newer than payment schema version
+// Returns false if payment is already on target version
+func migratePayment(payment *entities.Payment, targetVersion uint) (migrated bool, err error) {
+	if payment.SchemaVersion > targetVersion {
+		return false, fmt.Errorf("payment %s schema version %d is newer than chaincode version %d",
+			payment.Id, payment.SchemaVersion, targetVersion)
+	}
+
+	for _, m := range paymentMigrations {
+		if m.Version <= payment.SchemaVersion || m.Version > targetVersion {
+			continue
+		}
+		if err = m.Apply(payment); err != nil {
+			return false, fmt.Errorf("migration to version %d failed for payment %s: %s", m.Version, payment.Id, err)
+		}
+		payment.SchemaVersion = m.Version
+		migrated = true
+	}
+	return
+}
+
+func (t Ticket) getMigrationLog(stub shim.ChaincodeStubInterface) (log entities.MigrationLog, err error) {
+	logBytes, err := stub.GetState(t.migrationKey)
+	if err != nil || logBytes == nil {
+		return
+	}
+	err = json.Unmarshal(logBytes, &log)
+	return
+}
+
+// migrate processes next chunk of payments, progress is saved to migration log
+// so migration can be continued in next transaction
+func (t Ticket) migrate(stub shim.ChaincodeStubInterface, chunk int) (log entities.MigrationLog, err error) {
+	if log, err = t.getMigrationLog(stub); err != nil {
+		return
+	}
+
+	now, err := txTime(stub)
+	if err != nil {
+		return
+	}
+
+	creator, err := t.GetCreator(stub)
+	if err != nil {
+		return
+	}
+
+	// new target version or new pass over payments after completed one
+	if log.TargetVersion != entities.PaymentSchemaVersion || log.Completed {
+		log = entities.MigrationLog{TargetVersion: entities.PaymentSchemaVersion, StartedAt: now}
+	}
+
+	startKey := t.paymentKey + "_"
+	if log.LastKey != `` {
+		// first key after last processed
+		startKey = log.LastKey + "\x00"
+	}
+
+	iter, err := stub.GetStateByRange(startKey, t.paymentKey+"`")
+	if err != nil {
+		return
+	}
+	defer iter.Close()
+
+	processed := 0
+	for ; processed < chunk && iter.HasNext(); processed++ {
+		v, err := iter.Next()
+		if err != nil {
+			return log, err
+		}
+
+		var payment entities.Payment
+		if err = json.Unmarshal(v.Value, &payment); err != nil {
+			return log, err
+		}
+
+		migrated, err := migratePayment(&payment, log.TargetVersion)
+		if err != nil {
+			return log, err
+		}
+
+		if migrated {
+			payment.UpdatedBy = creator.MspID
+			payment.UpdatedByRole = RoleSystem
+			if err = t.putPayment(stub, &payment); err != nil {
+				return log, err
+			}
+			log.Migrated++
+		}
+		log.LastKey = v.Key
+		log.Processed++
+	}
+
+	log.Completed = !iter.HasNext()
+	log.UpdatedAt = now
+
+	logBytes, err := json.Marshal(log)
+	if err != nil {
+		return
+	}
+
+	if err = stub.PutState(t.migrationKey, logBytes); err != nil {
+		return
+	}
+
+	if log.Completed {
+		err = stub.SetEvent(entities.PaymentsMigrated, logBytes)
+	}
+	return
+}
+
+// migrateOnUpgrade runs migration chunk if payments are not migrated to current schema version yet
+func (t Ticket) migrateOnUpgrade(stub shim.ChaincodeStubInterface) error {
+	log, err := t.getMigrationLog(stub)
+	if err != nil {
+		return err
+	}
+
+	if log.Completed && log.TargetVersion == entities.PaymentSchemaVersion {
+		return nil
+	}
+
+	_, err = t.migrate(stub, defaultMigrationChunk)
+	return err
+}
+
+// Run next migration chunk, allowed only from merchant, arg[0] - optional chunk size
+func (t Ticket) migrateHandler(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+
+	chunk := defaultMigrationChunk
+	if len(args) > 0 {
+		var err error
+		if chunk, err = strconv.Atoi(args[0]); err != nil || chunk <= 0 {
+			return t.WriteError(fmt.Sprintf("invalid chunk size: %s", args[0]))
+		}
+	}
+
+	_, _, invokerRole, err := t.getActors(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if invokerRole != RoleMerchant {
+		return t.WriteError(fmt.Sprintf("only merchant can run migration, your role is: %s", invokerRole))
+	}
+
+	log, err := t.migrate(stub, chunk)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	logBytes, err := json.Marshal(log)
+	if err != nil {
+		return t.WriteError(err)
+	}
+	return t.WriteSuccess(logBytes)
+}
+
+func (t Ticket) migrateStatus(stub shim.ChaincodeStubInterface) pb.Response {
+	log, err := t.getMigrationLog(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	logBytes, err := json.Marshal(log)
+	if err != nil {
+		return t.WriteError(err)
+	}
+	return t.WriteSuccess(logBytes)
+}
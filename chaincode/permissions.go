@@ -0,0 +1,195 @@
+package chaincode
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/lib/cid"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"s7ab-platform-hyperledger/platform/s7ticket/entities"
+)
+
+var accessLevels = map[string]int{
+	entities.AccessViewer:   1,
+	entities.AccessOperator: 2,
+	entities.AccessAdmin:    3,
+}
+
+// routeAccess is access level of certificate required for route, routes not listed are available to viewers
+var routeAccess = map[string]string{
+	`/create`:                entities.AccessOperator,
+	`/limits/breach`:         entities.AccessOperator,
+	`/updateState`:           entities.AccessOperator,
+	`/issue`:                 entities.AccessOperator,
+	`/cancel`:                entities.AccessOperator,
+	`/void`:                  entities.AccessOperator,
+	`/hold/confirm`:          entities.AccessOperator,
+	`/hold/capture`:          entities.AccessOperator,
+	`/hold/release`:          entities.AccessOperator,
+	`/dispute/open`:          entities.AccessOperator,
+	`/dispute/respond`:       entities.AccessOperator,
+	`/dispute/resolve`:       entities.AccessOperator,
+	`/meta/set`:              entities.AccessOperator,
+	`/meta/put`:              entities.AccessOperator,
+	`/blocklist/add`:         entities.AccessOperator,
+	`/blocklist/remove`:      entities.AccessOperator,
+	`/settlement/create`:     entities.AccessOperator,
+	`/settlement/confirm`:    entities.AccessOperator,
+	`/report/create`:         entities.AccessOperator,
+	`/agent/account/confirm`: entities.AccessOperator,
+	`/report/sign`:           entities.AccessAdmin,
+	`/agent/add`:             entities.AccessAdmin,
+	`/agent/account/add`:     entities.AccessAdmin,
+	`/subagent/set`:          entities.AccessAdmin,
+	`/config/update`:         entities.AccessAdmin,
+	`/fees/set`:              entities.AccessAdmin,
+	`/limits/set`:            entities.AccessAdmin,
+	`/risk/set`:              entities.AccessAdmin,
+	`/migrate`:               entities.AccessAdmin,
+}
+
+// permissions of invoker certificate, attributed is false for certificates without role attribute
+type permissions struct {
+	access     string
+	approve    bool
+	maxAmount  uint
+	attributed bool
+}
+
+// certPermissions reads permissions from invoker certificate attributes
+// Role attribute must belong to organization role of invoker, certificates without it keep full access
+// they had before attributes were introduced, config can enforce attributes to reject them
+func certPermissions(stub shim.ChaincodeStubInterface, invokerRole string) (p permissions, err error) {
+	role, found, err := cid.GetAttributeValue(stub, entities.AttrRole)
+	if err != nil {
+		return
+	}
+	if !found {
+		return permissions{access: entities.AccessAdmin}, nil
+	}
+
+	p.attributed = true
+	prefix := strings.ToLower(invokerRole) + `-`
+	if !strings.HasPrefix(role, prefix) || accessLevels[strings.TrimPrefix(role, prefix)] == 0 {
+		return p, fmt.Errorf("certificate role %s doesn't match organization role %s", role, invokerRole)
+	}
+	p.access = strings.TrimPrefix(role, prefix)
+
+	approve, _, err := cid.GetAttributeValue(stub, entities.AttrApprove)
+	if err != nil {
+		return
+	}
+	p.approve = approve == `true`
+
+	maxAmount, found, err := cid.GetAttributeValue(stub, entities.AttrMaxAmount)
+	if err != nil || !found {
+		return
+	}
+	amount, err := strconv.ParseUint(maxAmount, 10, 64)
+	if err != nil {
+		return p, fmt.Errorf("invalid certificate max amount: %s", maxAmount)
+	}
+	p.maxAmount = uint(amount)
+	return
+}
+
+// checkAmount returns error if amount exceeds certificate max amount and certificate isn't approver
+func (p permissions) checkAmount(amount uint) error {
+	if p.maxAmount != 0 && amount > p.maxAmount && !p.approve {
+		return fmt.Errorf("amount %d exceeds certificate max amount %d, approver certificate is required", amount, p.maxAmount)
+	}
+	return nil
+}
+
+// checkRouteAccess checks access level of invoker certificate for invoked route
+func (t Ticket) checkRouteAccess(stub shim.ChaincodeStubInterface) error {
+	fn, _ := stub.GetFunctionAndParameters()
+	required, ok := routeAccess[fn]
+	if !ok {
+		return nil
+	}
+
+	_, _, invokerRole, err := t.getActors(stub)
+	if err != nil {
+		return err
+	}
+
+	// owner which is neither merchant, agent nor bank has no organization role to prefix its attributes,
+	// routes allowed to owner check it themselves
+	if invokerRole == RoleUnknown {
+		if owner, err := t.invokerIsOwner(stub); err != nil || owner {
+			return err
+		}
+	}
+
+	access, err := t.certAccess(stub, invokerRole)
+	if err != nil {
+		return err
+	}
+
+	if accessLevels[access] < accessLevels[required] {
+		return fmt.Errorf("certificate access %s is not enough for %s, required: %s", access, fn, required)
+	}
+	return nil
+}
+
+// invokerIsOwner returns true if transaction creator is config owner
+func (t Ticket) invokerIsOwner(stub shim.ChaincodeStubInterface) (bool, error) {
+	config, err := t.getConfig(stub)
+	if err != nil {
+		return false, err
+	}
+	creator, err := t.GetCreator(stub)
+	if err != nil {
+		return false, err
+	}
+	return creator.MspID == config.Owner, nil
+}
+
+// certAccess returns access level of invoker certificate
+// Certificate without role attribute is rejected when config enforces attributes
+func (t Ticket) certAccess(stub shim.ChaincodeStubInterface, invokerRole string) (string, error) {
+	p, err := certPermissions(stub, invokerRole)
+	if err != nil {
+		return ``, err
+	}
+
+	if !p.attributed {
+		config, err := t.getConfig(stub)
+		if err != nil {
+			return ``, err
+		}
+		if config.EnforceAttributes {
+			return ``, fmt.Errorf("certificate has no %s attribute", entities.AttrRole)
+		}
+	}
+	return p.access, nil
+}
+
+// checkStateAccess checks access level of invoker certificate for payment state set in config
+func checkStateAccess(stub shim.ChaincodeStubInterface, config *entities.Config, invokerRole string, state entities.PaymentState) error {
+	required, ok := config.StateAccess[state]
+	if !ok {
+		return nil
+	}
+
+	p, err := certPermissions(stub, invokerRole)
+	if err != nil {
+		return err
+	}
+
+	if accessLevels[p.access] < accessLevels[required] {
+		return fmt.Errorf("certificate access %s is not enough for state %s, required: %s", p.access, state, required)
+	}
+	return nil
+}
+
+// checkAmountAccess checks payment amount against max amount of invoker certificate
+func checkAmountAccess(stub shim.ChaincodeStubInterface, invokerRole string, amount uint) error {
+	p, err := certPermissions(stub, invokerRole)
+	if err != nil {
+		return err
+	}
+	return p.checkAmount(amount)
+}
@@ -0,0 +1,518 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"s7ab-platform-hyperledger/platform/s7ticket/entities"
+)
+
+const reportDateLayout = `2006-01-02`
+
+// parseReportPeriod returns bounds of reporting period, end is exclusive
+func parseReportPeriod(payload entities.SalesReportPayload) (start, end time.Time, err error) {
+	if start, err = time.Parse(reportDateLayout, payload.PeriodStart); err != nil {
+		return start, end, fmt.Errorf("invalid period start: %s", payload.PeriodStart)
+	}
+	if end, err = time.Parse(reportDateLayout, payload.PeriodEnd); err != nil {
+		return start, end, fmt.Errorf("invalid period end: %s", payload.PeriodEnd)
+	}
+	if !end.After(start) {
+		return start, end, errors.New(`period end must be after period start`)
+	}
+	return start, end, nil
+}
+
+// inPeriod returns true if RFC3339 time is in [start, end)
+func inPeriod(timestamp string, start, end time.Time) bool {
+	t, err := time.Parse(time.RFC3339Nano, timestamp)
+	return err == nil && !t.Before(start) && t.Before(end)
+}
+
+// periodLocked returns true if time falls into closed reporting period ending at closedUntil
+func periodLocked(closedUntil string, now time.Time) bool {
+	until, err := time.Parse(time.RFC3339, closedUntil)
+	return err == nil && now.Before(until)
+}
+
+// newSalesReportLine splits payment amount to base fare and taxes of itinerary
+// Remittance is amount which agent owes to merchant after commission
+func newSalesReportLine(p *entities.Payment, code, date string) entities.SalesReportLine {
+	line := entities.SalesReportLine{
+		PaymentId:       p.Id,
+		TicketNumber:    p.TicketNumber,
+		TransactionCode: code,
+		Date:            date,
+		Currency:        p.Currency,
+		Amount:          p.Amount,
+		Fare:            p.Amount,
+		Commission:      p.AgentCommission,
+	}
+	if p.Itinerary != nil {
+		line.PNR = p.Itinerary.PNR
+		for _, tax := range p.Itinerary.Fare.Taxes {
+			line.Taxes += tax.Amount
+		}
+		if line.Taxes > line.Amount {
+			line.Taxes = line.Amount
+		}
+		line.Fare = line.Amount - line.Taxes
+	}
+	if line.Commission < line.Amount {
+		line.Remittance = line.Amount - line.Commission
+	}
+	return line
+}
+
+// buildSalesReport aggregates issues, exchanges and refunds of agent payments in period
+// Payments already included in closed report are skipped
+func buildSalesReport(agentId string, start, end time.Time, payments []*entities.Payment) *entities.SalesReport {
+	report := &entities.SalesReport{
+		Id:          fmt.Sprintf("%s_%s", agentId, start.Format(reportDateLayout)),
+		AgentId:     agentId,
+		PeriodStart: start.Format(reportDateLayout),
+		PeriodEnd:   end.Format(reportDateLayout),
+		State:       entities.SalesReportDraft,
+		Lines:       []entities.SalesReportLine{},
+		Totals:      []entities.SalesReportTotal{},
+	}
+
+	totals := make(map[string]*entities.SalesReportTotal)
+	totalOf := func(currency string) *entities.SalesReportTotal {
+		if _, ok := totals[currency]; !ok {
+			totals[currency] = &entities.SalesReportTotal{Currency: currency}
+		}
+		return totals[currency]
+	}
+
+	for _, p := range payments {
+		if p.PayerOrgId != agentId || p.IssuedAt == `` {
+			continue
+		}
+
+		if p.SalesReportId == `` && inPeriod(p.IssuedAt, start, end) {
+			code := entities.TransactionIssue
+			if p.PaymentType == entities.PaymentTypeExchange {
+				code = entities.TransactionExchange
+			}
+			line := newSalesReportLine(p, code, p.IssuedAt)
+			report.Lines = append(report.Lines, line)
+
+			total := totalOf(line.Currency)
+			if code == entities.TransactionExchange {
+				total.Exchanges++
+			} else {
+				total.Issues++
+			}
+			total.GrossAmount += line.Amount
+			total.NetTaxes += int64(line.Taxes)
+			total.NetCommission += int64(line.Commission)
+			total.NetRemittance += int64(line.Remittance)
+		}
+
+		if p.State == entities.Refunded && p.RefundReportId == `` && inPeriod(p.CanceledAt, start, end) {
+			line := newSalesReportLine(p, entities.TransactionRefund, p.CanceledAt)
+			report.Lines = append(report.Lines, line)
+
+			total := totalOf(line.Currency)
+			total.Refunds++
+			total.RefundedAmount += line.Amount
+			total.NetTaxes -= int64(line.Taxes)
+			total.NetCommission -= int64(line.Commission)
+			total.NetRemittance -= int64(line.Remittance)
+		}
+	}
+
+	sort.SliceStable(report.Lines, func(i, j int) bool {
+		return report.Lines[i].Date < report.Lines[j].Date
+	})
+
+	for _, total := range totals {
+		report.Totals = append(report.Totals, *total)
+	}
+	sort.Slice(report.Totals, func(i, j int) bool {
+		return report.Totals[i].Currency < report.Totals[j].Currency
+	})
+
+	report.Hash = salesReportHash(report)
+	return report
+}
+
+// salesReportHash returns hex encoded sha256 of reported period, lines and totals
+func salesReportHash(report *entities.SalesReport) string {
+	content, _ := json.Marshal([]interface{}{report.AgentId, report.PeriodStart, report.PeriodEnd, report.Lines, report.Totals})
+	hash := sha256.Sum256(content)
+	return hex.EncodeToString(hash[:])
+}
+
+func (t Ticket) getReportKey(stub shim.ChaincodeStubInterface, reportId string) (string, error) {
+	return stub.CreateCompositeKey(t.reportKey, []string{reportId})
+}
+
+func (t Ticket) getReport(stub shim.ChaincodeStubInterface, reportId string) (report *entities.SalesReport, err error) {
+	key, err := t.getReportKey(stub, reportId)
+	if err != nil {
+		return
+	}
+
+	reportBytes, err := stub.GetState(key)
+	if err != nil {
+		return
+	}
+	if reportBytes == nil {
+		return nil, fmt.Errorf("sales report not found with id %s", reportId)
+	}
+
+	err = json.Unmarshal(reportBytes, &report)
+	return
+}
+
+func (t Ticket) putReport(stub shim.ChaincodeStubInterface, report *entities.SalesReport) error {
+	key, err := t.getReportKey(stub, report.Id)
+	if err != nil {
+		return err
+	}
+	return putJSON(stub, key, report)
+}
+
+func (t Ticket) listReports(stub shim.ChaincodeStubInterface) (reports []entities.SalesReport, err error) {
+	iter, err := stub.GetStateByPartialCompositeKey(t.reportKey, []string{})
+	if err != nil {
+		return
+	}
+
+	defer iter.Close()
+	for iter.HasNext() {
+		v, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		var report entities.SalesReport
+		if err = json.Unmarshal(v.Value, &report); err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+	return
+}
+
+func (t Ticket) getPeriodClosedKey(stub shim.ChaincodeStubInterface, agentId string) (string, error) {
+	return stub.CreateCompositeKey(t.periodClosedKey, []string{agentId})
+}
+
+// checkPeriodLock returns error if payment is included in closed sales report
+// or was issued or canceled in closed reporting period of payer agent
+// Only agent and bank changes are locked, merchant and system writers like settlement and migration are not
+func (t Ticket) checkPeriodLock(stub shim.ChaincodeStubInterface, payment *entities.Payment) error {
+	for _, reportId := range []string{payment.SalesReportId, payment.RefundReportId} {
+		if reportId != `` {
+			return fmt.Errorf("payment %s is included in closed sales report %s", payment.Id, reportId)
+		}
+	}
+
+	key, err := t.getPeriodClosedKey(stub, payment.PayerOrgId)
+	if err != nil {
+		return err
+	}
+
+	closedUntil, err := stub.GetState(key)
+	if err != nil || closedUntil == nil {
+		return err
+	}
+
+	for _, timestamp := range []string{payment.IssuedAt, payment.CanceledAt} {
+		at, err := time.Parse(time.RFC3339Nano, timestamp)
+		if err == nil && periodLocked(string(closedUntil), at) {
+			return fmt.Errorf("reporting period of agent %s is closed until %s", payment.PayerOrgId, closedUntil)
+		}
+	}
+	return nil
+}
+
+// closePeriod marks reported payments and locks agent changes until period end, last signer is recorded as actor
+func (t Ticket) closePeriod(stub shim.ChaincodeStubInterface, report *entities.SalesReport, end time.Time,
+	actor, actorRole string) error {
+
+	for _, line := range report.Lines {
+		payment, err := t.getPayment(stub, line.PaymentId)
+		if err != nil {
+			return err
+		}
+		if line.TransactionCode == entities.TransactionRefund {
+			payment.RefundReportId = report.Id
+		} else {
+			payment.SalesReportId = report.Id
+		}
+		payment.UpdatedBy = actor
+		payment.UpdatedByRole = actorRole
+		if err = t.putPayment(stub, payment); err != nil {
+			return err
+		}
+	}
+
+	key, err := t.getPeriodClosedKey(stub, report.AgentId)
+	if err != nil {
+		return err
+	}
+
+	current, err := stub.GetState(key)
+	if err != nil {
+		return err
+	}
+	if periodLocked(string(current), end) {
+		return nil
+	}
+	return stub.PutState(key, []byte(end.Format(time.RFC3339)))
+}
+
+func (t Ticket) setReportEvent(stub shim.ChaincodeStubInterface, name string, report *entities.SalesReport) error {
+	eventBytes, err := json.Marshal(entities.SalesReportEvent{
+		ReportId:    report.Id,
+		AgentId:     report.AgentId,
+		PeriodStart: report.PeriodStart,
+		PeriodEnd:   report.PeriodEnd,
+		State:       report.State,
+		Hash:        report.Hash,
+	})
+	if err != nil {
+		return err
+	}
+	return stub.SetEvent(name, eventBytes)
+}
+
+// canViewReport returns true for merchant and reported agent
+func canViewReport(report *entities.SalesReport, invokerId, invokerRole string) bool {
+	return invokerRole == RoleMerchant || (invokerRole == RoleAgent && report.AgentId == invokerId)
+}
+
+// Build or rebuild draft sales report of agent period, allowed only from merchant, arg[0] - sales report json
+// Rebuilt report resets signatures, periods of agent reports can't overlap
+func (t Ticket) reportCreate(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) != 1 {
+		return t.WriteError(fmt.Sprintf("arguments count mismatch: %v", args))
+	}
+
+	var payload entities.SalesReportPayload
+	if err := json.Unmarshal([]byte(args[0]), &payload); err != nil {
+		return t.WriteError(err)
+	}
+
+	_, _, invokerRole, err := t.getActors(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if invokerRole != RoleMerchant {
+		return t.WriteError(fmt.Sprintf("only merchant can create sales report, your role is: %s", invokerRole))
+	}
+
+	start, end, err := parseReportPeriod(payload)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if _, err = t.getAgent(stub, payload.AgentId); err != nil {
+		return t.WriteError(err)
+	}
+
+	payments, err := t.listPayments(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+	report := buildSalesReport(payload.AgentId, start, end, payments)
+
+	reports, err := t.listReports(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+	for _, r := range reports {
+		if r.AgentId != report.AgentId {
+			continue
+		}
+		if r.Id == report.Id && r.State == entities.SalesReportClosed {
+			return t.WriteError(fmt.Sprintf("sales period is closed: %s", r.Id))
+		}
+		if r.Id != report.Id && r.PeriodStart < report.PeriodEnd && report.PeriodStart < r.PeriodEnd {
+			return t.WriteError(fmt.Sprintf("sales period overlaps report %s", r.Id))
+		}
+	}
+
+	if report.CreatedAt, err = txTime(stub); err != nil {
+		return t.WriteError(err)
+	}
+
+	if err = t.putReport(stub, report); err != nil {
+		return t.WriteError(err)
+	}
+
+	if err = t.setReportEvent(stub, entities.SalesReportCreated, report); err != nil {
+		return t.WriteError(err)
+	}
+
+	reportBytes, err := json.Marshal(report)
+	if err != nil {
+		return t.WriteError(err)
+	}
+	return t.WriteSuccess(reportBytes)
+}
+
+// Co-sign sales report after period end, allowed from merchant and reported agent,
+// arg[0] - report id, arg[1] - signed report hash
+// Report is rebuilt before close, period is closed when both merchant and agent signed
+func (t Ticket) reportSign(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) != 2 {
+		return t.WriteError(fmt.Sprintf("arguments count mismatch: %v", args))
+	}
+
+	_, invoker, invokerRole, err := t.getActors(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	report, err := t.getReport(stub, args[0])
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if invokerRole != RoleMerchant && (invokerRole != RoleAgent || invoker.OrganizationId != report.AgentId) {
+		return t.WriteError(fmt.Sprintf("only merchant and reported agent can sign sales report, your role is: %s", invokerRole))
+	}
+
+	if report.State != entities.SalesReportDraft {
+		return t.WriteError(fmt.Sprintf("sales report is not draft: %s", report.State))
+	}
+	if args[1] != report.Hash {
+		return t.WriteError(fmt.Sprintf("signed hash doesn't match sales report hash %s", report.Hash))
+	}
+	for _, s := range report.Signatures {
+		if s.Role == invokerRole {
+			return t.WriteError(fmt.Sprintf("sales report already signed by %s", s.OrgId))
+		}
+	}
+
+	start, end, err := parseReportPeriod(entities.SalesReportPayload{PeriodStart: report.PeriodStart, PeriodEnd: report.PeriodEnd})
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	now, err := txNow(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+	if now.Before(end) {
+		return t.WriteError(fmt.Sprintf("sales report can be signed only after period end %s", report.PeriodEnd))
+	}
+
+	report.Signatures = append(report.Signatures, entities.SalesReportSignature{
+		OrgId:    invoker.OrganizationId,
+		Role:     invokerRole,
+		Hash:     report.Hash,
+		SignedAt: now.Format(time.RFC3339Nano),
+	})
+
+	event := entities.SalesReportSigned
+	if len(report.Signatures) == 2 {
+		payments, err := t.listPayments(stub)
+		if err != nil {
+			return t.WriteError(err)
+		}
+		if rebuilt := buildSalesReport(report.AgentId, start, end, payments); rebuilt.Hash != report.Hash {
+			return t.WriteError(`payments of period changed after sales report was created, rebuild report`)
+		}
+
+		report.State = entities.SalesReportClosed
+		report.ClosedAt = now.Format(time.RFC3339Nano)
+		event = entities.SalesPeriodClosed
+		if err = t.closePeriod(stub, report, end, invoker.OrganizationId, invokerRole); err != nil {
+			return t.WriteError(err)
+		}
+	}
+
+	if err = t.putReport(stub, report); err != nil {
+		return t.WriteError(err)
+	}
+
+	if err = t.setReportEvent(stub, event, report); err != nil {
+		return t.WriteError(err)
+	}
+	return t.WriteSuccess(nil)
+}
+
+// Get sales report, allowed for merchant and reported agent, arg[0] - report id
+func (t Ticket) reportGet(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) != 1 {
+		return t.WriteError(fmt.Sprintf("arguments count mismatch: %v", args))
+	}
+
+	_, invoker, invokerRole, err := t.getActors(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if invokerRole == RoleUnknown {
+		return t.WriteError(fmt.Sprintf("sales reports are available only for merchant and agent, your role is: %s", invokerRole))
+	}
+
+	report, err := t.getReport(stub, args[0])
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if !canViewReport(report, invoker.OrganizationId, invokerRole) {
+		return t.WriteError(fmt.Sprintf("sales report not found with id %s", args[0]))
+	}
+
+	reportBytes, err := json.Marshal(report)
+	if err != nil {
+		return t.WriteError(err)
+	}
+	return t.WriteSuccess(reportBytes)
+}
+
+// List sales reports visible to invoker, optional arg[0] - agent id
+func (t Ticket) reportList(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) > 1 {
+		return t.WriteError(fmt.Sprintf("arguments count mismatch: %v", args))
+	}
+
+	_, invoker, invokerRole, err := t.getActors(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if invokerRole == RoleUnknown {
+		return t.WriteError(fmt.Sprintf("sales reports are available only for merchant and agent, your role is: %s", invokerRole))
+	}
+
+	reports, err := t.listReports(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	visible := []entities.SalesReport{}
+	for i := range reports {
+		if len(args) == 1 && args[0] != `` && reports[i].AgentId != args[0] {
+			continue
+		}
+		if canViewReport(&reports[i], invoker.OrganizationId, invokerRole) {
+			visible = append(visible, reports[i])
+		}
+	}
+
+	result, err := json.Marshal(visible)
+	if err != nil {
+		return t.WriteError(err)
+	}
+	return t.WriteSuccess(result)
+}
@@ -0,0 +1,39 @@
+package chaincode
+
+import (
+	"fmt"
+
+	"s7ab-platform-hyperledger/platform/s7ticket/entities"
+)
+
+// retryStates maps failed state to state agent retries payment from it
+var retryStates = map[entities.PaymentState]entities.PaymentState{
+	entities.CheckFundsFail: entities.CheckFundsRequest,
+	entities.DebitFail:      entities.DebitRequest,
+}
+
+// isRetry returns true if payment moves from failed state back to request
+func isRetry(payment *entities.Payment, newState entities.PaymentState) bool {
+	retryState, failed := retryStates[payment.State]
+	return failed && retryState == newState
+}
+
+// applyRetry increments retry count, error is returned when payment reached max retries
+func applyRetry(payment *entities.Payment, maxRetries uint) error {
+	if payment.RetryCount >= maxRetries {
+		return fmt.Errorf("payment retries exhausted: %d of %d", payment.RetryCount, maxRetries)
+	}
+	payment.RetryCount++
+	return nil
+}
+
+// recordFailure stores reason when payment or its leg moves to failed state, state name is used if reason is empty
+func recordFailure(payment *entities.Payment, newState entities.PaymentState, reason string) {
+	if _, failed := retryStates[newState]; !failed {
+		return
+	}
+	if reason == `` {
+		reason = string(newState)
+	}
+	payment.LastFailureReason = reason
+}
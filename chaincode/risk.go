@@ -0,0 +1,296 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"s7ab-platform-hyperledger/platform/s7ticket/entities"
+)
+
+func (t Ticket) getAgentRiskKey(stub shim.ChaincodeStubInterface, agentId string) (string, error) {
+	return stub.CreateCompositeKey(t.riskAgentKey, []string{agentId})
+}
+
+func (t Ticket) getPayerRiskKey(stub shim.ChaincodeStubInterface, payerId string) (string, error) {
+	return stub.CreateCompositeKey(t.riskPayerKey, []string{payerId})
+}
+
+// getRiskRules returns current risk rules, empty rules if merchant not set them yet
+func (t Ticket) getRiskRules(stub shim.ChaincodeStubInterface) (rules entities.RiskRules, err error) {
+	rulesBytes, err := stub.GetState(t.riskRulesKey)
+	if err != nil || rulesBytes == nil {
+		return
+	}
+	err = json.Unmarshal(rulesBytes, &rules)
+	return
+}
+
+func (t Ticket) getAgentRiskStats(stub shim.ChaincodeStubInterface, agentId string) (stats entities.AgentRiskStats, err error) {
+	stats.AgentId = agentId
+	key, err := t.getAgentRiskKey(stub, agentId)
+	if err != nil {
+		return
+	}
+	statsBytes, err := stub.GetState(key)
+	if err != nil || statsBytes == nil {
+		return
+	}
+	err = json.Unmarshal(statsBytes, &stats)
+	return
+}
+
+func (t Ticket) getPayerRiskStats(stub shim.ChaincodeStubInterface, payerId string) (stats entities.PayerRiskStats, err error) {
+	stats.PayerId = payerId
+	key, err := t.getPayerRiskKey(stub, payerId)
+	if err != nil {
+		return
+	}
+	statsBytes, err := stub.GetState(key)
+	if err != nil || statsBytes == nil {
+		return
+	}
+	err = json.Unmarshal(statsBytes, &stats)
+	return
+}
+
+func validateRiskRules(rules entities.RiskRules) error {
+	ids := make(map[string]bool)
+	for _, rule := range rules.Rules {
+		if rule.Id == `` {
+			return errors.New(`risk rule id is empty`)
+		}
+		if ids[rule.Id] {
+			return fmt.Errorf("risk rule id is duplicated: %s", rule.Id)
+		}
+		ids[rule.Id] = true
+
+		switch rule.Type {
+		case entities.RiskAmount, entities.RiskPayerAgents:
+			if rule.Threshold == 0 {
+				return fmt.Errorf("risk rule %s threshold is not set", rule.Id)
+			}
+		case entities.RiskVelocity:
+			if rule.Threshold == 0 || rule.WindowSeconds <= 0 {
+				return fmt.Errorf("risk rule %s threshold and window are required", rule.Id)
+			}
+		case entities.RiskAgentAmount:
+			if rule.Multiplier == 0 {
+				return fmt.Errorf("risk rule %s multiplier is not set", rule.Id)
+			}
+		default:
+			return fmt.Errorf("risk rule %s has unknown type: %s", rule.Id, rule.Type)
+		}
+	}
+	return nil
+}
+
+// velocityWindow returns longest window of velocity rules, timestamps older than it are not kept
+func velocityWindow(rules entities.RiskRules) (window int64) {
+	for _, rule := range rules.Rules {
+		if rule.Type == entities.RiskVelocity && rule.WindowSeconds > window {
+			window = rule.WindowSeconds
+		}
+	}
+	return
+}
+
+// assessRisk returns ids of rules matched by payment created at now, aggregates are taken before payment
+func assessRisk(rules entities.RiskRules, payment *entities.Payment, now int64,
+	agent entities.AgentRiskStats, payer entities.PayerRiskStats) (flags []string) {
+
+	payerAgents := len(payer.Agents)
+	if i := sort.SearchStrings(payer.Agents, agent.AgentId); i == len(payer.Agents) || payer.Agents[i] != agent.AgentId {
+		payerAgents++
+	}
+
+	for _, rule := range rules.Rules {
+		var matched bool
+		switch rule.Type {
+		case entities.RiskAmount:
+			matched = payment.Amount > rule.Threshold
+		case entities.RiskAgentAmount:
+			// compare amount*count with total to avoid rounding of average
+			matched = agent.Count > 0 && agent.Count >= rule.MinPayments &&
+				payment.Amount*agent.Count > agent.TotalAmount*rule.Multiplier
+		case entities.RiskVelocity:
+			count := uint(1)
+			for _, ts := range agent.Recent {
+				if ts > now-rule.WindowSeconds {
+					count++
+				}
+			}
+			matched = count >= rule.Threshold
+		case entities.RiskPayerAgents:
+			matched = payment.PayerId != `` && uint(payerAgents) >= rule.Threshold
+		}
+		if matched {
+			flags = append(flags, rule.Id)
+		}
+	}
+	return
+}
+
+// updateRiskStats adds payment created at now to agent and payer aggregates
+func updateRiskStats(rules entities.RiskRules, payment *entities.Payment, now int64,
+	agent entities.AgentRiskStats, payer entities.PayerRiskStats) (entities.AgentRiskStats, entities.PayerRiskStats) {
+
+	agent.Count++
+	agent.TotalAmount += payment.Amount
+
+	window := velocityWindow(rules)
+	recent := []int64{}
+	if window > 0 {
+		for _, ts := range agent.Recent {
+			if ts > now-window {
+				recent = append(recent, ts)
+			}
+		}
+		recent = append(recent, now)
+	}
+	agent.Recent = recent
+
+	if i := sort.SearchStrings(payer.Agents, agent.AgentId); i == len(payer.Agents) || payer.Agents[i] != agent.AgentId {
+		payer.Agents = append(payer.Agents, ``)
+		copy(payer.Agents[i+1:], payer.Agents[i:])
+		payer.Agents[i] = agent.AgentId
+	}
+	return agent, payer
+}
+
+// screenPayment evaluates risk rules against on-ledger aggregates and updates them with payment
+// Payment is moved to RiskReview state if any rule matched
+// Aggregates are kept only while rules are set, so payments don't write shared keys without rules
+func (t Ticket) screenPayment(stub shim.ChaincodeStubInterface, payment *entities.Payment) error {
+	rules, err := t.getRiskRules(stub)
+	if err != nil {
+		return err
+	}
+
+	payment.RiskRulesVersion = rules.Version
+	if len(rules.Rules) == 0 {
+		return nil
+	}
+
+	ts, err := stub.GetTxTimestamp()
+	if err != nil {
+		return err
+	}
+
+	agent, err := t.getAgentRiskStats(stub, payment.PayerOrgId)
+	if err != nil {
+		return err
+	}
+
+	var payer entities.PayerRiskStats
+	if payment.PayerId != `` {
+		if payer, err = t.getPayerRiskStats(stub, payment.PayerId); err != nil {
+			return err
+		}
+	}
+
+	payment.RiskFlags = assessRisk(rules, payment, ts.Seconds, agent, payer)
+	if len(payment.RiskFlags) > 0 {
+		payment.State = entities.RiskReview
+	}
+
+	agent, payer = updateRiskStats(rules, payment, ts.Seconds, agent, payer)
+
+	agentKey, err := t.getAgentRiskKey(stub, agent.AgentId)
+	if err != nil {
+		return err
+	}
+	if err = putJSON(stub, agentKey, agent); err != nil {
+		return err
+	}
+
+	if payment.PayerId == `` {
+		return nil
+	}
+	payerKey, err := t.getPayerRiskKey(stub, payer.PayerId)
+	if err != nil {
+		return err
+	}
+	return putJSON(stub, payerKey, payer)
+}
+
+func putJSON(stub shim.ChaincodeStubInterface, key string, value interface{}) error {
+	valueBytes, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return stub.PutState(key, valueBytes)
+}
+
+// Set risk rules, allowed only from merchant, arg[0] - risk rules json
+// Rules version is incremented on every update
+func (t Ticket) riskSet(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) != 1 {
+		return t.WriteError(fmt.Sprintf("arguments count mismatch: %v", args))
+	}
+
+	_, _, invokerRole, err := t.getActors(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if invokerRole != RoleMerchant {
+		return t.WriteError(fmt.Sprintf("only merchant can set risk rules, your role is: %s", invokerRole))
+	}
+
+	var rules entities.RiskRules
+	if err = json.Unmarshal([]byte(args[0]), &rules); err != nil {
+		return t.WriteError(err)
+	}
+
+	if err = validateRiskRules(rules); err != nil {
+		return t.WriteError(err)
+	}
+
+	current, err := t.getRiskRules(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+	rules.Version = current.Version + 1
+
+	rulesBytes, err := json.Marshal(rules)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if err = stub.PutState(t.riskRulesKey, rulesBytes); err != nil {
+		return t.WriteError(err)
+	}
+
+	if err = stub.SetEvent(entities.RiskRulesUpdated, rulesBytes); err != nil {
+		return t.WriteError(err)
+	}
+	return t.WriteSuccess(rulesBytes)
+}
+
+// Get risk rules, allowed only from merchant
+func (t Ticket) riskGet(stub shim.ChaincodeStubInterface) pb.Response {
+	_, _, invokerRole, err := t.getActors(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if invokerRole != RoleMerchant {
+		return t.WriteError(fmt.Sprintf("risk rules are available only for merchant, your role is: %s", invokerRole))
+	}
+
+	rules, err := t.getRiskRules(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	rulesBytes, err := json.Marshal(rules)
+	if err != nil {
+		return t.WriteError(err)
+	}
+	return t.WriteSuccess(rulesBytes)
+}
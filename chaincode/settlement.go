@@ -0,0 +1,450 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"s7ab-platform-hyperledger/platform/s7ticket/entities"
+)
+
+func (t Ticket) getSettlementKey(stub shim.ChaincodeStubInterface, batchId string) (string, error) {
+	return stub.CreateCompositeKey(t.settlementKey, []string{batchId})
+}
+
+func (t Ticket) getSettlementBatch(stub shim.ChaincodeStubInterface, batchId string) (batch *entities.SettlementBatch, err error) {
+	key, err := t.getSettlementKey(stub, batchId)
+	if err != nil {
+		return
+	}
+
+	batchBytes, err := stub.GetState(key)
+	if err != nil {
+		return
+	}
+	if batchBytes == nil {
+		return nil, fmt.Errorf("settlement batch not found with id %s", batchId)
+	}
+
+	err = json.Unmarshal(batchBytes, &batch)
+	return
+}
+
+func (t Ticket) putSettlementBatch(stub shim.ChaincodeStubInterface, batch *entities.SettlementBatch) error {
+	key, err := t.getSettlementKey(stub, batch.Id)
+	if err != nil {
+		return err
+	}
+
+	batchBytes, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	return stub.PutState(key, batchBytes)
+}
+
+// paymentFunding returns legs of split payment or single leg funded by payer bank
+func paymentFunding(p *entities.Payment) []entities.PaymentLeg {
+	if len(p.Legs) > 0 {
+		return p.Legs
+	}
+	return []entities.PaymentLeg{{BankOrgId: p.PayerBankOrgId, Account: p.PayerAccount, Amount: p.Amount}}
+}
+
+// partiallyRefunded returns true if some legs of debited split payment are refunded by dispute
+func partiallyRefunded(p *entities.Payment) bool {
+	for _, leg := range p.Legs {
+		if leg.State == entities.Refunded {
+			return true
+		}
+	}
+	return false
+}
+
+// paymentBanks returns distinct banks funding payment in legs order
+func paymentBanks(p *entities.Payment) (banks []string) {
+	seen := map[string]bool{}
+	for _, leg := range paymentFunding(p) {
+		if !seen[leg.BankOrgId] {
+			seen[leg.BankOrgId] = true
+			banks = append(banks, leg.BankOrgId)
+		}
+	}
+	return
+}
+
+// isPaymentBank returns true if bank funds payment or any leg of split payment
+func isPaymentBank(payment *entities.Payment, bankOrgId string) bool {
+	for _, leg := range paymentFunding(payment) {
+		if leg.BankOrgId == bankOrgId {
+			return true
+		}
+	}
+	return false
+}
+
+func (t Ticket) setSettlementEvent(stub shim.ChaincodeStubInterface, name string, batch *entities.SettlementBatch) error {
+	eventBytes, err := json.Marshal(entities.SettlementBatchEvent{
+		BatchId:   batch.Id,
+		State:     batch.State,
+		Positions: batch.Positions,
+	})
+	if err != nil {
+		return err
+	}
+	return stub.SetEvent(name, eventBytes)
+}
+
+// buildSettlementBatch groups unsettled payments in currency by payer and recipient bank pair
+// and computes multilateral net positions. Payments included in batch are marked with batch id
+func buildSettlementBatch(batchId, currency string, payments []*entities.Payment) (*entities.SettlementBatch, []*entities.Payment) {
+	batch := &entities.SettlementBatch{Id: batchId, State: entities.SettlementPending, Currency: currency}
+
+	pairs := make(map[[2]string]*entities.SettlementPair)
+	positions := make(map[string]int64)
+	var changed []*entities.Payment
+
+	pairOf := func(payerBankOrgId, recipientBankOrgId string) *entities.SettlementPair {
+		k := [2]string{payerBankOrgId, recipientBankOrgId}
+		if _, ok := pairs[k]; !ok {
+			pairs[k] = &entities.SettlementPair{PayerBankOrgId: payerBankOrgId, RecipientBankOrgId: recipientBankOrgId}
+			positions[payerBankOrgId] = positions[payerBankOrgId]
+			positions[recipientBankOrgId] = positions[recipientBankOrgId]
+		}
+		return pairs[k]
+	}
+
+	for _, p := range payments {
+		if p.Currency != currency {
+			continue
+		}
+
+		var debited, refunded bool
+		switch {
+		case p.State == entities.DebitSuccess && p.SettlementBatchId == ``:
+			debited = true
+			batch.PaymentIds = append(batch.PaymentIds, p.Id)
+			p.SettlementBatchId = batchId
+			// legs refunded by dispute before settlement are offset inside cycle
+			if partiallyRefunded(p) {
+				refunded = true
+				batch.RefundIds = append(batch.RefundIds, p.Id)
+				p.RefundSettlementBatchId = batchId
+			}
+
+		// refunded before settlement, debit and refund are offset inside cycle
+		case p.State == entities.Refunded && p.SettlementBatchId == ``:
+			debited, refunded = true, true
+			batch.PaymentIds = append(batch.PaymentIds, p.Id)
+			batch.RefundIds = append(batch.RefundIds, p.Id)
+			p.SettlementBatchId = batchId
+			p.RefundSettlementBatchId = batchId
+
+		// refunded after settlement, funds move back from recipient bank
+		case p.State == entities.Refunded && p.Settled && p.RefundSettlementBatchId == ``:
+			refunded = true
+			batch.RefundIds = append(batch.RefundIds, p.Id)
+			p.RefundSettlementBatchId = batchId
+			p.Settled = false
+
+		// legs refunded by dispute after settlement, the rest of payment stays settled
+		case p.State == entities.DebitSuccess && p.Settled && p.RefundSettlementBatchId == `` && partiallyRefunded(p):
+			refunded = true
+			batch.RefundIds = append(batch.RefundIds, p.Id)
+			p.RefundSettlementBatchId = batchId
+
+		default:
+			continue
+		}
+
+		// split payment is settled by every leg bank, fees are accounted in pair of first leg
+		for i, leg := range paymentFunding(p) {
+			legRefunded := refunded && (p.State == entities.Refunded || leg.State == entities.Refunded)
+			if !debited && !legRefunded {
+				continue
+			}
+			pair := pairOf(leg.BankOrgId, p.RecipientBankOrgId)
+			if debited {
+				pair.Debited += leg.Amount
+			}
+			if legRefunded {
+				pair.Refunded += leg.Amount
+			}
+			if i == 0 && debited && p.State != entities.Refunded {
+				pair.AgentCommission += p.AgentCommission
+				pair.MerchantFee += p.MerchantFee
+			}
+			pair.PaymentIds = append(pair.PaymentIds, p.Id)
+		}
+		changed = append(changed, p)
+	}
+
+	for _, pair := range pairs {
+		pair.Net = int64(pair.Debited) - int64(pair.Refunded)
+		positions[pair.PayerBankOrgId] -= pair.Net
+		positions[pair.RecipientBankOrgId] += pair.Net
+		batch.Pairs = append(batch.Pairs, *pair)
+	}
+	sort.Slice(batch.Pairs, func(i, j int) bool {
+		if batch.Pairs[i].PayerBankOrgId != batch.Pairs[j].PayerBankOrgId {
+			return batch.Pairs[i].PayerBankOrgId < batch.Pairs[j].PayerBankOrgId
+		}
+		return batch.Pairs[i].RecipientBankOrgId < batch.Pairs[j].RecipientBankOrgId
+	})
+
+	for bankOrgId, net := range positions {
+		batch.Positions = append(batch.Positions, entities.SettlementPosition{BankOrgId: bankOrgId, Net: net})
+	}
+	sort.Slice(batch.Positions, func(i, j int) bool {
+		return batch.Positions[i].BankOrgId < batch.Positions[j].BankOrgId
+	})
+
+	return batch, changed
+}
+
+// Open new settlement cycle, allowed only from merchant, arg[0] - currency
+func (t Ticket) settlementCreate(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) != 1 {
+		return t.WriteError(fmt.Sprintf("arguments count mismatch: %v", args))
+	}
+
+	_, invoker, invokerRole, err := t.getActors(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if invokerRole != RoleMerchant {
+		return t.WriteError(fmt.Sprintf("only merchant can create settlement batch, your role is: %s", invokerRole))
+	}
+
+	payments, err := t.listPayments(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	batch, changed := buildSettlementBatch(stub.GetTxID(), args[0], payments)
+	if len(changed) == 0 {
+		return t.WriteError(`no payments to settle`)
+	}
+
+	if batch.CreatedAt, err = txTime(stub); err != nil {
+		return t.WriteError(err)
+	}
+
+	for _, p := range changed {
+		p.UpdatedBy = invoker.OrganizationId
+		p.UpdatedByRole = invokerRole
+		if err = t.putPayment(stub, p); err != nil {
+			return t.WriteError(err)
+		}
+	}
+
+	if err = t.putSettlementBatch(stub, batch); err != nil {
+		return t.WriteError(err)
+	}
+
+	if err = t.setSettlementEvent(stub, entities.SettlementBatchCreated, batch); err != nil {
+		return t.WriteError(err)
+	}
+
+	batchBytes, err := json.Marshal(batch)
+	if err != nil {
+		return t.WriteError(err)
+	}
+	return t.WriteSuccess(batchBytes)
+}
+
+// Confirm settlement batch by involved bank, arg[0] - batch id
+// Batch is completed and payments marked as settled when every involved bank confirmed
+func (t Ticket) settlementConfirm(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) != 1 {
+		return t.WriteError(fmt.Sprintf("arguments count mismatch: %v", args))
+	}
+
+	_, invoker, invokerRole, err := t.getActors(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if invokerRole != RoleBank {
+		return t.WriteError(fmt.Sprintf("only bank can confirm settlement batch, your role is: %s", invokerRole))
+	}
+
+	batch, err := t.getSettlementBatch(stub, args[0])
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if batch.State != entities.SettlementPending {
+		return t.WriteError(fmt.Sprintf("settlement batch is not pending: %s", batch.State))
+	}
+
+	found, completed := false, true
+	for i := range batch.Positions {
+		if batch.Positions[i].BankOrgId == invoker.OrganizationId {
+			if batch.Positions[i].Confirmed {
+				return t.WriteError(`settlement batch already confirmed by bank`)
+			}
+			batch.Positions[i].Confirmed = true
+			found = true
+		}
+		completed = completed && batch.Positions[i].Confirmed
+	}
+
+	if !found {
+		return t.WriteError(errors.New(`bank is not participant of settlement batch`))
+	}
+
+	event := entities.SettlementBatchConfirmed
+	if completed {
+		batch.State = entities.SettlementCompleted
+		event = entities.SettlementBatchCompleted
+		if err = t.markSettled(stub, batch, invoker.OrganizationId, invokerRole); err != nil {
+			return t.WriteError(err)
+		}
+	}
+
+	if err = t.putSettlementBatch(stub, batch); err != nil {
+		return t.WriteError(err)
+	}
+
+	if err = t.setSettlementEvent(stub, event, batch); err != nil {
+		return t.WriteError(err)
+	}
+	return t.WriteSuccess(nil)
+}
+
+// markSettled marks payments and refunds of completed batch, bank confirmed batch last is recorded as actor
+func (t Ticket) markSettled(stub shim.ChaincodeStubInterface, batch *entities.SettlementBatch, actor, actorRole string) error {
+	ids := append(append([]string{}, batch.PaymentIds...), batch.RefundIds...)
+	for _, id := range ids {
+		payment, err := t.getPayment(stub, id)
+		if err != nil {
+			return err
+		}
+		payment.Settled = true
+		payment.UpdatedBy = actor
+		payment.UpdatedByRole = actorRole
+		if err = t.putPayment(stub, payment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// settlementView returns batch visible to invoker, merchant sees whole batch
+// Bank sees own position and pairs, nil is returned if bank has no position in batch
+func settlementView(batch *entities.SettlementBatch, orgId, role string) *entities.SettlementBatch {
+	if role == RoleMerchant {
+		return batch
+	}
+
+	view := *batch
+	view.PaymentIds, view.RefundIds, view.Pairs, view.Positions = nil, nil, nil, nil
+	for _, position := range batch.Positions {
+		if position.BankOrgId == orgId {
+			view.Positions = append(view.Positions, position)
+		}
+	}
+	if len(view.Positions) == 0 {
+		return nil
+	}
+
+	ids := map[string]bool{}
+	for _, pair := range batch.Pairs {
+		if pair.PayerBankOrgId == orgId || pair.RecipientBankOrgId == orgId {
+			view.Pairs = append(view.Pairs, pair)
+			for _, id := range pair.PaymentIds {
+				ids[id] = true
+			}
+		}
+	}
+	for _, id := range batch.PaymentIds {
+		if ids[id] {
+			view.PaymentIds = append(view.PaymentIds, id)
+		}
+	}
+	for _, id := range batch.RefundIds {
+		if ids[id] {
+			view.RefundIds = append(view.RefundIds, id)
+		}
+	}
+	return &view
+}
+
+// Get settlement batch, allowed for merchant and banks of batch, arg[0] - batch id
+func (t Ticket) settlementGet(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) != 1 {
+		return t.WriteError(fmt.Sprintf("arguments count mismatch: %v", args))
+	}
+
+	_, invoker, invokerRole, err := t.getActors(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if invokerRole != RoleMerchant && invokerRole != RoleBank {
+		return t.WriteError(fmt.Sprintf("settlement batches are available only for merchant and bank, your role is: %s", invokerRole))
+	}
+
+	batch, err := t.getSettlementBatch(stub, args[0])
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if batch = settlementView(batch, invoker.OrganizationId, invokerRole); batch == nil {
+		return t.WriteError(fmt.Sprintf("settlement batch not found with id %s", args[0]))
+	}
+
+	batchBytes, err := json.Marshal(batch)
+	if err != nil {
+		return t.WriteError(err)
+	}
+	return t.WriteSuccess(batchBytes)
+}
+
+// List settlement batches visible to invoker, allowed for merchant and bank
+func (t Ticket) settlementList(stub shim.ChaincodeStubInterface) pb.Response {
+	var batches []entities.SettlementBatch
+
+	_, invoker, invokerRole, err := t.getActors(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if invokerRole != RoleMerchant && invokerRole != RoleBank {
+		return t.WriteError(fmt.Sprintf("settlement batches are available only for merchant and bank, your role is: %s", invokerRole))
+	}
+
+	iter, err := stub.GetStateByPartialCompositeKey(t.settlementKey, []string{})
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	defer iter.Close()
+	for iter.HasNext() {
+		v, err := iter.Next()
+		if err != nil {
+			return t.WriteError(err)
+		}
+		var batch entities.SettlementBatch
+		if err = json.Unmarshal(v.Value, &batch); err != nil {
+			return t.WriteError(err)
+		}
+		if view := settlementView(&batch, invoker.OrganizationId, invokerRole); view != nil {
+			batches = append(batches, *view)
+		}
+	}
+
+	result, err := json.Marshal(batches)
+	if err != nil {
+		return t.WriteError(err)
+	}
+	return t.WriteSuccess(result)
+}
@@ -0,0 +1,303 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/lib/cid"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"s7ab-platform-hyperledger/platform/s7ticket/entities"
+)
+
+var subAgentPermissions = map[string]bool{
+	entities.SubAgentCreate: true,
+	entities.SubAgentIssue:  true,
+	entities.SubAgentCancel: true,
+}
+
+// getSubAgentId returns point of sale id from invoker certificate attribute, empty if certificate has no attribute
+func getSubAgentId(stub shim.ChaincodeStubInterface) (string, error) {
+	subAgentId, found, err := cid.GetAttributeValue(stub, entities.AttrSubAgent)
+	if err != nil || !found {
+		return ``, err
+	}
+	return subAgentId, nil
+}
+
+func (t Ticket) getSubAgentKey(stub shim.ChaincodeStubInterface, agentId, subAgentId string) (string, error) {
+	return stub.CreateCompositeKey(t.subAgentKey, []string{agentId, subAgentId})
+}
+
+func (t Ticket) getSubAgent(stub shim.ChaincodeStubInterface, agentId, subAgentId string) (*entities.SubAgent, error) {
+	key, err := t.getSubAgentKey(stub, agentId, subAgentId)
+	if err != nil {
+		return nil, err
+	}
+	subAgentBytes, err := stub.GetState(key)
+	if err != nil {
+		return nil, err
+	}
+	if subAgentBytes == nil {
+		return nil, fmt.Errorf("point of sale %s of agent %s not found", subAgentId, agentId)
+	}
+	var subAgent entities.SubAgent
+	if err = json.Unmarshal(subAgentBytes, &subAgent); err != nil {
+		return nil, err
+	}
+	return &subAgent, nil
+}
+
+// invokerSubAgent returns enabled point of sale of invoker agent, nil if certificate acts for agent itself
+func (t Ticket) invokerSubAgent(stub shim.ChaincodeStubInterface, agentId string) (*entities.SubAgent, error) {
+	subAgentId, err := getSubAgentId(stub)
+	if err != nil || subAgentId == `` {
+		return nil, err
+	}
+	subAgent, err := t.getSubAgent(stub, agentId, subAgentId)
+	if err != nil {
+		return nil, err
+	}
+	if subAgent.Disabled {
+		return nil, fmt.Errorf("point of sale %s is disabled", subAgentId)
+	}
+	return subAgent, nil
+}
+
+// checkSubAgentPayment returns error if point of sale of invoker agent has no permission or payment belongs to another point of sale
+func (t Ticket) checkSubAgentPayment(stub shim.ChaincodeStubInterface, payment *entities.Payment, permission string) error {
+	subAgent, err := t.invokerSubAgent(stub, payment.PayerOrgId)
+	if err != nil || subAgent == nil {
+		return err
+	}
+	if permission != `` && !subAgent.Can(permission) {
+		return fmt.Errorf("point of sale %s has no permission: %s", subAgent.Id, permission)
+	}
+	if payment.SubAgentId != subAgent.Id {
+		return fmt.Errorf("point of sale %s can't process payment of another point of sale", subAgent.Id)
+	}
+	return nil
+}
+
+// checkSubAgentLimit checks payment created by point of sale against its permission, amount and window limit
+func (t Ticket) checkSubAgentLimit(stub shim.ChaincodeStubInterface, subAgent *entities.SubAgent, payment *entities.Payment) error {
+	if !subAgent.Can(entities.SubAgentCreate) {
+		return fmt.Errorf("point of sale %s has no permission: %s", subAgent.Id, entities.SubAgentCreate)
+	}
+	if subAgent.MaxAmount != 0 && payment.Amount > subAgent.MaxAmount {
+		return fmt.Errorf("%s: amount %d exceeds max amount %d of point of sale %s",
+			ErrLimitExceeded, payment.Amount, subAgent.MaxAmount, subAgent.Id)
+	}
+	if subAgent.Limit.WindowSeconds == 0 {
+		return nil
+	}
+
+	ts, err := stub.GetTxTimestamp()
+	if err != nil {
+		return err
+	}
+
+	key, err := stub.CreateCompositeKey(t.subAgentUsageKey, []string{subAgent.AgentId, subAgent.Id})
+	if err != nil {
+		return err
+	}
+	usage := entities.AgentUsage{AgentId: subAgent.AgentId}
+	usageBytes, err := stub.GetState(key)
+	if err != nil {
+		return err
+	}
+	if usageBytes != nil {
+		if err = json.Unmarshal(usageBytes, &usage); err != nil {
+			return err
+		}
+	}
+
+	usage, breach := applyAgentLimit(subAgent.Limit, usage, entities.AgentUsageEntry{
+		PaymentId: payment.Id, Timestamp: ts.Seconds, Amount: payment.Amount})
+	if breach != nil {
+		return fmt.Errorf("%s: %s limit %d of point of sale %s, requested %d in %d seconds",
+			ErrLimitExceeded, breach.Limit, breach.Max, subAgent.Id, breach.Requested, breach.WindowSeconds)
+	}
+	return putJSON(stub, key, usage)
+}
+
+// Set point of sale of agent with permissions and limits, allowed only from merchant, arg[0] - sub agent json
+func (t Ticket) subAgentSet(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) != 1 {
+		return t.WriteError(fmt.Sprintf("arguments count mismatch: %v", args))
+	}
+
+	_, _, invokerRole, err := t.getActors(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if invokerRole != RoleMerchant {
+		return t.WriteError(fmt.Sprintf("only merchant can set point of sale, your role is: %s", invokerRole))
+	}
+
+	var subAgent entities.SubAgent
+	if err = json.Unmarshal([]byte(args[0]), &subAgent); err != nil {
+		return t.WriteError(err)
+	}
+
+	if subAgent.Id == `` {
+		return t.WriteError(`point of sale id is empty`)
+	}
+	if _, err = t.getAgent(stub, subAgent.AgentId); err != nil {
+		return t.WriteError(err)
+	}
+	for _, p := range subAgent.Permissions {
+		if !subAgentPermissions[p] {
+			return t.WriteError(fmt.Sprintf("unknown point of sale permission: %s", p))
+		}
+	}
+	if err = validateAgentLimit(subAgent.Limit); err != nil {
+		return t.WriteError(err)
+	}
+
+	if subAgent.UpdatedAt, err = txTime(stub); err != nil {
+		return t.WriteError(err)
+	}
+
+	key, err := t.getSubAgentKey(stub, subAgent.AgentId, subAgent.Id)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	subAgentBytes, err := json.Marshal(subAgent)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if err = stub.PutState(key, subAgentBytes); err != nil {
+		return t.WriteError(err)
+	}
+
+	if err = stub.SetEvent(entities.SubAgentUpdated, subAgentBytes); err != nil {
+		return t.WriteError(err)
+	}
+	return t.WriteSuccess(subAgentBytes)
+}
+
+// visibleAgentId returns agent whose points of sale invoker can see, agent sees only own ones
+func visibleAgentId(agentId, invokerId, invokerRole string) (string, error) {
+	switch invokerRole {
+	case RoleMerchant:
+		if agentId == `` {
+			return ``, fmt.Errorf(`agent id is empty`)
+		}
+		return agentId, nil
+	case RoleAgent:
+		if agentId != `` && agentId != invokerId {
+			return ``, fmt.Errorf("agent can't view points of sale of another agent: %s", agentId)
+		}
+		return invokerId, nil
+	}
+	return ``, fmt.Errorf("points of sale are available only for merchant and agent, your role is: %s", invokerRole)
+}
+
+// Get points of sale of agent, arg[0] - agent id, current agent if empty
+func (t Ticket) subAgentList(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) != 1 {
+		return t.WriteError(fmt.Sprintf("arguments count mismatch: %v", args))
+	}
+
+	_, invoker, invokerRole, err := t.getActors(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if invokerRole != RoleMerchant && invokerRole != RoleAgent {
+		return t.WriteError(fmt.Sprintf("points of sale are available only for merchant and agent, your role is: %s", invokerRole))
+	}
+
+	agentId, err := visibleAgentId(args[0], invoker.OrganizationId, invokerRole)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	subAgents := []entities.SubAgent{}
+
+	iter, err := stub.GetStateByPartialCompositeKey(t.subAgentKey, []string{agentId})
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	defer iter.Close()
+	for iter.HasNext() {
+		v, err := iter.Next()
+		if err != nil {
+			return t.WriteError(err)
+		}
+		var subAgent entities.SubAgent
+		if err = json.Unmarshal(v.Value, &subAgent); err != nil {
+			return t.WriteError(err)
+		}
+		subAgents = append(subAgents, subAgent)
+	}
+
+	result, err := json.Marshal(subAgents)
+	if err != nil {
+		return t.WriteError(err)
+	}
+	return t.WriteSuccess(result)
+}
+
+// Get payments created by points of sale of invoker agent, arg[0] - point of sale id, all points of sale if empty
+// Point of sale certificate sees only own payments, payments of all points of sale are listed by agent admin
+func (t Ticket) subAgentPayments(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) != 1 {
+		return t.WriteError(fmt.Sprintf("arguments count mismatch: %v", args))
+	}
+
+	_, invoker, invokerRole, err := t.getActors(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if invokerRole != RoleAgent {
+		return t.WriteError(fmt.Sprintf("only agent can list payments of points of sale, your role is: %s", invokerRole))
+	}
+
+	subAgentId := args[0]
+	invokerSubAgentId, err := getSubAgentId(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+	if invokerSubAgentId != `` {
+		if subAgentId != `` && subAgentId != invokerSubAgentId {
+			return t.WriteError(fmt.Sprintf("point of sale %s can't view payments of another point of sale", invokerSubAgentId))
+		}
+		subAgentId = invokerSubAgentId
+	} else if access, err := t.certAccess(stub, invokerRole); err != nil {
+		return t.WriteError(err)
+	} else if accessLevels[access] < accessLevels[entities.AccessAdmin] {
+		return t.WriteError(fmt.Sprintf("certificate access %s is not enough for payments of points of sale, required: %s",
+			access, entities.AccessAdmin))
+	}
+
+	all, err := t.listPayments(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	payments := []*entities.Payment{}
+	for _, p := range all {
+		if p.PayerOrgId != invoker.OrganizationId || p.SubAgentId == `` {
+			continue
+		}
+		if subAgentId == `` || p.SubAgentId == subAgentId {
+			payments = append(payments, p)
+		}
+	}
+
+	result, err := json.Marshal(payments)
+	if err != nil {
+		return t.WriteError(err)
+	}
+	return t.WriteSuccess(result)
+}
@@ -11,12 +11,12 @@ import (
 	"s7ab-platform-hyperledger/platform/core/chaincode/base"
 	"s7ab-platform-hyperledger/platform/core/chaincode/base/extensions/crud"
 	"s7ab-platform-hyperledger/platform/core/chaincode/base/extensions/meta"
-	"s7ab-platform-hyperledger/platform/core/chaincode/base/extensions/owner"
 	"s7ab-platform-hyperledger/platform/core/chaincode/base/extensions/router"
 	platformEntities "s7ab-platform-hyperledger/platform/core/entities"
 	"s7ab-platform-hyperledger/platform/core/logger"
 	apiEntities "s7ab-platform-hyperledger/platform/s7ticket/api/tickets/entities"
 	"s7ab-platform-hyperledger/platform/s7ticket/entities"
+	"strconv"
 )
 
 const (
@@ -24,22 +24,49 @@ const (
 	RoleMerchant = "MERCHANT"
 	RoleBank     = "BANK"
 	RoleUnknown  = "UNKNOWN"
+	// RoleSystem marks payment changes made by chaincode itself, e.g. schema migration
+	RoleSystem = "SYSTEM"
 )
 
 type Ticket struct {
 	base.Chaincode
 	router      *router.Group
-	owner       *owner.Owner
 	merchantKey string
 	agentKey    string
 	paymentKey  string
+
+	settlementKey    string
+	feeScheduleKey   string
+	migrationKey     string
+	configKey        string
+	limitsKey        string
+	agentUsageKey    string
+	riskRulesKey     string
+	riskAgentKey     string
+	riskPayerKey     string
+	blocklistKey     string
+	disputeKey       string
+	pnrIndexKey      string
+	reportKey        string
+	periodClosedKey  string
+	subAgentKey      string
+	subAgentUsageKey string
+	limitBreachKey   string
+	agentAccountKey  string
 	meta.Meta
 }
 
 func NewTicket(l logger.Logger) Ticket {
-	t := Ticket{agentKey: RoleAgent, merchantKey: RoleMerchant, paymentKey: `PAYMENT`}
+	t := Ticket{agentKey: RoleAgent, merchantKey: RoleMerchant, paymentKey: `PAYMENT`,
+		settlementKey: `SETTLEMENT`, feeScheduleKey: `FEE_SCHEDULE`,
+		migrationKey: `MIGRATION`, configKey: `CONFIG`,
+		limitsKey: `LIMITS`, agentUsageKey: `AGENT_USAGE`,
+		riskRulesKey: `RISK_RULES`, riskAgentKey: `RISK_AGENT`, riskPayerKey: `RISK_PAYER`,
+		blocklistKey: `BLOCKLIST`, disputeKey: `DISPUTE`, pnrIndexKey: `PNR_INDEX`,
+		reportKey: `SALES_REPORT`, periodClosedKey: `SALES_PERIOD_CLOSED`,
+		subAgentKey: `SUB_AGENT`, subAgentUsageKey: `SUB_AGENT_USAGE`, limitBreachKey: `LIMIT_BREACH`,
+		agentAccountKey: `AGENT_ACCOUNT`}
 	t.Log = l
-	t.owner = owner.NewOwner(l)
 	t.Meta = meta.NewMeta(t)
 	r := router.New()
 
@@ -47,38 +74,121 @@ func NewTicket(l logger.Logger) Ticket {
 	agentGroup := r.Group(`/agent`)
 	agentGroup.Add(`/add`, t.agentAdd)
 	agentGroup.Add(`/list`, t.agentList)
-
-	// add meta handlers
+	// settlement accounts of agent funding split payment legs, registered by agent and confirmed by bank
+	agentGroup.Add(`/account/add`, t.agentAccountAdd)
+	agentGroup.Add(`/account/confirm`, t.agentAccountConfirm)
+	agentGroup.Add(`/account/list`, t.agentAccountList)
+
+	// add point of sale handlers, point of sale of agent is taken from certificate attribute
+	subAgentGroup := r.Group(`/subagent`)
+	subAgentGroup.Add(`/set`, t.subAgentSet)
+	subAgentGroup.Add(`/list`, t.subAgentList)
+	subAgentGroup.Add(`/payments`, t.subAgentPayments)
+
+	// add meta handlers, keys and writers are restricted by entities.MetaSchema
 	metaGroup := r.Group(`/meta`)
-	metaGroup.Add(`/set`, t.SetMeta)
+	metaGroup.Add(`/set`, t.setMeta)
 	metaGroup.Add(`/get`, t.GetMeta)
+	metaGroup.Add(`/put`, t.metaPut)
+	metaGroup.Add(`/fields`, t.metaFields)
+	metaGroup.Add(`/schema`, t.metaSchema)
+
+	// add settlement handlers
+	settlementGroup := r.Group(`/settlement`)
+	settlementGroup.Add(`/create`, t.settlementCreate)
+	settlementGroup.Add(`/confirm`, t.settlementConfirm)
+	settlementGroup.Add(`/get`, t.settlementGet)
+	settlementGroup.Add(`/list`, t.settlementList)
+
+	// add fee schedule handlers
+	feesGroup := r.Group(`/fees`)
+	feesGroup.Add(`/set`, t.feesSet)
+	feesGroup.Add(`/get`, t.feesGet)
+
+	// add payment limits handlers
+	limitsGroup := r.Group(`/limits`)
+	limitsGroup.Add(`/set`, t.limitsSet)
+	limitsGroup.Add(`/get`, t.limitsGet)
+	limitsGroup.Add(`/usage`, t.limitsUsage)
+	limitsGroup.Add(`/breach`, t.limitsBreach)
+
+	// add risk rules handlers, payments in RiskReview are released or rejected by merchant with /updateState
+	riskGroup := r.Group(`/risk`)
+	riskGroup.Add(`/set`, t.riskSet)
+	riskGroup.Add(`/get`, t.riskGet)
+
+	// add blocklist handlers
+	blocklistGroup := r.Group(`/blocklist`)
+	blocklistGroup.Add(`/add`, t.blocklistAdd)
+	blocklistGroup.Add(`/remove`, t.blocklistRemove)
+	blocklistGroup.Add(`/list`, t.blocklistList)
+
+	// add hold handlers, hold replaces CheckFundsSuccess when agent debits later
+	holdGroup := r.Group(`/hold`)
+	holdGroup.Add(`/confirm`, t.holdConfirm)
+	holdGroup.Add(`/capture`, t.holdCapture)
+	holdGroup.Add(`/release`, t.holdRelease)
+
+	// add dispute handlers, payer bank opens case against debited payment
+	disputeGroup := r.Group(`/dispute`)
+	disputeGroup.Add(`/open`, t.disputeOpen)
+	disputeGroup.Add(`/respond`, t.disputeRespond)
+	disputeGroup.Add(`/resolve`, t.disputeResolve)
+	disputeGroup.Add(`/get`, t.disputeGet)
+	disputeGroup.Add(`/list`, t.disputeList)
+
+	// add itinerary handlers
+	itineraryGroup := r.Group(`/itinerary`)
+	itineraryGroup.Add(`/search`, t.itinerarySearch)
+
+	// add sales report handlers, co-signed report closes agent reporting period
+	reportGroup := r.Group(`/report`)
+	reportGroup.Add(`/create`, t.reportCreate)
+	reportGroup.Add(`/sign`, t.reportSign)
+	reportGroup.Add(`/get`, t.reportGet)
+	reportGroup.Add(`/list`, t.reportList)
+
+	// add config handlers
+	configGroup := r.Group(`/config`)
+	configGroup.Add(`/update`, t.configUpdate)
+	configGroup.Add(`/get`, t.configGet)
 
 	// add main handlers
 	r.Add(`/merchant`, t.merchant)
-	r.Add(`/init`, t.initMerchant)
 	r.Add(`/create`, t.create)
 	r.Add(`/updateState`, t.updateState)
 	r.Add(`/issue`, t.issue)
+	r.Add(`/cancel`, t.cancel)
+	r.Add(`/void`, t.void)
 	r.Add(`/get`, t.get)
+	r.Add(`/list`, t.list)
 	r.Add(`/history`, t.history)
+	r.Add(`/migrate`, t.migrateHandler)
+	r.Add(`/migrate/status`, t.migrateStatus)
 	t.router = r
 	return t
 }
 
-// Init sets current chaincode owner if owner is presented
-// Sets current MSP if owner isn't presented
+// Init sets chaincode config from json payload: merchant, owner, default currency, timeouts and limits
+// Owner is set to creator MSP if it isn't presented in payload, payload can be omitted on upgrade
+// On upgrade runs first chunk of payments migration, rest is processed with /migrate
 func (t Ticket) Init(stub shim.ChaincodeStubInterface) pb.Response {
-	_, args := stub.GetFunctionAndParameters()
-	mspId := args[0]
-	err := stub.PutState(t.merchantKey, []byte(mspId))
-	if err != nil {
+	if err := t.initConfig(stub); err != nil {
+		return t.WriteError(err)
+	}
+
+	if err := t.migrateOnUpgrade(stub); err != nil {
 		return t.WriteError(err)
 	}
 
-	return t.owner.SetFromFirstArgOrCreator(stub)
+	return t.WriteSuccess(nil)
 }
 
+// Invoke checks access level of invoker certificate for route and routes transaction
 func (t Ticket) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	if err := t.checkRouteAccess(stub); err != nil {
+		return t.WriteError(err)
+	}
 	return t.router.Handle(stub)
 }
 
@@ -114,10 +224,10 @@ func (t Ticket) GetMetaKey(stub shim.ChaincodeStubInterface) (string, error) {
 }
 
 // GetMetaData is interface method for getting meta data from stub
-// Expecting to get from stub less 2 arguments: [meta_key,meta_value]
+// Expecting to get from stub less 3 arguments: [key,meta_key,meta_value]
 func (t Ticket) GetMetaData(stub shim.ChaincodeStubInterface) ([]byte, error) {
 	_, args := stub.GetFunctionAndParameters()
-	if len(args) < 2 {
+	if len(args) < 3 {
 		return nil, meta.ErrMetaKeyNotPresented
 	}
 
@@ -139,6 +249,10 @@ func (t Ticket) GetStateDataWithMeta(stub shim.ChaincodeStubInterface) ([]byte,
 				if err = json.Unmarshal(data, &p); err != nil {
 					return nil, err
 				}
+				// payments stored with null meta, e.g. by older chaincode versions before migration, have nil map
+				if p.Meta == nil {
+					p.Meta = make(map[string][]byte)
+				}
 				p.Meta[metaKey] = metaData
 				if pBytes, err := json.Marshal(p); err != nil {
 					return nil, err
@@ -150,30 +264,6 @@ func (t Ticket) GetStateDataWithMeta(stub shim.ChaincodeStubInterface) ([]byte,
 	}
 }
 
-//todo: remove
-func (t Ticket) initMerchant(stub shim.ChaincodeStubInterface) pb.Response {
-	_, args := stub.GetFunctionAndParameters()
-	if len(args) != 1 {
-		return t.WriteError(fmt.Sprintf("Arguments count mismatch: %v", args))
-	}
-
-	existsMerchantId, err := stub.GetState(t.merchantKey)
-	if err != nil {
-		return t.WriteError(err)
-	}
-
-	if existsMerchantId != nil {
-		return t.WriteError(fmt.Sprintf("MerchantId already set: %s", existsMerchantId))
-	}
-
-	err = stub.PutState(t.merchantKey, []byte(args[0]))
-	if err != nil {
-		return t.WriteError(err)
-	}
-
-	return t.WriteSuccess(nil)
-}
-
 func (t Ticket) getMember(APIstub shim.ChaincodeStubInterface, memberId string) (*platformEntities.Member, error) {
 	var member platformEntities.Member
 
@@ -386,6 +476,10 @@ func (t Ticket) validatePaymentPayload(stub shim.ChaincodeStubInterface,
 
 	}
 
+	if err = t.checkBlocklist(stub, paymentCreatePayload); err != nil {
+		return
+	}
+
 	agentByItn, err := t.getMemberByItn(stub, paymentCreatePayload.PayerNumber)
 	if err != nil {
 		return
@@ -400,7 +494,12 @@ func (t Ticket) validatePaymentPayload(stub shim.ChaincodeStubInterface,
 		return errors.New(fmt.Sprintf("agent itn mismatch in payment attributes %s", agentByItn.OrganizationId))
 	}
 
-	if invoker.Requisites.SettlementAccount != paymentCreatePayload.PayerAccount {
+	// split payment is funded from leg accounts instead of agent settlement account
+	if len(paymentCreatePayload.Legs) > 0 {
+		if err = t.validatePaymentLegs(stub, invoker, paymentCreatePayload); err != nil {
+			return
+		}
+	} else if invoker.Requisites.SettlementAccount != paymentCreatePayload.PayerAccount {
 		return errors.New(fmt.Sprintf("agent account mismatch in payment attributes, agent account: %s, payerAccount: %s",
 			invoker.Requisites.SettlementAccount, paymentCreatePayload.PayerAccount))
 	}
@@ -410,6 +509,12 @@ func (t Ticket) validatePaymentPayload(stub shim.ChaincodeStubInterface,
 			merchantByItn.Requisites.SettlementAccount, paymentCreatePayload.RecipientAccount))
 	}
 
+	if paymentCreatePayload.Itinerary != nil {
+		if err = validateItinerary(*paymentCreatePayload.Itinerary, paymentCreatePayload.Amount); err != nil {
+			return fmt.Errorf("invalid itinerary: %s", err)
+		}
+	}
+
 	return nil
 }
 
@@ -439,19 +544,36 @@ func (t Ticket) create(stub shim.ChaincodeStubInterface) pb.Response {
 		return t.WriteError(err)
 	}
 
+	if err = checkAmountAccess(stub, invokerRole, paymentCreatePayload.Amount); err != nil {
+		return t.WriteError(err)
+	}
+
 	log.Println("Payer number:", paymentCreatePayload.PayerNumber)
 
+	config, err := t.getConfig(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if paymentCreatePayload.Currency == `` {
+		paymentCreatePayload.Currency = config.DefaultCurrency
+	}
+
+	if err = validatePaymentAmount(config, paymentCreatePayload.Amount); err != nil {
+		return t.WriteError(err)
+	}
+
 	if err = t.validatePaymentPayload(stub, paymentCreatePayload, invoker); err != nil {
 		return t.WriteError(err)
 	}
 
 	payment := entities.Payment{
-		Id:                  paymentCreatePayload.Id,
-		Amount:              paymentCreatePayload.Amount,
-		Currency:            paymentCreatePayload.Currency,
-		InternationalFlight: paymentCreatePayload.InternationalFlight,
-		PaymentType:         `SALE`,
-		State:               entities.CheckFundsRequest,
+		SchemaVersion: entities.PaymentSchemaVersion,
+		Id:            paymentCreatePayload.Id,
+		Amount:        paymentCreatePayload.Amount,
+		Currency:      paymentCreatePayload.Currency,
+		PaymentType:   `SALE`,
+		State:         entities.CheckFundsRequest,
 
 		PayerOrgId:     invoker.OrganizationId,
 		PayerId:        paymentCreatePayload.PayerId,
@@ -464,6 +586,54 @@ func (t Ticket) create(stub shim.ChaincodeStubInterface) pb.Response {
 		RecipientId:        paymentCreatePayload.RecipientId,
 		RecipientNumber:    paymentCreatePayload.RecipientNumber,
 		RecipientAccount:   paymentCreatePayload.RecipientAccount,
+
+		UpdatedBy:     invoker.OrganizationId,
+		UpdatedByRole: invokerRole,
+
+		Meta: make(map[string][]byte),
+	}
+
+	subAgent, err := t.invokerSubAgent(stub, invoker.OrganizationId)
+	if err != nil {
+		return t.WriteError(err)
+	}
+	if subAgent != nil {
+		payment.SubAgentId = subAgent.Id
+	}
+
+	if len(paymentCreatePayload.Legs) > 0 {
+		payment.Legs = newPaymentLegs(paymentCreatePayload.Legs)
+		payment.PayerBankOrgId = payment.Legs[0].BankOrgId
+		payment.PayerAccount = payment.Legs[0].Account
+	}
+
+	// international flight drives fees, it is derived only from itinerary and agent flag is ignored
+	if paymentCreatePayload.Itinerary != nil {
+		payment.Itinerary = paymentCreatePayload.Itinerary
+		payment.InternationalFlight = payment.Itinerary.International()
+	}
+
+	feeSchedule, err := t.getFeeSchedule(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if payment.PaymentFees, err = calculateFees(feeSchedule, &payment); err != nil {
+		return t.WriteError(err)
+	}
+
+	if err = t.checkAgentLimit(stub, invoker.OrganizationId, &payment); err != nil {
+		return t.WriteError(err)
+	}
+
+	if subAgent != nil {
+		if err = t.checkSubAgentLimit(stub, subAgent, &payment); err != nil {
+			return t.WriteError(err)
+		}
+	}
+
+	if err = t.screenPayment(stub, &payment); err != nil {
+		return t.WriteError(err)
 	}
 
 	paymentKey := t.getPaymentKey(payment.Id)
@@ -474,13 +644,21 @@ func (t Ticket) create(stub shim.ChaincodeStubInterface) pb.Response {
 		return t.WriteError(err)
 	}
 
+	if payment.Itinerary != nil {
+		if err = t.putPNRIndex(stub, &payment); err != nil {
+			return t.WriteError(err)
+		}
+	}
+
 	event := entities.TicketsPaymentStateChangedEvent{
 		PaymentId:    payment.Id,
-		CurrentState: entities.CheckFundsRequest,
+		CurrentState: payment.State,
 		PaymentKey:   paymentKey,
 		To:           *merchant,
 		From:         *invoker,
 		Amount:       payment.Amount,
+		Currency:     payment.Currency,
+		PaymentFees:  payment.PaymentFees,
 	}
 
 	if eventBytes, err := json.Marshal(event); err != nil {
@@ -512,6 +690,10 @@ func (t Ticket) updateState(stub shim.ChaincodeStubInterface) pb.Response {
 		return t.WriteError("paymentId is empty")
 	}
 
+	if holdStates[payload.State] {
+		return t.WriteError(fmt.Sprintf("state %s is changed only with /hold routes", payload.State))
+	}
+
 	merchant, invoker, invokerRole, err := t.getActors(stub)
 	if err != nil {
 		return t.WriteError(err)
@@ -522,8 +704,91 @@ func (t Ticket) updateState(stub shim.ChaincodeStubInterface) pb.Response {
 		return t.WriteError(err)
 	}
 
-	if err = t.canChangePaymentState(payment, payload.State, invoker, invokerRole); err != nil {
+	if invokerRole == RoleAgent {
+		if err = t.checkSubAgentPayment(stub, payment, ``); err != nil {
+			return t.WriteError(err)
+		}
+	}
+
+	if err = checkAmountAccess(stub, invokerRole, payment.Amount); err != nil {
+		return t.WriteError(err)
+	}
+
+	config, err := t.getConfig(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if err = checkStateAccess(stub, config, invokerRole, payload.State); err != nil {
+		return t.WriteError(err)
+	}
+
+	if err = t.checkPeriodLock(stub, payment); err != nil {
+		return t.WriteError(err)
+	}
+
+	// banks change legs of split payment, payment state is derived from legs
+	var leg *entities.PaymentLeg
+	previousState := payment.State
+	if len(payment.Legs) > 0 && invokerRole == RoleBank {
+		legs := append([]entities.PaymentLeg(nil), payment.Legs...)
+		if leg, err = t.changeLegState(payment, payload.LegId, payload.State, invoker); err != nil {
+			return t.WriteError(err)
+		}
+		// leg change to four-eyes state is approved with leg amount,
+		// legs are kept unchanged until second signer repeats the change
+		if pending, err := t.approveStateChange(stub, payment, payload.State, leg, invoker, invokerRole); err != nil {
+			return t.WriteError(err)
+		} else if pending {
+			payment.Legs, payment.State = legs, previousState
+			approvalBytes, err := t.putPendingApproval(stub, payment)
+			if err != nil {
+				return t.WriteError(err)
+			}
+			return t.WriteSuccess(approvalBytes)
+		}
+	} else if err = t.canChangePaymentState(payment, payload.State, invoker, invokerRole); err != nil {
 		return t.WriteError(err)
+	} else {
+		// first signer of four-eyes state change only opens approval, state is changed by second signer
+		if pending, err := t.approveStateChange(stub, payment, payload.State, nil, invoker, invokerRole); err != nil {
+			return t.WriteError(err)
+		} else if pending {
+			approvalBytes, err := t.putPendingApproval(stub, payment)
+			if err != nil {
+				return t.WriteError(err)
+			}
+			return t.WriteSuccess(approvalBytes)
+		}
+
+		if isRetry(payment, payload.State) {
+			if err = applyRetry(payment, config.Retries()); err != nil {
+				return t.WriteError(err)
+			}
+		}
+
+		payment.State = payload.State
+		if payload.State == entities.TicketCanceled {
+			// debited legs of canceled payment are refunded by their banks
+			if err = cancelPaymentLegs(payment); err != nil {
+				return t.WriteError(err)
+			}
+		} else {
+			// legs waiting for agent move with payment: debit is requested from every leg bank, failed legs are retried
+			for i := range payment.Legs {
+				if payment.Legs[i].State == previousState {
+					payment.Legs[i].State = payload.State
+				}
+			}
+		}
+	}
+	recordFailure(payment, payload.State, payload.Reason)
+
+	if payment.State == entities.TicketCanceled {
+		payment.CancelReason = entities.CancelReasonDebitFail
+		if payment.CanceledAt, err = txTime(stub); err != nil {
+			return t.WriteError(err)
+		}
 	}
 
 	agent, err := t.getMemberByItn(stub, payment.PayerNumber)
@@ -534,15 +799,18 @@ func (t Ticket) updateState(stub shim.ChaincodeStubInterface) pb.Response {
 	event := entities.TicketsPaymentStateChangedEvent{
 		PaymentKey:    t.getPaymentKey(payment.Id),
 		PaymentId:     payment.Id,
-		PreviousState: payment.State,
-		CurrentState:  payload.State,
+		PreviousState: previousState,
+		CurrentState:  payment.State,
 		Amount:        payment.Amount,
 		Currency:      payment.Currency,
 		To:            *merchant,
 		From:          *agent,
+		PaymentFees:   payment.PaymentFees,
+		Leg:           leg,
 	}
 
-	payment.State = payload.State
+	payment.UpdatedBy = invoker.OrganizationId
+	payment.UpdatedByRole = invokerRole
 
 	paymentWithNewStateBytes, err := json.Marshal(payment)
 	if err != nil {
@@ -593,10 +861,6 @@ func (t Ticket) canChangePaymentState(
 	return
 }
 
-func (t Ticket) issue(stub shim.ChaincodeStubInterface) pb.Response {
-	return t.WriteError(`not implemented`)
-}
-
 // get payment struct by payment id
 func (t Ticket) getPayment(stub shim.ChaincodeStubInterface, paymentId string) (payment *entities.Payment, err error) {
 	paymentBytes, err := stub.GetState(t.getPaymentKey(paymentId))
@@ -616,6 +880,38 @@ func (t Ticket) isPaymentExists(stub shim.ChaincodeStubInterface, paymentId stri
 	return paymentBytes != nil, err
 }
 
+// putPayment saves payment struct to state
+func (t Ticket) putPayment(stub shim.ChaincodeStubInterface, payment *entities.Payment) error {
+	paymentBytes, err := json.Marshal(payment)
+	if err != nil {
+		return err
+	}
+	return stub.PutState(t.getPaymentKey(payment.Id), paymentBytes)
+}
+
+// listPayments returns all payments from state ordered by key
+func (t Ticket) listPayments(stub shim.ChaincodeStubInterface) (payments []*entities.Payment, err error) {
+	// payment keys are "PAYMENT_<id>", "`" is the next symbol after "_"
+	iter, err := stub.GetStateByRange(t.paymentKey+"_", t.paymentKey+"`")
+	if err != nil {
+		return
+	}
+	defer iter.Close()
+
+	for iter.HasNext() {
+		v, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		var payment entities.Payment
+		if err = json.Unmarshal(v.Value, &payment); err != nil {
+			return nil, err
+		}
+		payments = append(payments, &payment)
+	}
+	return
+}
+
 // public func, return byte representa
 func (t Ticket) get(stub shim.ChaincodeStubInterface) pb.Response {
 	_, args := stub.GetFunctionAndParameters()
@@ -630,6 +926,58 @@ func (t Ticket) get(stub shim.ChaincodeStubInterface) pb.Response {
 	return t.WriteSuccess(paymentBytes)
 }
 
+// List payments visible to invoker ordered by key, arg[0] - limit, arg[1] - offset
+func (t Ticket) list(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) != 2 {
+		return t.WriteError(fmt.Sprintf("arguments count mismatch: %v", args))
+	}
+
+	limit, err := strconv.Atoi(args[0])
+	if err != nil || limit <= 0 {
+		return t.WriteError(fmt.Sprintf("invalid limit: %s", args[0]))
+	}
+
+	offset, err := strconv.Atoi(args[1])
+	if err != nil || offset < 0 {
+		return t.WriteError(fmt.Sprintf("invalid offset: %s", args[1]))
+	}
+
+	_, invoker, invokerRole, err := t.getActors(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	if invokerRole == RoleUnknown {
+		return t.WriteError(`unknown invoker can't list payments`)
+	}
+
+	payments, err := t.listPayments(stub)
+	if err != nil {
+		return t.WriteError(err)
+	}
+
+	visible := make([]*entities.Payment, 0)
+	for _, payment := range payments {
+		if canViewPayment(payment, invoker.OrganizationId, invokerRole) {
+			visible = append(visible, payment)
+		}
+	}
+
+	if offset > len(visible) {
+		offset = len(visible)
+	}
+	if offset+limit < len(visible) {
+		visible = visible[:offset+limit]
+	}
+
+	paymentsBytes, err := json.Marshal(visible[offset:])
+	if err != nil {
+		return t.WriteError(err)
+	}
+	return t.WriteSuccess(paymentsBytes)
+}
+
 func (t Ticket) roleCanChangeState(role string, state entities.PaymentState) bool {
 
 	roleCanChangeState := map[entities.PaymentState]string{
@@ -639,6 +987,8 @@ func (t Ticket) roleCanChangeState(role string, state entities.PaymentState) boo
 		entities.DebitRequest:         RoleBank,
 		entities.DebitInProgress:      RoleBank,
 		entities.DebitFail:            RoleAgent,
+		entities.CheckFundsFail:       RoleAgent,
+		entities.RiskReview:           RoleMerchant,
 	}
 
 	needRole, ok := roleCanChangeState[state]
@@ -660,38 +1010,17 @@ func (t Ticket) createFSM(currentState entities.PaymentState) *fsm.FSM {
 			{Name: string(entities.TicketIssuanceTimeout), Src: []string{}, Dst: string(entities.TicketIssuanceTimeout)},
 
 			{Name: string(entities.Refunded), Src: []string{string(entities.DebitSuccess)}, Dst: string(entities.Refunded)},
+
+			{Name: string(entities.CheckFundsRequest), Src: []string{string(entities.CheckFundsFail)}, Dst: string(entities.CheckFundsRequest)},
+			{Name: string(entities.DebitRequest), Src: []string{string(entities.DebitFail)}, Dst: string(entities.DebitRequest)},
+
+			{Name: string(entities.FundsHeld), Src: []string{string(entities.CheckFundsInProgress)}, Dst: string(entities.FundsHeld)},
+			{Name: string(entities.DebitRequest), Src: []string{string(entities.FundsHeld)}, Dst: string(entities.DebitRequest)},
+			{Name: string(entities.HoldReleased), Src: []string{string(entities.FundsHeld)}, Dst: string(entities.HoldReleased)},
+
+			{Name: string(entities.CheckFundsRequest), Src: []string{string(entities.RiskReview)}, Dst: string(entities.CheckFundsRequest)},
+			{Name: string(entities.RiskRejected), Src: []string{string(entities.RiskReview)}, Dst: string(entities.RiskRejected)},
 		},
 		fsm.Callbacks{},
 	)
 }
-
-func (t Ticket) history(stub shim.ChaincodeStubInterface) pb.Response {
-	key, err := t.GetKey(stub)
-	if err != nil {
-		return t.WriteError(err)
-	}
-	if iterator, err := stub.GetHistoryForKey(key); err != nil {
-		return t.WriteError(err)
-	} else {
-		defer iterator.Close()
-		var mods []platformEntities.KeyModification
-
-		for iterator.HasNext() {
-			if modification, err := iterator.Next(); err != nil {
-				return t.WriteError(err)
-			} else {
-				mods = append(mods, platformEntities.KeyModification{
-					TxID:     modification.TxId,
-					Payload:  modification.Value,
-					Time:     modification.Timestamp.Seconds,
-					IsDelete: modification.IsDelete,
-				})
-			}
-		}
-		if modsBytes, err := json.Marshal(mods); err != nil {
-			return t.WriteError(err)
-		} else {
-			return t.WriteSuccess(modsBytes)
-		}
-	}
-}
@@ -1,16 +1,34 @@
 package chaincode
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"strings"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"testing"
+	"time"
 
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/ledger/queryresult"
 	coreCC "s7ab-platform-hyperledger/platform/core/chaincode"
+	"s7ab-platform-hyperledger/platform/core/chaincode/base/extensions/meta"
 	"s7ab-platform-hyperledger/platform/core/logger"
 
 	. "s7ab-platform-hyperledger/platform/s7platform/testing"
 	s7t "s7ab-platform-hyperledger/platform/s7platform/testing"
 	"s7ab-platform-hyperledger/platform/s7platform/tests/fixture"
+	apiEntities "s7ab-platform-hyperledger/platform/s7ticket/api/tickets/entities"
 	"s7ab-platform-hyperledger/platform/s7ticket/entities"
 	ticketFixture "s7ab-platform-hyperledger/platform/s7ticket/tests/fixture"
 )
@@ -24,11 +42,79 @@ func TestTickets(t *testing.T) {
 	RunSpecs(t, "Tickets Suite")
 }
 
+// orgUser is client of organization with certificate attributes
+type orgUser struct {
+	org   fixture.OrgFixture
+	attrs map[string]string
+}
+
+// attrCert returns self-signed certificate with attributes extension issued by fabric-ca
+// Certificates with equal attributes have equal subject and are the same client identity
+func attrCert(attrs map[string]string) string {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	attrsBytes, _ := json.Marshal(map[string]interface{}{`attrs`: attrs})
+	template := x509.Certificate{
+		SerialNumber:    big.NewInt(1),
+		Subject:         pkix.Name{CommonName: fmt.Sprint(attrs)},
+		NotBefore:       time.Now().Add(-time.Hour),
+		NotAfter:        time.Now().Add(time.Hour),
+		ExtraExtensions: []pkix.Extension{{Id: asn1.ObjectIdentifier{1, 2, 3, 4, 5, 6, 7, 8, 1}, Value: attrsBytes}},
+	}
+	certBytes, _ := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	return string(pem.EncodeToMemory(&pem.Block{Type: `CERTIFICATE`, Bytes: certBytes}))
+}
+
+// adminOf returns client of organization with admin role attribute, which passes routes even when config enforces attributes
+func adminOf(org fixture.OrgFixture, role string) orgUser {
+	return orgUser{org, map[string]string{entities.AttrRole: strings.ToLower(role) + `-` + entities.AccessAdmin}}
+}
+
 func orgToCreatorTransformer(params ...interface{}) (mspID, cert string) {
+	if user, ok := params[0].(orgUser); ok {
+		return user.org.OrgData().OrganizationId, attrCert(user.attrs)
+	}
 	org := params[0].(fixture.OrgFixture).OrgData()
 	return org.OrganizationId, org.OrganizationCACert
 }
 
+// argsStub returns fixed function and arguments, it is used to call handlers directly
+type argsStub struct {
+	shim.ChaincodeStubInterface
+	args []string
+}
+
+func (s argsStub) GetFunctionAndParameters() (string, []string) {
+	return s.args[0], s.args[1:]
+}
+
+// historyStub returns fixed key modifications as history of any key
+type historyStub struct {
+	argsStub
+	modifications []*queryresult.KeyModification
+}
+
+func (s historyStub) GetHistoryForKey(key string) (shim.HistoryQueryIteratorInterface, error) {
+	return &historyIterator{modifications: s.modifications}, nil
+}
+
+type historyIterator struct {
+	modifications []*queryresult.KeyModification
+}
+
+func (i *historyIterator) HasNext() bool {
+	return len(i.modifications) > 0
+}
+
+func (i *historyIterator) Next() (*queryresult.KeyModification, error) {
+	next := i.modifications[0]
+	i.modifications = i.modifications[1:]
+	return next, nil
+}
+
+func (i *historyIterator) Close() error {
+	return nil
+}
+
 func ExpectPaymentState(tickets *s7t.FullMockStub, paymentId string, state entities.PaymentState) {
 	paymentFromChaincode, _ := ticketFixture.FromBytes(tickets.Invoke("/get", paymentId).Payload)
 	Expect(paymentFromChaincode.State).To(Equal(state))
@@ -45,6 +131,16 @@ var _ = Describe("Tickets", func() {
 
 	var payment, payment2 ticketFixture.PaymentFixture
 
+	// createPayload returns copy of payment fixture as create payload with another id and amount
+	createPayload := func(id string, amount uint) entities.PaymentCreatePayload {
+		var payload entities.PaymentCreatePayload
+		paymentBytes, _ := json.Marshal(payment)
+		Expect(json.Unmarshal(paymentBytes, &payload)).To(Succeed())
+		payload.Id = id
+		payload.Amount = amount
+		return payload
+	}
+
 	BeforeSuite(func() {
 
 		operator, _ = fixture.GetOrgFixture("Org1MSP.json")
@@ -63,8 +159,6 @@ var _ = Describe("Tickets", func() {
 		orgs.RegisterCreatorTransformer(orgToCreatorTransformer)
 
 		tickets = s7t.NewFullMockStub(`tickets`, NewTicket(l))
-		//Merchant is owner of tickets chaincode
-		tickets.MockInit("2", orgs.ArgsToBytes(merchant.OrganizationId))
 		tickets.MockPeerChaincode("organizations/mychannel", orgs)
 		tickets.RegisterCreatorTransformer(orgToCreatorTransformer)
 
@@ -87,6 +181,49 @@ var _ = Describe("Tickets", func() {
 			//fmt.Println(orgs.MockInvokeFunc("/get", agent.OrganizationId))
 		})
 
+		It("Allow init tickets with config payload", func() {
+			ExpectResponseError(tickets.MockInit("2", [][]byte{}), `init payload is empty`)
+			ExpectResponseError(tickets.MockInit("2", orgs.ArgsToBytes(entities.Config{Merchant: someOrg.OrganizationId, DefaultCurrency: `RUB`})),
+				`invalid merchant`)
+
+			//Merchant is owner of tickets chaincode
+			config := entities.Config{Merchant: merchant.OrganizationId, Owner: merchant.OrganizationId, DefaultCurrency: `RUB`}
+			ExpectResponseOk(tickets.MockInit("2", orgs.ArgsToBytes(config)))
+
+			//upgrade without payload keeps config
+			ExpectResponseOk(tickets.MockInit("3", [][]byte{}))
+		})
+
+		It("Seed config from merchant of version without config on upgrade", func() {
+			legacy := s7t.NewFullMockStub(`legacy`, NewTicket(l))
+			legacy.MockTransactionStart(`merchant`)
+			legacy.PutState(`MERCHANT`, []byte(merchant.OrganizationId))
+			legacy.MockTransactionEnd(`merchant`)
+
+			legacy.MockCreator(merchant.OrganizationId, merchant.OrganizationCACert)
+			ExpectResponseOk(legacy.MockInit("1", [][]byte{}))
+
+			var config entities.Config
+			Expect(json.Unmarshal(legacy.MockInvokeFunc("/config/get").Payload, &config)).To(Succeed())
+			Expect(config.Merchant).To(Equal(merchant.OrganizationId))
+			Expect(config.Owner).To(Equal(merchant.OrganizationId))
+			Expect(config.Retries()).To(Equal(uint(defaultMaxRetries)))
+		})
+
+		It("Allow only owner to update config", func() {
+			config := entities.Config{Merchant: merchant.OrganizationId, Owner: merchant.OrganizationId, DefaultCurrency: `RUB`, MinPaymentAmount: 1}
+			ExpectResponseError(tickets.From(adminOf(agent, RoleAgent)).Invoke("/config/update", config), `only owner can update config`)
+			moved := config
+			moved.Merchant = agent.OrganizationId
+			ExpectResponseError(tickets.From(adminOf(merchant, RoleMerchant)).Invoke("/config/update", moved), `merchant can't be changed`)
+			ExpectResponseOk(tickets.From(adminOf(merchant, RoleMerchant)).Invoke("/config/update", config))
+
+			var configFromChaincode entities.Config
+			Expect(json.Unmarshal(tickets.MockInvokeFunc("/config/get").Payload, &configFromChaincode)).To(Succeed())
+			Expect(configFromChaincode.MinPaymentAmount).To(Equal(uint(1)))
+			Expect(configFromChaincode.IssuanceTimeout).To(Equal(uint(defaultIssuanceTimeout)))
+		})
+
 		It("Allow add agent", func() {
 			merchantFromChaincode, _ := fixture.GetMemberFromBytes(tickets.MockInvokeFunc("/merchant").Payload)
 			Expect(merchantFromChaincode.OrganizationId).To(Equal(merchant.OrganizationId))
@@ -117,6 +254,26 @@ var _ = Describe("Tickets", func() {
 			ExpectResponseOk(tickets.From(agent2).Invoke("/create", payment2))
 		})
 
+		It("List payments visible to invoker by pages", func() {
+			var payments []entities.Payment
+			Expect(json.Unmarshal(tickets.From(merchant).Invoke("/list", `10`, `0`).Payload, &payments)).To(Succeed())
+			Expect(payments).To(HaveLen(2))
+
+			var page []entities.Payment
+			Expect(json.Unmarshal(tickets.From(merchant).Invoke("/list", `1`, `1`).Payload, &page)).To(Succeed())
+			Expect(page).To(Equal(payments[1:]))
+
+			Expect(json.Unmarshal(tickets.From(agent).Invoke("/list", `10`, `0`).Payload, &payments)).To(Succeed())
+			Expect(payments).To(HaveLen(1))
+			Expect(payments[0].Id).To(Equal(payment.Id))
+
+			Expect(json.Unmarshal(tickets.From(bank2).Invoke("/list", `10`, `5`).Payload, &payments)).To(Succeed())
+			Expect(payments).To(BeEmpty())
+
+			ExpectResponseError(tickets.From(merchant).Invoke("/list", `0`, `0`), `invalid limit: 0`)
+			ExpectResponseError(tickets.From(someOrg).Invoke("/list", `10`, `0`), `unknown invoker can't list payments`)
+		})
+
 		It("Disallow non agents to  add payment", func() {
 			paymentNew, _ := ticketFixture.GetFixture("payment_1_SALE_from_Org4MSP.json")
 
@@ -225,4 +382,1251 @@ var _ = Describe("Tickets", func() {
 		})
 
 	})
+
+	Describe("Retries", func() {
+		It("Default max retries only when it is omitted", func() {
+			defaults, err := Ticket{}.parseConfig(nil, `{"owner":"owner"}`)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(defaults.Retries()).To(Equal(uint(defaultMaxRetries)))
+
+			disabled, err := Ticket{}.parseConfig(nil, `{"owner":"owner","maxRetries":0}`)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(disabled.Retries()).To(Equal(uint(0)))
+
+			// config stored before retries
+			Expect(entities.Config{}.Retries()).To(Equal(uint(0)))
+		})
+
+		It("Default void window only when it is omitted", func() {
+			defaults, err := Ticket{}.parseConfig(nil, `{"owner":"owner"}`)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(defaults.VoidSeconds()).To(Equal(uint(defaultVoidWindow)))
+
+			disabled, err := Ticket{}.parseConfig(nil, `{"owner":"owner","voidWindow":0}`)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(disabled.VoidSeconds()).To(Equal(uint(0)))
+
+			// config stored before voids
+			Expect(entities.Config{}.VoidSeconds()).To(Equal(uint(0)))
+		})
+
+		It("Allow agent to retry failed debit until max retries", func() {
+			ExpectResponseError(tickets.From(bank2).Invoke("/updateState", ticketFixture.UpdateState(payment2.Id, entities.DebitRequest)),
+				`role can't change from state: DebitFail`)
+			ExpectResponseOk(tickets.From(agent2).Invoke("/updateState", ticketFixture.UpdateState(payment2.Id, entities.DebitRequest)))
+
+			retried, _ := ticketFixture.FromBytes(tickets.MockInvokeFunc("/get", payment2.Id).Payload)
+			Expect(retried.RetryCount).To(Equal(uint(1)))
+			Expect(retried.LastFailureReason).To(Equal(string(entities.DebitFail)))
+
+			debitFail := apiEntities.RequestUpdateState{PaymentId: payment2.Id, State: entities.DebitFail, Reason: `insufficient funds`}
+			for i := 0; i < defaultMaxRetries; i++ {
+				ExpectResponseOk(tickets.From(bank2).Invoke("/updateState", ticketFixture.UpdateState(payment2.Id, entities.DebitInProgress)))
+				ExpectResponseOk(tickets.From(bank2).Invoke("/updateState", debitFail))
+				if i < defaultMaxRetries-1 {
+					ExpectResponseOk(tickets.From(agent2).Invoke("/updateState", ticketFixture.UpdateState(payment2.Id, entities.DebitRequest)))
+				}
+			}
+
+			ExpectResponseError(tickets.From(agent2).Invoke("/updateState", ticketFixture.UpdateState(payment2.Id, entities.DebitRequest)),
+				`payment retries exhausted: 3 of 3`)
+
+			retried, _ = ticketFixture.FromBytes(tickets.MockInvokeFunc("/get", payment2.Id).Payload)
+			Expect(retried.State).To(Equal(entities.DebitFail))
+			Expect(retried.LastFailureReason).To(Equal(`insufficient funds`))
+		})
+	})
+
+	Describe("Cancellation", func() {
+		It("Allow payer agent to cancel payment before debit", func() {
+			abandoned := createPayload(`abandoned`, payment.Amount)
+			ExpectResponseOk(tickets.From(agent).Invoke("/create", abandoned))
+			ExpectResponseOk(tickets.From(bank).Invoke("/updateState", ticketFixture.UpdateState(abandoned.Id, entities.CheckFundsInProgress)))
+			ExpectResponseOk(tickets.From(bank).Invoke("/updateState", ticketFixture.UpdateState(abandoned.Id, entities.CheckFundsSuccess)))
+
+			cancel := entities.CancelPayload{PaymentId: abandoned.Id, Reason: `booking abandoned`}
+			ExpectResponseError(tickets.From(bank).Invoke("/cancel", cancel), `only payer agent can cancel payment`)
+			ExpectResponseError(tickets.From(agent2).Invoke("/cancel", cancel), `only payer agent can cancel payment`)
+			ExpectResponseOk(tickets.From(agent).Invoke("/cancel", cancel))
+
+			canceled, _ := ticketFixture.FromBytes(tickets.MockInvokeFunc("/get", abandoned.Id).Payload)
+			Expect(canceled.State).To(Equal(entities.TicketCanceled))
+			Expect(canceled.CancelReason).To(Equal(entities.CancelReasonAgentCancel))
+
+			ExpectResponseError(tickets.From(agent).Invoke("/cancel", cancel), `payment can't be canceled in state: TicketCanceled`)
+		})
+
+		It("Disallow agent to cancel payment after debit", func() {
+			ExpectResponseError(tickets.From(agent).Invoke("/cancel", entities.CancelPayload{PaymentId: payment.Id}),
+				`payment can't be canceled in state: DebitSuccess`)
+		})
+
+		It("Record reason of cancellation after failed debit", func() {
+			ExpectResponseOk(tickets.From(agent2).Invoke("/updateState", ticketFixture.UpdateState(payment2.Id, entities.TicketCanceled)))
+
+			canceled, _ := ticketFixture.FromBytes(tickets.MockInvokeFunc("/get", payment2.Id).Payload)
+			Expect(canceled.State).To(Equal(entities.TicketCanceled))
+			Expect(canceled.CancelReason).To(Equal(entities.CancelReasonDebitFail))
+		})
+
+		It("Allow merchant to void issued ticket during void window", func() {
+			issuedAt := time.Date(2030, 1, 1, 10, 0, 0, 0, time.UTC)
+			issued := &entities.Payment{State: entities.DebitSuccess, IssuedAt: issuedAt.Format(time.RFC3339Nano)}
+			Expect(voidable(issued, defaultVoidWindow, issuedAt.Add(time.Hour))).To(Succeed())
+			Expect(voidable(issued, defaultVoidWindow, issuedAt.Add(25*time.Hour))).NotTo(Succeed())
+
+			void := entities.CancelPayload{PaymentId: payment.Id, Reason: `duplicate booking`}
+			ExpectResponseError(tickets.From(merchant).Invoke("/void", void), `only issued ticket can be voided`)
+
+			ExpectResponseOk(tickets.From(agent).Invoke("/issue", entities.IssuePayload{PaymentId: payment.Id, TicketNumber: `555-1234567890`}))
+			ExpectResponseError(tickets.From(agent).Invoke("/issue", entities.IssuePayload{PaymentId: payment.Id, TicketNumber: `555-1`}),
+				`ticket already issued`)
+
+			ExpectResponseError(tickets.From(agent).Invoke("/void", void), `only merchant can void ticket`)
+			ExpectResponseOk(tickets.From(merchant).Invoke("/void", void))
+
+			voided, _ := ticketFixture.FromBytes(tickets.MockInvokeFunc("/get", payment.Id).Payload)
+			Expect(voided.State).To(Equal(entities.Refunded))
+			Expect(voided.CancelReason).To(Equal(entities.CancelReasonVoid))
+			Expect(voided.TicketNumber).To(Equal(`555-1234567890`))
+		})
+	})
+
+	Describe("History", func() {
+		It("Diff payment versions by changed fields", func() {
+			before := entities.Payment{Id: payment.Id, State: entities.DebitInProgress, UpdatedBy: bank.OrganizationId}
+			after := before
+			after.State = entities.DebitSuccess
+
+			changes, err := diffPayments(&before, &after)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(len(changes)).To(Equal(1))
+			Expect(changes[0].Field).To(Equal(`state`))
+			Expect(changes[0].From).To(Equal(string(entities.DebitInProgress)))
+			Expect(changes[0].To).To(Equal(string(entities.DebitSuccess)))
+
+			deleted, err := diffPayments(&after, nil)
+			Expect(err).NotTo(HaveOccurred())
+			for _, change := range deleted {
+				Expect(change.To).To(BeNil())
+			}
+		})
+
+		It("Return history with actor of every version and deletion", func() {
+			created := entities.Payment{Id: `timeline`, State: entities.CheckFundsRequest,
+				UpdatedBy: agent.OrganizationId, UpdatedByRole: RoleAgent}
+			checked := created
+			checked.State = entities.CheckFundsInProgress
+			checked.UpdatedBy, checked.UpdatedByRole = bank.OrganizationId, RoleBank
+			createdBytes, _ := json.Marshal(created)
+			checkedBytes, _ := json.Marshal(checked)
+
+			response := NewTicket(l).history(historyStub{argsStub{args: []string{`/history`, created.Id}}, []*queryresult.KeyModification{
+				{TxId: `tx1`, Value: createdBytes, Timestamp: &timestamp.Timestamp{Seconds: 1893456000, Nanos: 5}},
+				{TxId: `tx2`, Value: checkedBytes, Timestamp: &timestamp.Timestamp{Seconds: 1893456060}},
+				{TxId: `tx3`, IsDelete: true, Timestamp: &timestamp.Timestamp{Seconds: 1893456120}},
+			}})
+			ExpectResponseOk(response)
+
+			var history []entities.PaymentHistoryEntry
+			Expect(json.Unmarshal(response.Payload, &history)).To(Succeed())
+			Expect(history).To(HaveLen(3))
+
+			Expect(history[0].Timestamp).To(Equal(`2030-01-01T00:00:00.000000005Z`))
+			Expect(history[0].PreviousState).To(BeNil())
+			Expect(history[0].Actor).To(Equal(agent.OrganizationId))
+			Expect(history[0].ActorRole).To(Equal(RoleAgent))
+
+			Expect(history[1].Actor).To(Equal(bank.OrganizationId))
+			Expect(history[1].ActorRole).To(Equal(RoleBank))
+			var fields []string
+			for _, change := range history[1].Changes {
+				fields = append(fields, change.Field)
+			}
+			Expect(fields).To(Equal([]string{`state`, `updatedBy`, `updatedByRole`}))
+
+			Expect(history[2].IsDelete).To(BeTrue())
+			Expect(history[2].State).To(BeNil())
+			Expect(history[2].Actor).To(BeEmpty())
+			Expect(history[2].PreviousState.State).To(Equal(entities.CheckFundsInProgress))
+			for _, change := range history[2].Changes {
+				Expect(change.To).To(BeNil())
+			}
+		})
+	})
+
+	Describe("Settlement", func() {
+		It("Net debits and offset refunds by bank pairs", func() {
+			payments := []*entities.Payment{
+				{Id: `1`, Amount: 100, Currency: `RUB`, State: entities.DebitSuccess, PayerBankOrgId: bank.OrganizationId, RecipientBankOrgId: bank2.OrganizationId},
+				{Id: `2`, Amount: 30, Currency: `RUB`, State: entities.DebitSuccess, PayerBankOrgId: bank2.OrganizationId, RecipientBankOrgId: bank.OrganizationId},
+				{Id: `3`, Amount: 50, Currency: `RUB`, State: entities.Refunded, PayerBankOrgId: bank.OrganizationId, RecipientBankOrgId: bank2.OrganizationId},
+				{Id: `4`, Amount: 70, Currency: `USD`, State: entities.DebitSuccess, PayerBankOrgId: bank.OrganizationId, RecipientBankOrgId: bank2.OrganizationId},
+				{Id: `5`, Amount: 20, Currency: `RUB`, State: entities.DebitInProgress, PayerBankOrgId: bank.OrganizationId, RecipientBankOrgId: bank2.OrganizationId},
+			}
+
+			batch, changed := buildSettlementBatch(`batch1`, `RUB`, payments)
+			Expect(len(changed)).To(Equal(3))
+			Expect(batch.PaymentIds).To(Equal([]string{`1`, `2`, `3`}))
+			Expect(batch.RefundIds).To(Equal([]string{`3`}))
+
+			positions := map[string]int64{}
+			for _, p := range batch.Positions {
+				positions[p.BankOrgId] = p.Net
+			}
+			Expect(positions[bank.OrganizationId]).To(Equal(int64(-70)))
+			Expect(positions[bank2.OrganizationId]).To(Equal(int64(70)))
+
+			_, changed = buildSettlementBatch(`batch2`, `RUB`, payments)
+			Expect(len(changed)).To(Equal(0))
+
+			Expect(settlementView(batch, merchant.OrganizationId, RoleMerchant)).To(Equal(batch))
+			Expect(settlementView(batch, `Org9MSP`, RoleBank)).To(BeNil())
+
+			view := settlementView(batch, bank2.OrganizationId, RoleBank)
+			Expect(view.Positions).To(Equal([]entities.SettlementPosition{{BankOrgId: bank2.OrganizationId, Net: 70}}))
+			Expect(view.Pairs).To(HaveLen(2))
+			Expect(view.PaymentIds).To(Equal([]string{`1`, `2`, `3`}))
+		})
+
+		It("Show settlement batches only to merchant and banks", func() {
+			ExpectResponseError(tickets.From(agent).Invoke("/settlement/list"), `settlement batches are available only for merchant and bank`)
+			ExpectResponseError(tickets.From(someOrg).Invoke("/settlement/get", `batch1`),
+				`settlement batches are available only for merchant and bank`)
+			ExpectResponseOk(tickets.From(bank).Invoke("/settlement/list"))
+		})
+	})
+
+	Describe("Fees", func() {
+		It("Apply first matched fee rule", func() {
+			international := true
+			schedule := entities.FeeSchedule{Version: 2, Rules: []entities.FeeRule{
+				{Id: `intl`, InternationalFlight: &international, CommissionBps: 300, MerchantFeeFixed: 10},
+				{Id: `default`, CommissionBps: 100, MerchantFeeBps: 50},
+			}}
+			Expect(validateFeeSchedule(schedule)).To(Succeed())
+
+			fees, err := calculateFees(schedule, &entities.Payment{Amount: 1000, InternationalFlight: true})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fees.FeeRuleId).To(Equal(`intl`))
+			Expect(fees.AgentCommission).To(Equal(uint(30)))
+			Expect(fees.MerchantFee).To(Equal(uint(10)))
+			Expect(fees.NetAmount).To(Equal(uint(960)))
+
+			fees, err = calculateFees(schedule, &entities.Payment{Amount: 1000})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fees.FeeRuleId).To(Equal(`default`))
+			Expect(fees.NetAmount).To(Equal(uint(985)))
+		})
+
+		It("Allow only merchant to set fee schedule", func() {
+			schedule := entities.FeeSchedule{Rules: []entities.FeeRule{{Id: `default`, CommissionBps: 100}}}
+			ExpectResponseError(tickets.From(adminOf(agent, RoleAgent)).Invoke("/fees/set", schedule), `only merchant can set fee schedule`)
+			ExpectResponseOk(tickets.From(adminOf(merchant, RoleMerchant)).Invoke("/fees/set", schedule))
+		})
+	})
+
+	Describe("Limits", func() {
+		It("Count agent payments in rolling window", func() {
+			limit := entities.AgentLimit{WindowSeconds: 60, MaxCount: 2, MaxAmount: 1000}
+			usage := entities.AgentUsage{AgentId: `agent`}
+
+			usage, breach := applyAgentLimit(limit, usage, entities.AgentUsageEntry{PaymentId: `1`, Timestamp: 100, Amount: 400})
+			Expect(breach).To(BeNil())
+			usage, breach = applyAgentLimit(limit, usage, entities.AgentUsageEntry{PaymentId: `2`, Timestamp: 110, Amount: 700})
+			Expect(breach).NotTo(BeNil())
+			Expect(breach.Limit).To(Equal(entities.LimitAmount))
+			usage, breach = applyAgentLimit(limit, usage, entities.AgentUsageEntry{PaymentId: `3`, Timestamp: 120, Amount: 100})
+			Expect(breach).To(BeNil())
+			_, breach = applyAgentLimit(limit, usage, entities.AgentUsageEntry{PaymentId: `4`, Timestamp: 130, Amount: 100})
+			Expect(breach).NotTo(BeNil())
+			Expect(breach.Limit).To(Equal(entities.LimitCount))
+
+			// first payment is out of window
+			usage, breach = applyAgentLimit(limit, usage, entities.AgentUsageEntry{PaymentId: `5`, Timestamp: 161, Amount: 100})
+			Expect(breach).To(BeNil())
+			Expect(usage.Entries).To(HaveLen(2))
+		})
+
+		It("Allow only merchant to set payment limits", func() {
+			limits := entities.PaymentLimits{Default: entities.AgentLimit{WindowSeconds: 60, MaxCount: 10}}
+			ExpectResponseError(tickets.From(adminOf(agent, RoleAgent)).Invoke("/limits/set", limits), `only merchant can set payment limits`)
+			ExpectResponseError(tickets.From(adminOf(merchant, RoleMerchant)).Invoke("/limits/set", entities.PaymentLimits{
+				Default: entities.AgentLimit{MaxCount: 10}}), `limit window is not set`)
+			ExpectResponseOk(tickets.From(adminOf(merchant, RoleMerchant)).Invoke("/limits/set", limits))
+		})
+
+		It("Record breach of rejected payment and show usage to merchant and agent only", func() {
+			limited, _ := ticketFixture.GetFixture("payment_2_SALE_from_Org6MSP.json")
+			limited.Id = `limited`
+			ExpectResponseOk(tickets.From(adminOf(merchant, RoleMerchant)).Invoke("/limits/set", entities.PaymentLimits{
+				Agents: map[string]entities.AgentLimit{agent2.OrganizationId: {WindowSeconds: 60, MaxAmount: limited.Amount - 1}}}))
+
+			ExpectResponseError(tickets.From(agent2).Invoke("/create", limited), ErrLimitExceeded.Error())
+			ExpectResponseError(tickets.From(agent2).Invoke("/limits/breach", agent2.OrganizationId, limited), `only merchant can record limit breach`)
+			ExpectResponseOk(tickets.From(merchant).Invoke("/limits/breach", agent2.OrganizationId, limited))
+			ExpectResponseError(tickets.From(merchant).Invoke("/limits/breach", agent.OrganizationId, limited), `doesn't exceed limit`)
+
+			var limits entities.PaymentLimits
+			Expect(json.Unmarshal(tickets.From(agent).Invoke("/limits/get").Payload, &limits)).To(Succeed())
+			Expect(limits.Agents).To(Equal(map[string]entities.AgentLimit{agent.OrganizationId: {}}))
+			Expect(json.Unmarshal(tickets.From(merchant).Invoke("/limits/get").Payload, &limits)).To(Succeed())
+			Expect(limits.Agents).To(HaveKey(agent2.OrganizationId))
+			ExpectResponseError(tickets.From(bank).Invoke("/limits/get"), `available only for merchant and agent`)
+
+			ExpectResponseOk(tickets.From(agent2).Invoke("/limits/usage", ``))
+			ExpectResponseOk(tickets.From(merchant).Invoke("/limits/usage", agent2.OrganizationId))
+			ExpectResponseError(tickets.From(agent).Invoke("/limits/usage", agent2.OrganizationId), `agent can't view usage of another agent`)
+			ExpectResponseError(tickets.From(bank).Invoke("/limits/usage", agent2.OrganizationId), `available only for merchant and agent`)
+			ExpectResponseError(tickets.From(someOrg).Invoke("/limits/usage", agent2.OrganizationId), `available only for merchant and agent`)
+
+			ExpectResponseOk(tickets.From(adminOf(merchant, RoleMerchant)).Invoke("/limits/set", entities.PaymentLimits{}))
+		})
+	})
+
+	Describe("Risk", func() {
+		It("Match rules against agent and payer aggregates", func() {
+			rules := entities.RiskRules{Version: 1, Rules: []entities.RiskRule{
+				{Id: `big`, Type: entities.RiskAmount, Threshold: 5000},
+				{Id: `unusual`, Type: entities.RiskAgentAmount, Multiplier: 3, MinPayments: 2},
+				{Id: `velocity`, Type: entities.RiskVelocity, Threshold: 3, WindowSeconds: 60},
+				{Id: `payer`, Type: entities.RiskPayerAgents, Threshold: 2},
+			}}
+			Expect(validateRiskRules(rules)).To(Succeed())
+
+			agentStats := entities.AgentRiskStats{AgentId: `agent`}
+			payerStats := entities.PayerRiskStats{PayerId: `payer`}
+			p := &entities.Payment{Amount: 100, PayerId: `payer`}
+
+			Expect(assessRisk(rules, p, 100, agentStats, payerStats)).To(BeEmpty())
+			agentStats, payerStats = updateRiskStats(rules, p, 100, agentStats, payerStats)
+			agentStats, payerStats = updateRiskStats(rules, p, 110, agentStats, payerStats)
+
+			Expect(assessRisk(rules, &entities.Payment{Amount: 400}, 120, agentStats, payerStats)).To(Equal([]string{`unusual`, `velocity`}))
+			Expect(assessRisk(rules, &entities.Payment{Amount: 100}, 200, agentStats, payerStats)).To(BeEmpty())
+
+			otherAgent := entities.AgentRiskStats{AgentId: `other`}
+			Expect(assessRisk(rules, &entities.Payment{Amount: 6000, PayerId: `payer`}, 200, otherAgent, payerStats)).To(
+				Equal([]string{`big`, `payer`}))
+		})
+
+		It("Hold payment in RiskReview until merchant releases it", func() {
+			// payments created without rules don't write aggregates
+			agentKey, _ := tickets.CreateCompositeKey(`RISK_AGENT`, []string{agent.OrganizationId})
+			Expect(tickets.State).NotTo(HaveKey(agentKey))
+
+			rules := entities.RiskRules{Rules: []entities.RiskRule{{Id: `big`, Type: entities.RiskAmount, Threshold: 500}}}
+			ExpectResponseError(tickets.From(adminOf(agent, RoleAgent)).Invoke("/risk/set", rules), `only merchant can set risk rules`)
+			ExpectResponseOk(tickets.From(adminOf(merchant, RoleMerchant)).Invoke("/risk/set", rules))
+
+			risky, _ := ticketFixture.GetFixture("payment_1_SALE_from_Org4MSP.json")
+			risky.Id = `risky`
+			risky.Amount = 1000
+			ExpectResponseOk(tickets.From(agent).Invoke("/create", risky))
+			ExpectPaymentState(tickets, risky.Id, entities.RiskReview)
+			Expect(tickets.State).To(HaveKey(agentKey))
+
+			ExpectResponseOk(tickets.From(merchant).Invoke("/risk/get"))
+			ExpectResponseError(tickets.From(agent).Invoke("/risk/get"), `risk rules are available only for merchant`)
+
+			ExpectResponseError(tickets.From(bank).Invoke("/updateState", ticketFixture.UpdateState(risky.Id, entities.CheckFundsInProgress)),
+				`role can't change from state`)
+			ExpectResponseOk(tickets.From(merchant).Invoke("/updateState", ticketFixture.UpdateState(risky.Id, entities.CheckFundsRequest)))
+			ExpectPaymentState(tickets, risky.Id, entities.CheckFundsRequest)
+
+			ExpectResponseOk(tickets.From(adminOf(merchant, RoleMerchant)).Invoke("/risk/set", entities.RiskRules{}))
+		})
+	})
+
+	Describe("Blocklist", func() {
+		It("Reject payments of blocklisted payer until entry expires or is removed", func() {
+			blocked, _ := ticketFixture.GetFixture("payment_1_SALE_from_Org4MSP.json")
+			blocked.Id = `blocked`
+
+			entries := []entities.BlocklistEntry{{Type: entities.BlockAccount, Value: agent.Requisites.SettlementAccount, Reason: `sanctions`}}
+			ExpectResponseError(tickets.From(agent).Invoke("/blocklist/add", entries), `only merchant or bank can maintain blocklist`)
+			ExpectResponseError(tickets.From(bank).Invoke("/blocklist/add", []entities.BlocklistEntry{{Type: `PHONE`, Value: `1`}}),
+				`unknown blocklist entry type`)
+			ExpectResponseOk(tickets.From(bank).Invoke("/blocklist/add", entries))
+
+			ExpectResponseError(tickets.From(agent).Invoke("/create", blocked), `is blocklisted: sanctions`)
+
+			entries[0].ExpiresAt = `2000-01-01T00:00:00Z`
+			ExpectResponseOk(tickets.From(merchant).Invoke("/blocklist/add", entries))
+			Expect(blocklistEntryActive(entries[0], time.Now())).To(BeFalse())
+
+			var list []entities.BlocklistEntry
+			ExpectResponseError(tickets.From(agent).Invoke("/blocklist/list", ``), `only merchant or bank can maintain blocklist`)
+			Expect(json.Unmarshal(tickets.From(bank).Invoke("/blocklist/list", ``).Payload, &list)).To(Succeed())
+			Expect(list).To(HaveLen(1))
+			Expect(list[0].AddedBy).To(Equal(merchant.OrganizationId))
+
+			ExpectResponseError(tickets.From(bank).Invoke("/blocklist/add", entries), `can be changed only by merchant or `+merchant.OrganizationId)
+			ExpectResponseError(tickets.From(bank).Invoke("/blocklist/remove", string(entities.BlockAccount), agent.Requisites.SettlementAccount),
+				`can be changed only by merchant or `+merchant.OrganizationId)
+			ExpectResponseOk(tickets.From(merchant).Invoke("/blocklist/remove", string(entities.BlockAccount), agent.Requisites.SettlementAccount))
+			ExpectResponseOk(tickets.From(agent).Invoke("/create", blocked))
+		})
+	})
+
+	Describe("Split payments", func() {
+		It("Derive payment state from legs", func() {
+			legs := []entities.PaymentLeg{{State: entities.CheckFundsSuccess}, {State: entities.CheckFundsInProgress}}
+			Expect(derivePaymentState(legs)).To(Equal(entities.CheckFundsInProgress))
+
+			legs[1].State = entities.CheckFundsSuccess
+			Expect(derivePaymentState(legs)).To(Equal(entities.CheckFundsSuccess))
+
+			legs[0].State = entities.CheckFundsFail
+			Expect(derivePaymentState(legs)).To(Equal(entities.CheckFundsFail))
+		})
+
+		It("Treat bank of every leg as payment bank", func() {
+			split := &entities.Payment{PayerBankOrgId: bank.OrganizationId, Legs: []entities.PaymentLeg{
+				{BankOrgId: bank.OrganizationId}, {BankOrgId: bank2.OrganizationId}, {BankOrgId: bank.OrganizationId}}}
+			Expect(paymentBanks(split)).To(Equal([]string{bank.OrganizationId, bank2.OrganizationId}))
+			Expect(isPaymentBank(split, bank2.OrganizationId)).To(BeTrue())
+			Expect(paymentBanks(&entities.Payment{PayerBankOrgId: bank.OrganizationId})).To(Equal([]string{bank.OrganizationId}))
+		})
+
+		It("Fund legs only from bank settlement accounts of agent", func() {
+			ExpectResponseError(tickets.From(agent).Invoke("/agent/account/add", entities.AgentAccountPayload{
+				BankOrgId: merchant.OrganizationId, Account: `acc2`}), `Organization is not bank`)
+			ExpectResponseOk(tickets.From(agent).Invoke("/agent/account/add", entities.AgentAccountPayload{
+				BankOrgId: bank2.OrganizationId, Account: `acc2`}))
+
+			split := createPayload(`split-accounts`, 1000)
+			split.Legs = []entities.PaymentLegPayload{
+				{BankOrgId: bank.OrganizationId, Account: agent.Requisites.SettlementAccount, Amount: 600},
+				{BankOrgId: merchant.OrganizationId, Account: `acc2`, Amount: 400},
+			}
+			ExpectResponseError(tickets.From(agent).Invoke("/create", split), `payment leg 2 bank: Organization is not bank`)
+
+			split.Legs[1].BankOrgId = bank2.OrganizationId
+			ExpectResponseError(tickets.From(agent).Invoke("/create", split), `payment leg 2 account acc2 isn't confirmed`)
+
+			ExpectResponseError(tickets.From(bank).Invoke("/agent/account/confirm", agent.OrganizationId, `acc2`),
+				`settlement account acc2 of agent `+agent.OrganizationId+` at bank `+bank.OrganizationId+` not found`)
+			ExpectResponseOk(tickets.From(bank2).Invoke("/agent/account/confirm", agent.OrganizationId, `acc2`))
+
+			split.Legs[1].Account = `acc3`
+			ExpectResponseError(tickets.From(agent).Invoke("/create", split), `payment leg 2 account acc3 isn't confirmed`)
+
+			var accounts []entities.AgentAccount
+			Expect(json.Unmarshal(tickets.From(bank2).Invoke("/agent/account/list").Payload, &accounts)).To(Succeed())
+			Expect(accounts).To(Equal([]entities.AgentAccount{{
+				AgentId: agent.OrganizationId, BankOrgId: bank2.OrganizationId, Account: `acc2`, Confirmed: true}}))
+			Expect(json.Unmarshal(tickets.From(bank).Invoke("/agent/account/list").Payload, &accounts)).To(Succeed())
+			Expect(accounts).To(BeEmpty())
+		})
+
+		It("Allow bank to change only own leg", func() {
+			split := createPayload(`split`, 1000)
+			split.Legs = []entities.PaymentLegPayload{
+				{BankOrgId: bank.OrganizationId, Account: agent.Requisites.SettlementAccount, Amount: 600},
+				{BankOrgId: bank2.OrganizationId, Account: `acc2`, Amount: 300},
+			}
+			ExpectResponseError(tickets.From(agent).Invoke("/create", split), `payment legs amount 900 mismatch payment amount 1000`)
+
+			split.Legs[1].Amount = 400
+			ExpectResponseOk(tickets.From(agent).Invoke("/create", split))
+
+			ExpectResponseError(tickets.From(bank).Invoke("/updateState", apiEntities.RequestUpdateState{
+				PaymentId: split.Id, State: entities.CheckFundsInProgress, LegId: `2`}), `bank can't process leg of another bank`)
+
+			ExpectResponseOk(tickets.From(bank2).Invoke("/updateState", ticketFixture.UpdateState(split.Id, entities.CheckFundsInProgress)))
+			ExpectPaymentState(tickets, split.Id, entities.CheckFundsRequest)
+
+			ExpectResponseOk(tickets.From(bank).Invoke("/updateState", ticketFixture.UpdateState(split.Id, entities.CheckFundsInProgress)))
+			ExpectPaymentState(tickets, split.Id, entities.CheckFundsInProgress)
+		})
+
+		It("Finish legs after another leg failed and refund debited legs of canceled payment", func() {
+			split := createPayload(`split-cancel`, 1000)
+			split.Legs = []entities.PaymentLegPayload{
+				{BankOrgId: bank.OrganizationId, Account: agent.Requisites.SettlementAccount, Amount: 600},
+				{BankOrgId: bank2.OrganizationId, Account: `acc2`, Amount: 400},
+			}
+			ExpectResponseOk(tickets.From(agent).Invoke("/create", split))
+
+			for _, state := range []entities.PaymentState{entities.CheckFundsInProgress, entities.CheckFundsSuccess} {
+				ExpectResponseOk(tickets.From(bank).Invoke("/updateState", ticketFixture.UpdateState(split.Id, state)))
+				ExpectResponseOk(tickets.From(bank2).Invoke("/updateState", ticketFixture.UpdateState(split.Id, state)))
+			}
+			ExpectResponseOk(tickets.From(agent).Invoke("/updateState", ticketFixture.UpdateState(split.Id, entities.DebitRequest)))
+			ExpectResponseOk(tickets.From(bank).Invoke("/updateState", ticketFixture.UpdateState(split.Id, entities.DebitInProgress)))
+			ExpectResponseOk(tickets.From(bank2).Invoke("/updateState", ticketFixture.UpdateState(split.Id, entities.DebitInProgress)))
+
+			ExpectResponseOk(tickets.From(bank2).Invoke("/updateState", ticketFixture.UpdateState(split.Id, entities.DebitFail)))
+			ExpectPaymentState(tickets, split.Id, entities.DebitFail)
+
+			cancel := ticketFixture.UpdateState(split.Id, entities.TicketCanceled)
+			ExpectResponseError(tickets.From(agent).Invoke("/updateState", cancel), `payment leg 1 is debited by bank `+bank.OrganizationId)
+
+			ExpectResponseOk(tickets.From(bank).Invoke("/updateState", ticketFixture.UpdateState(split.Id, entities.DebitSuccess)))
+			ExpectPaymentState(tickets, split.Id, entities.DebitFail)
+
+			ExpectResponseOk(tickets.From(agent).Invoke("/updateState", cancel))
+			canceled, _ := ticketFixture.FromBytes(tickets.MockInvokeFunc("/get", split.Id).Payload)
+			Expect(canceled.State).To(Equal(entities.TicketCanceled))
+			Expect(canceled.Legs[0].State).To(Equal(entities.Refunded))
+			Expect(canceled.Legs[1].State).To(Equal(entities.TicketCanceled))
+		})
+	})
+
+	Describe("Holds", func() {
+		It("Capture part of hold and recalculate fees", func() {
+			p := &entities.Payment{Amount: 1000, Hold: &entities.PaymentHold{Amount: 1000, ExpiresAt: `2030-01-01T00:00:00Z`}}
+			Expect(holdExpired(p.Hold, time.Date(2029, 1, 1, 0, 0, 0, 0, time.UTC))).To(BeFalse())
+			Expect(holdExpired(p.Hold, time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))).To(BeTrue())
+
+			schedule := entities.FeeSchedule{Rules: []entities.FeeRule{{Id: `default`, CommissionBps: 100}}}
+			Expect(capturePayment(p, 2000, schedule)).NotTo(Succeed())
+			Expect(capturePayment(p, 600, schedule)).To(Succeed())
+			Expect(p.State).To(Equal(entities.DebitRequest))
+			Expect(p.Amount).To(Equal(uint(600)))
+			Expect(p.AgentCommission).To(Equal(uint(6)))
+		})
+
+		It("Allow payer bank to hold funds and agent to capture them", func() {
+			held := createPayload(`held`, 1000)
+			ExpectResponseOk(tickets.From(agent).Invoke("/create", held))
+			ExpectResponseOk(tickets.From(bank).Invoke("/updateState", ticketFixture.UpdateState(held.Id, entities.CheckFundsInProgress)))
+
+			ExpectResponseError(tickets.From(bank).Invoke("/updateState", ticketFixture.UpdateState(held.Id, entities.FundsHeld)),
+				`is changed only with /hold routes`)
+
+			hold := entities.HoldConfirmPayload{PaymentId: held.Id, ExpiresAt: time.Now().Add(time.Hour).Format(time.RFC3339)}
+			ExpectResponseError(tickets.From(bank2).Invoke("/hold/confirm", hold), `bank can't process payment of another bank`)
+			ExpectResponseOk(tickets.From(bank).Invoke("/hold/confirm", hold))
+			ExpectPaymentState(tickets, held.Id, entities.FundsHeld)
+
+			ExpectResponseError(tickets.From(bank).Invoke("/hold/release", held.Id), `bank can release only expired hold`)
+			ExpectResponseError(tickets.From(agent).Invoke("/hold/capture", entities.HoldCapturePayload{PaymentId: held.Id, Amount: 1001}),
+				`capture amount 1001 exceeds hold amount 1000`)
+			ExpectResponseOk(tickets.From(agent).Invoke("/hold/capture", entities.HoldCapturePayload{PaymentId: held.Id, Amount: 700}))
+			ExpectPaymentState(tickets, held.Id, entities.DebitRequest)
+
+			captured, _ := ticketFixture.FromBytes(tickets.MockInvokeFunc("/get", held.Id).Payload)
+			Expect(captured.Amount).To(Equal(uint(700)))
+			Expect(captured.Hold.Captured).To(Equal(uint(700)))
+
+			ExpectResponseError(tickets.From(agent).Invoke("/hold/release", held.Id), `can't release payment in state`)
+		})
+	})
+
+	Describe("Disputes", func() {
+		evidence := []entities.DisputeEvidence{{
+			Hash:        `9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08`,
+			Description: `boarding pass`,
+		}}
+
+		It("Check dispute resolution deadlines", func() {
+			now := time.Date(2030, 1, 10, 0, 0, 0, 0, time.UTC)
+			opened := &entities.Dispute{State: entities.DisputeOpened, RespondBy: `2030-01-15T00:00:00Z`}
+			Expect(canResolveDispute(opened, RoleBank, entities.DisputeAccepted, now)).NotTo(Succeed())
+			Expect(canResolveDispute(opened, RoleBank, entities.DisputeAccepted, now.Add(6*24*time.Hour))).To(Succeed())
+			Expect(canResolveDispute(opened, RoleBank, entities.DisputeRejected, now)).To(Succeed())
+			Expect(canResolveDispute(opened, RoleMerchant, entities.DisputeAccepted, now)).To(Succeed())
+			Expect(canResolveDispute(opened, RoleMerchant, entities.DisputeRejected, now)).NotTo(Succeed())
+			Expect(canResolveDispute(opened, RoleAgent, entities.DisputeRejected, now)).NotTo(Succeed())
+
+			responded := &entities.Dispute{State: entities.DisputeResponded, ResolveBy: `2030-01-15T00:00:00Z`}
+			Expect(canResolveDispute(responded, RoleBank, entities.DisputeRejected, now)).To(Succeed())
+			Expect(canResolveDispute(responded, RoleMerchant, entities.DisputeRejected, now)).NotTo(Succeed())
+			Expect(canResolveDispute(responded, RoleBank, entities.DisputeAccepted, now.Add(6*24*time.Hour))).NotTo(Succeed())
+			Expect(canResolveDispute(responded, RoleMerchant, entities.DisputeRejected, now.Add(6*24*time.Hour))).To(Succeed())
+			Expect(canResolveDispute(responded, RoleBank, entities.DisputeOpened, now)).NotTo(Succeed())
+		})
+
+		It("Allow payer bank to open dispute and refund payment on acceptance", func() {
+			ExpectResponseOk(tickets.From(bank).Invoke("/updateState", ticketFixture.UpdateState(`held`, entities.DebitInProgress)))
+			ExpectResponseOk(tickets.From(bank).Invoke("/updateState", ticketFixture.UpdateState(`held`, entities.DebitSuccess)))
+
+			open := entities.DisputeOpenPayload{PaymentId: `held`, Reason: `flight not taken`}
+			ExpectResponseError(tickets.From(agent).Invoke("/dispute/open", open), `only payer bank can open dispute`)
+			ExpectResponseError(tickets.From(bank2).Invoke("/dispute/open", open), `only payer bank can open dispute`)
+
+			response := tickets.From(bank).Invoke("/dispute/open", open)
+			ExpectResponseOk(response)
+			var dispute entities.Dispute
+			Expect(json.Unmarshal(response.Payload, &dispute)).To(Succeed())
+			Expect(dispute.State).To(Equal(entities.DisputeOpened))
+			Expect(dispute.Amount).To(Equal(uint(700)))
+
+			ExpectResponseError(tickets.From(bank).Invoke("/dispute/open", open), `payment already disputed`)
+
+			respond := entities.DisputeRespondPayload{DisputeId: dispute.Id, Evidence: []entities.DisputeEvidence{{Hash: `boarding pass`}}}
+			ExpectResponseError(tickets.From(merchant).Invoke("/dispute/respond", respond), `evidence hash must be hex encoded sha256`)
+			respond.Evidence = evidence
+			ExpectResponseError(tickets.From(bank).Invoke("/dispute/respond", respond), `only merchant can respond to dispute`)
+			ExpectResponseOk(tickets.From(merchant).Invoke("/dispute/respond", respond))
+
+			disputed, _ := ticketFixture.FromBytes(tickets.MockInvokeFunc("/get", `held`).Payload)
+			Expect(disputed.DisputeId).To(Equal(dispute.Id))
+			Expect(disputed.DisputeState).To(Equal(entities.DisputeResponded))
+			Expect(disputed.UpdatedBy).To(Equal(merchant.OrganizationId))
+			Expect(disputed.UpdatedByRole).To(Equal(RoleMerchant))
+
+			resolve := entities.DisputeResolvePayload{DisputeId: dispute.Id, State: entities.DisputeAccepted}
+			ExpectResponseError(tickets.From(bank2).Invoke("/dispute/resolve", resolve), `only bank which opened dispute can resolve it`)
+			ExpectResponseOk(tickets.From(bank).Invoke("/dispute/resolve", resolve))
+			ExpectResponseError(tickets.From(merchant).Invoke("/dispute/resolve", resolve), `dispute is already resolved: ACCEPTED`)
+
+			refunded, _ := ticketFixture.FromBytes(tickets.MockInvokeFunc("/get", `held`).Payload)
+			Expect(refunded.State).To(Equal(entities.Refunded))
+			Expect(refunded.CancelReason).To(Equal(entities.CancelReasonChargeback))
+			Expect(refunded.DisputeState).To(Equal(entities.DisputeAccepted))
+		})
+
+		It("List disputes visible to invoker", func() {
+			var disputes []entities.Dispute
+			Expect(json.Unmarshal(tickets.From(merchant).Invoke("/dispute/list").Payload, &disputes)).To(Succeed())
+			Expect(disputes).To(HaveLen(1))
+			Expect(disputes[0].Evidence).To(Equal(evidence))
+			disputeId := disputes[0].Id
+
+			Expect(json.Unmarshal(tickets.From(agent).Invoke("/dispute/list", `held`).Payload, &disputes)).To(Succeed())
+			Expect(disputes).To(HaveLen(1))
+
+			Expect(json.Unmarshal(tickets.From(bank2).Invoke("/dispute/list").Payload, &disputes)).To(Succeed())
+			Expect(disputes).To(BeEmpty())
+
+			ExpectResponseError(tickets.From(someOrg).Invoke("/dispute/list"), `disputes are available only for merchant, bank and agent`)
+			ExpectResponseError(tickets.From(someOrg).Invoke("/dispute/get", disputeId),
+				`disputes are available only for merchant, bank and agent`)
+		})
+
+		It("Dispute and refund only legs of opening bank of split payment", func() {
+			split := createPayload(`split-dispute`, 1000)
+			split.Legs = []entities.PaymentLegPayload{
+				{BankOrgId: bank.OrganizationId, Account: agent.Requisites.SettlementAccount, Amount: 600},
+				{BankOrgId: bank2.OrganizationId, Account: `acc2`, Amount: 400},
+			}
+			ExpectResponseOk(tickets.From(agent).Invoke("/create", split))
+			for _, state := range []entities.PaymentState{entities.CheckFundsInProgress, entities.CheckFundsSuccess} {
+				ExpectResponseOk(tickets.From(bank).Invoke("/updateState", ticketFixture.UpdateState(split.Id, state)))
+				ExpectResponseOk(tickets.From(bank2).Invoke("/updateState", ticketFixture.UpdateState(split.Id, state)))
+			}
+			ExpectResponseOk(tickets.From(agent).Invoke("/updateState", ticketFixture.UpdateState(split.Id, entities.DebitRequest)))
+			for _, state := range []entities.PaymentState{entities.DebitInProgress, entities.DebitSuccess} {
+				ExpectResponseOk(tickets.From(bank).Invoke("/updateState", ticketFixture.UpdateState(split.Id, state)))
+				ExpectResponseOk(tickets.From(bank2).Invoke("/updateState", ticketFixture.UpdateState(split.Id, state)))
+			}
+
+			response := tickets.From(bank2).Invoke("/dispute/open", entities.DisputeOpenPayload{PaymentId: split.Id, Reason: `card stolen`})
+			ExpectResponseOk(response)
+			var dispute entities.Dispute
+			Expect(json.Unmarshal(response.Payload, &dispute)).To(Succeed())
+			Expect(dispute.Amount).To(Equal(uint(400)))
+
+			ExpectResponseOk(tickets.From(merchant).Invoke("/dispute/resolve",
+				entities.DisputeResolvePayload{DisputeId: dispute.Id, State: entities.DisputeAccepted}))
+
+			refunded, _ := ticketFixture.FromBytes(tickets.MockInvokeFunc("/get", split.Id).Payload)
+			Expect(refunded.State).To(Equal(entities.DebitSuccess))
+			Expect(refunded.CancelReason).To(BeEmpty())
+			Expect(refunded.Legs[0].State).To(Equal(entities.DebitSuccess))
+			Expect(refunded.Legs[1].State).To(Equal(entities.Refunded))
+
+			settled := &entities.Payment{Id: split.Id, Amount: 1000, Currency: `RUB`, State: entities.DebitSuccess,
+				RecipientBankOrgId: bank.OrganizationId, Settled: true, SettlementBatchId: `batch1`, Legs: []entities.PaymentLeg{
+					{BankOrgId: bank.OrganizationId, Amount: 600, State: entities.DebitSuccess},
+					{BankOrgId: bank2.OrganizationId, Amount: 400, State: entities.Refunded},
+				}}
+			batch, changed := buildSettlementBatch(`batch2`, `RUB`, []*entities.Payment{settled})
+			Expect(changed).To(HaveLen(1))
+			Expect(batch.RefundIds).To(Equal([]string{split.Id}))
+			Expect(batch.Pairs).To(Equal([]entities.SettlementPair{{PayerBankOrgId: bank2.OrganizationId,
+				RecipientBankOrgId: bank.OrganizationId, Refunded: 400, Net: -400, PaymentIds: []string{split.Id}}}))
+			Expect(settled.Settled).To(BeTrue())
+		})
+	})
+
+	Describe("Meta", func() {
+		It("Validate meta values against schema", func() {
+			pnr, _ := getMetaField(entities.MetaPNR)
+			Expect(validateMetaValue(pnr, `ABC123`)).To(Succeed())
+			Expect(validateMetaValue(pnr, `abc123`)).NotTo(Succeed())
+			Expect(validateMetaValue(pnr, `ABC123456`)).NotTo(Succeed())
+			Expect(validateMetaValue(pnr, `ABC12`)).NotTo(Succeed())
+			Expect(validateMetaValue(pnr, ``)).To(Succeed())
+
+			_, err := getMetaField(`seat`)
+			Expect(err).To(HaveOccurred())
+
+			Expect(metaSize(map[string][]byte{`pnr`: []byte(`ABC123`), `route`: []byte(`LED-MOW`)}, `pnr`, []byte(`XYZ`))).To(Equal(10))
+		})
+
+		It("Allow roles to set meta keys according to schema", func() {
+			agentMeta := entities.MetaPutPayload{PaymentId: `held`, Values: map[string]string{
+				entities.MetaPNR:           `ABC123`,
+				entities.MetaPassengerName: `IVANOV/IVAN`,
+				entities.MetaRoute:         `LED-MOW`,
+			}}
+			ExpectResponseError(tickets.From(agent2).Invoke("/meta/put", agentMeta), `agent can't set meta of payment of another agent`)
+			ExpectResponseError(tickets.From(merchant).Invoke("/meta/put", agentMeta), `role MERCHANT can't set meta key: passengerName`)
+			ExpectResponseOk(tickets.From(agent).Invoke("/meta/put", agentMeta))
+
+			flight := entities.MetaPutPayload{PaymentId: `held`, Values: map[string]string{entities.MetaFlight: `S71234`}}
+			ExpectResponseError(tickets.From(bank).Invoke("/meta/put", flight), `role BANK can't set meta key: flight`)
+			ExpectResponseOk(tickets.From(merchant).Invoke("/meta/put", flight))
+
+			ExpectResponseError(tickets.From(agent).Invoke("/meta/put", entities.MetaPutPayload{PaymentId: `held`,
+				Values: map[string]string{entities.MetaPNR: `abc`}}), `meta pnr doesn't match pattern`)
+			ExpectResponseError(tickets.From(agent).Invoke("/meta/set", `held`, `seat`, `12A`), `meta key is not allowed: seat`)
+
+			var meta entities.PaymentMeta
+			Expect(json.Unmarshal(tickets.From(bank).Invoke("/meta/fields", `held`).Payload, &meta)).To(Succeed())
+			Expect(meta).To(Equal(entities.PaymentMeta{PNR: `ABC123`, PassengerName: `IVANOV/IVAN`, Flight: `S71234`, Route: `LED-MOW`}))
+
+			ExpectResponseError(tickets.From(agent2).Invoke("/meta/fields", `held`), `payment meta of held is available only for`)
+			ExpectResponseError(tickets.From(bank2).Invoke("/meta/fields", `held`), `payment meta of held is available only for`)
+			ExpectResponseError(tickets.From(someOrg).Invoke("/meta/fields", `held`), `payment meta is available only for merchant, agent and bank`)
+		})
+
+		It("Set single meta value on payment stored with null meta", func() {
+			tickets.MockTransactionStart(`nullMeta`)
+			tickets.PutState(`PAYMENT_null_meta`, []byte(fmt.Sprintf(
+				`{"schemaVersion":%d,"paymentId":"null_meta","state":"TicketCanceled","amount":500,"currency":"RUB","meta":null,"payerOrgId":"%s"}`,
+				entities.PaymentSchemaVersion, agent.OrganizationId)))
+			tickets.MockTransactionEnd(`nullMeta`)
+
+			ExpectResponseError(tickets.From(someOrg).Invoke("/meta/set", `null_meta`, entities.MetaPNR, `ABC123`),
+				`meta can be set only by merchant, bank and agent`)
+			ExpectResponseError(tickets.From(agent).Invoke("/meta/set", `null_meta`, entities.MetaPNR), `arguments count mismatch`)
+			ExpectResponseOk(tickets.From(agent).Invoke("/meta/set", `null_meta`, entities.MetaPNR, `ABC123`))
+
+			stored, _ := ticketFixture.FromBytes(tickets.MockInvokeFunc("/get", `null_meta`).Payload)
+			Expect(stored.Meta).To(Equal(map[string][]byte{entities.MetaPNR: []byte(`ABC123`)}))
+			Expect(stored.UpdatedBy).To(Equal(agent.OrganizationId))
+
+			// empty value removes key as in /meta/put
+			ExpectResponseOk(tickets.From(agent).Invoke("/meta/set", `null_meta`, entities.MetaPNR, ``))
+			stored, _ = ticketFixture.FromBytes(tickets.MockInvokeFunc("/get", `null_meta`).Payload)
+			Expect(stored.Meta).To(BeEmpty())
+		})
+
+		It("Refuse meta extension calls with two arguments", func() {
+			t := NewTicket(l)
+			stub := argsStub{args: []string{`/meta/set`, `held`, entities.MetaPNR}}
+
+			_, err := t.GetMetaData(stub)
+			Expect(err).To(Equal(meta.ErrMetaKeyNotPresented))
+			_, err = t.GetStateDataWithMeta(stub)
+			Expect(err).To(Equal(meta.ErrMetaKeyNotPresented))
+		})
+	})
+
+	Describe("Itinerary", func() {
+		itinerary := entities.Itinerary{
+			PNR:        `X7K2LM`,
+			Passengers: []entities.Passenger{{LastName: `IVANOV`, FirstName: `IVAN`, Type: entities.PassengerAdult}},
+			Segments: []entities.FlightSegment{
+				{Carrier: `S7`, FlightNumber: `1001`, Origin: `DME`, OriginCountry: `RU`, Destination: `LED`, DestinationCountry: `RU`,
+					DepartureDate: `2030-05-01`},
+				{Carrier: `S7`, FlightNumber: `4421`, Origin: `LED`, OriginCountry: `RU`, Destination: `HEL`, DestinationCountry: `FI`,
+					DepartureDate: `2030-05-02`},
+			},
+			Fare: entities.Fare{BaseFare: 800, Taxes: []entities.FareTax{{Code: `YQ`, Amount: 150}, {Code: `RI`, Amount: 50}}},
+		}
+
+		It("Validate itinerary codes and fare", func() {
+			Expect(validateItinerary(itinerary, 1000)).To(Succeed())
+			Expect(validateItinerary(itinerary, 900)).NotTo(Succeed())
+			Expect(itinerary.International()).To(BeTrue())
+
+			domestic := itinerary
+			domestic.Segments = itinerary.Segments[:1]
+			Expect(domestic.International()).To(BeFalse())
+
+			invalid := itinerary
+			invalid.Segments = []entities.FlightSegment{itinerary.Segments[1], itinerary.Segments[0]}
+			Expect(validateItinerary(invalid, 1000)).To(MatchError(`segment 1: departs before previous segment`))
+
+			invalid.Segments = []entities.FlightSegment{itinerary.Segments[0]}
+			invalid.Segments[0].Origin = `MOSCOW`
+			Expect(validateItinerary(invalid, 1000)).To(MatchError(`segment 0: invalid airport code: MOSCOW-LED`))
+
+			invalid = itinerary
+			invalid.PNR = `x7k2lm`
+			Expect(validateItinerary(invalid, 1000)).To(MatchError(`invalid pnr: x7k2lm`))
+		})
+
+		It("Derive international flag from itinerary and search payments by PNR", func() {
+			unbooked := createPayload(`unbooked`, 1000)
+			unbooked.InternationalFlight = true
+			ExpectResponseOk(tickets.From(agent).Invoke("/create", unbooked))
+
+			created, _ := ticketFixture.FromBytes(tickets.MockInvokeFunc("/get", unbooked.Id).Payload)
+			Expect(created.InternationalFlight).To(BeFalse())
+
+			booked := createPayload(`booked`, 1000)
+			booked.Itinerary = &itinerary
+			ExpectResponseOk(tickets.From(agent).Invoke("/create", booked))
+
+			created, _ = ticketFixture.FromBytes(tickets.MockInvokeFunc("/get", booked.Id).Payload)
+			Expect(created.InternationalFlight).To(BeTrue())
+			Expect(created.Itinerary.PNR).To(Equal(itinerary.PNR))
+
+			var found []entities.Payment
+			Expect(json.Unmarshal(tickets.From(agent).Invoke("/itinerary/search", itinerary.PNR).Payload, &found)).To(Succeed())
+			Expect(found).To(HaveLen(1))
+			Expect(found[0].Id).To(Equal(booked.Id))
+
+			Expect(json.Unmarshal(tickets.From(agent2).Invoke("/itinerary/search", itinerary.PNR).Payload, &found)).To(Succeed())
+			Expect(found).To(BeEmpty())
+
+			ExpectResponseError(tickets.From(someOrg).Invoke("/itinerary/search", itinerary.PNR),
+				`itinerary search is available only for merchant, bank and agent`)
+		})
+	})
+
+	Describe("Sales reports", func() {
+		It("Aggregate issues, exchanges and refunds of agent period", func() {
+			start := time.Date(2030, 5, 1, 0, 0, 0, 0, time.UTC)
+			end := time.Date(2030, 6, 1, 0, 0, 0, 0, time.UTC)
+			withTaxes := &entities.Itinerary{PNR: `X7K2LM`, Fare: entities.Fare{BaseFare: 800, Taxes: []entities.FareTax{{Code: `YQ`, Amount: 200}}}}
+
+			payments := []*entities.Payment{
+				{Id: `issued`, PayerOrgId: `a`, Currency: `RUB`, Amount: 1000, State: entities.DebitSuccess,
+					IssuedAt: `2030-05-10T10:00:00Z`, Itinerary: withTaxes, PaymentFees: entities.PaymentFees{AgentCommission: 10}},
+				{Id: `refunded`, PayerOrgId: `a`, Currency: `RUB`, Amount: 500, State: entities.Refunded,
+					IssuedAt: `2030-04-20T10:00:00Z`, CanceledAt: `2030-05-03T10:00:00Z`, PaymentFees: entities.PaymentFees{AgentCommission: 5}},
+				{Id: `exchanged`, PayerOrgId: `a`, Currency: `RUB`, Amount: 300, State: entities.DebitSuccess,
+					PaymentType: entities.PaymentTypeExchange, IssuedAt: `2030-05-15T10:00:00Z`},
+				{Id: `other agent`, PayerOrgId: `b`, Currency: `RUB`, Amount: 100, IssuedAt: `2030-05-10T10:00:00Z`},
+				{Id: `reported`, PayerOrgId: `a`, Currency: `RUB`, Amount: 100, IssuedAt: `2030-05-11T10:00:00Z`, SalesReportId: `a_2030-04-01`},
+			}
+
+			report := buildSalesReport(`a`, start, end, payments)
+			Expect(report.Id).To(Equal(`a_2030-05-01`))
+			Expect(report.Lines).To(HaveLen(3))
+			Expect(report.Lines[0].TransactionCode).To(Equal(entities.TransactionRefund))
+			Expect(report.Lines[1].Taxes).To(Equal(uint(200)))
+			Expect(report.Lines[1].Fare).To(Equal(uint(800)))
+			Expect(report.Lines[2].TransactionCode).To(Equal(entities.TransactionExchange))
+			Expect(report.Totals).To(Equal([]entities.SalesReportTotal{{Currency: `RUB`, Issues: 1, Exchanges: 1, Refunds: 1,
+				GrossAmount: 1300, RefundedAmount: 500, NetTaxes: 200, NetCommission: 5, NetRemittance: 795}}))
+			Expect(report.Hash).To(Equal(buildSalesReport(`a`, start, end, payments).Hash))
+
+			Expect(periodLocked(`2030-06-01T00:00:00Z`, time.Date(2030, 5, 20, 0, 0, 0, 0, time.UTC))).To(BeTrue())
+			Expect(periodLocked(`2030-06-01T00:00:00Z`, time.Date(2030, 6, 2, 0, 0, 0, 0, time.UTC))).To(BeFalse())
+			Expect(periodLocked(``, time.Now())).To(BeFalse())
+		})
+
+		It("Close agent period when merchant and agent co-signed report", func() {
+			period := entities.SalesReportPayload{AgentId: agent.OrganizationId, PeriodStart: `2000-01-01`, PeriodEnd: `2000-02-01`}
+			ExpectResponseError(tickets.From(agent).Invoke("/report/create", period), `only merchant can create sales report`)
+
+			response := tickets.From(merchant).Invoke("/report/create", period)
+			ExpectResponseOk(response)
+			var report entities.SalesReport
+			Expect(json.Unmarshal(response.Payload, &report)).To(Succeed())
+			Expect(report.State).To(Equal(entities.SalesReportDraft))
+			Expect(report.Lines).To(BeEmpty())
+
+			ExpectResponseError(tickets.From(adminOf(agent2, RoleAgent)).Invoke("/report/sign", report.Id, report.Hash), `only merchant and reported agent can sign`)
+			ExpectResponseError(tickets.From(adminOf(agent, RoleAgent)).Invoke("/report/sign", report.Id, `hash`), `signed hash doesn't match`)
+			ExpectResponseOk(tickets.From(adminOf(merchant, RoleMerchant)).Invoke("/report/sign", report.Id, report.Hash))
+			ExpectResponseError(tickets.From(adminOf(merchant, RoleMerchant)).Invoke("/report/sign", report.Id, report.Hash), `sales report already signed`)
+			ExpectResponseOk(tickets.From(adminOf(agent, RoleAgent)).Invoke("/report/sign", report.Id, report.Hash))
+
+			Expect(json.Unmarshal(tickets.From(agent).Invoke("/report/get", report.Id).Payload, &report)).To(Succeed())
+			Expect(report.State).To(Equal(entities.SalesReportClosed))
+			Expect(report.Signatures).To(HaveLen(2))
+			ExpectResponseError(tickets.From(agent2).Invoke("/report/get", report.Id), `sales report not found`)
+
+			ExpectResponseError(tickets.From(merchant).Invoke("/report/create", period), `sales period is closed`)
+			ExpectResponseError(tickets.From(merchant).Invoke("/report/create", entities.SalesReportPayload{
+				AgentId: agent.OrganizationId, PeriodStart: `2000-01-15`, PeriodEnd: `2000-03-01`}), `sales period overlaps report`)
+		})
+
+		It("Reject agent changes of payment reported in closed period", func() {
+			tickets.MockTransactionStart(`reported`)
+			tickets.PutState(`PAYMENT_reported`, []byte(fmt.Sprintf(
+				`{"schemaVersion":%d,"paymentId":"reported","state":"DebitSuccess","amount":700,"currency":"RUB","meta":{},"payerOrgId":"%s","issuedAt":"2001-01-10T10:00:00Z"}`,
+				entities.PaymentSchemaVersion, agent.OrganizationId)))
+			tickets.MockTransactionEnd(`reported`)
+
+			pnr := entities.MetaPutPayload{PaymentId: `reported`, Values: map[string]string{entities.MetaPNR: `ABC123`}}
+			ExpectResponseOk(tickets.From(agent).Invoke("/meta/put", pnr))
+
+			period := entities.SalesReportPayload{AgentId: agent.OrganizationId, PeriodStart: `2001-01-01`, PeriodEnd: `2001-02-01`}
+			response := tickets.From(merchant).Invoke("/report/create", period)
+			ExpectResponseOk(response)
+			var report entities.SalesReport
+			Expect(json.Unmarshal(response.Payload, &report)).To(Succeed())
+			Expect(report.Lines).To(HaveLen(1))
+
+			ExpectResponseOk(tickets.From(adminOf(merchant, RoleMerchant)).Invoke("/report/sign", report.Id, report.Hash))
+			// period is not closed until both signatures
+			ExpectResponseOk(tickets.From(agent).Invoke("/meta/put", pnr))
+			ExpectResponseOk(tickets.From(adminOf(agent, RoleAgent)).Invoke("/report/sign", report.Id, report.Hash))
+
+			reported, _ := ticketFixture.FromBytes(tickets.MockInvokeFunc("/get", `reported`).Payload)
+			Expect(reported.SalesReportId).To(Equal(report.Id))
+			ExpectResponseError(tickets.From(agent).Invoke("/meta/put", pnr), `payment reported is included in closed sales report`)
+
+			ExpectResponseError(tickets.From(someOrg).Invoke("/report/list"), `sales reports are available only for merchant and agent`)
+			ExpectResponseError(tickets.From(someOrg).Invoke("/report/get", report.Id), `sales reports are available only for merchant and agent`)
+		})
+
+		It("Disallow to sign report before period end", func() {
+			today := time.Now().UTC()
+			period := entities.SalesReportPayload{AgentId: agent2.OrganizationId,
+				PeriodStart: today.Format(`2006-01-02`), PeriodEnd: today.AddDate(0, 0, 2).Format(`2006-01-02`)}
+
+			response := tickets.From(merchant).Invoke("/report/create", period)
+			ExpectResponseOk(response)
+			var report entities.SalesReport
+			Expect(json.Unmarshal(response.Payload, &report)).To(Succeed())
+			ExpectResponseError(tickets.From(adminOf(agent2, RoleAgent)).Invoke("/report/sign", report.Id, report.Hash), `can be signed only after period end`)
+		})
+	})
+
+	Describe("Points of sale", func() {
+		It("Create payments from point of sale certificate within its permissions and limits", func() {
+			office := orgUser{agent, map[string]string{entities.AttrSubAgent: `office1`}}
+			otherOffice := orgUser{agent, map[string]string{entities.AttrSubAgent: `office2`}}
+
+			subAgent := entities.SubAgent{Id: `office1`, AgentId: agent.OrganizationId, Name: `Airport office`,
+				Permissions: []string{entities.SubAgentCreate}, MaxAmount: 500}
+			ExpectResponseError(tickets.From(adminOf(agent, RoleAgent)).Invoke("/subagent/set", subAgent), `only merchant can set point of sale`)
+			ExpectResponseError(tickets.From(adminOf(merchant, RoleMerchant)).Invoke("/subagent/set", entities.SubAgent{Id: `office2`,
+				AgentId: agent.OrganizationId, Permissions: []string{`REFUND`}}), `unknown point of sale permission: REFUND`)
+			ExpectResponseOk(tickets.From(adminOf(merchant, RoleMerchant)).Invoke("/subagent/set", subAgent))
+			ExpectResponseOk(tickets.From(adminOf(merchant, RoleMerchant)).Invoke("/subagent/set", entities.SubAgent{Id: `office2`,
+				AgentId: agent.OrganizationId, Permissions: []string{entities.SubAgentCreate, entities.SubAgentCancel}}))
+
+			pos, _ := ticketFixture.GetFixture("payment_1_SALE_from_Org4MSP.json")
+			pos.Id = `pos`
+			pos.Amount = 600
+			ExpectResponseError(tickets.From(office).Invoke("/create", pos), `exceeds max amount 500 of point of sale office1`)
+			ExpectResponseError(tickets.From(orgUser{agent, map[string]string{entities.AttrSubAgent: `office9`}}).Invoke("/create", pos),
+				`point of sale office9`)
+
+			pos.Amount = 400
+			ExpectResponseOk(tickets.From(office).Invoke("/create", pos))
+			created, _ := ticketFixture.FromBytes(tickets.MockInvokeFunc("/get", pos.Id).Payload)
+			Expect(created.SubAgentId).To(Equal(`office1`))
+
+			cancel := entities.CancelPayload{PaymentId: pos.Id}
+			ExpectResponseError(tickets.From(office).Invoke("/cancel", cancel), `point of sale office1 has no permission: CANCEL`)
+			ExpectResponseError(tickets.From(otherOffice).Invoke("/cancel", cancel),
+				`point of sale office2 can't process payment of another point of sale`)
+		})
+
+		It("Allow agent to list own points of sale and their payments", func() {
+			var subAgents []entities.SubAgent
+			Expect(json.Unmarshal(tickets.From(agent).Invoke("/subagent/list", ``).Payload, &subAgents)).To(Succeed())
+			Expect(subAgents).To(HaveLen(2))
+			ExpectResponseError(tickets.From(agent2).Invoke("/subagent/list", agent.OrganizationId),
+				`agent can't view points of sale of another agent`)
+			ExpectResponseError(tickets.From(someOrg).Invoke("/subagent/list", agent.OrganizationId),
+				`points of sale are available only for merchant and agent`)
+
+			var payments []entities.Payment
+			ExpectResponseError(tickets.From(orgUser{agent, map[string]string{entities.AttrRole: `agent-viewer`}}).Invoke("/subagent/payments", ``),
+				`certificate access viewer is not enough for payments of points of sale, required: admin`)
+			Expect(json.Unmarshal(tickets.From(adminOf(agent, RoleAgent)).Invoke("/subagent/payments", ``).Payload, &payments)).To(Succeed())
+			Expect(payments).To(HaveLen(1))
+			Expect(payments[0].Id).To(Equal(`pos`))
+
+			office := orgUser{agent, map[string]string{entities.AttrSubAgent: `office2`}}
+			ExpectResponseError(tickets.From(office).Invoke("/subagent/payments", `office1`),
+				`point of sale office2 can't view payments of another point of sale`)
+			Expect(json.Unmarshal(tickets.From(office).Invoke("/subagent/payments", ``).Payload, &payments)).To(Succeed())
+			Expect(payments).To(BeEmpty())
+		})
+	})
+
+	Describe("Certificate permissions", func() {
+		It("Check access level and max amount from certificate attributes", func() {
+			abac, _ := ticketFixture.GetFixture("payment_2_SALE_from_Org6MSP.json")
+			abac.Id = `abac`
+			abac.Amount = 400
+
+			ExpectResponseError(tickets.From(orgUser{agent2, map[string]string{entities.AttrRole: `merchant-admin`}}).Invoke("/create", abac),
+				`certificate role merchant-admin doesn't match organization role AGENT`)
+			ExpectResponseError(tickets.From(orgUser{agent2, map[string]string{entities.AttrRole: `agent-viewer`}}).Invoke("/create", abac),
+				`certificate access viewer is not enough for /create, required: operator`)
+
+			limited := map[string]string{entities.AttrRole: `agent-operator`, entities.AttrMaxAmount: `100`}
+			ExpectResponseError(tickets.From(orgUser{agent2, limited}).Invoke("/create", abac),
+				`amount 400 exceeds certificate max amount 100`)
+			limited[entities.AttrApprove] = `true`
+			ExpectResponseOk(tickets.From(orgUser{agent2, limited}).Invoke("/create", abac))
+
+			checkFunds := ticketFixture.UpdateState(abac.Id, entities.CheckFundsInProgress)
+			ExpectResponseError(tickets.From(orgUser{bank2, map[string]string{
+				entities.AttrRole: `bank-operator`, entities.AttrMaxAmount: `100`}}).Invoke("/updateState", checkFunds),
+				`amount 400 exceeds certificate max amount 100`)
+			ExpectResponseOk(tickets.From(orgUser{bank2, map[string]string{entities.AttrRole: `bank-operator`}}).Invoke("/updateState", checkFunds))
+			ExpectPaymentState(tickets, abac.Id, entities.CheckFundsInProgress)
+		})
+
+		It("Reject certificates without role attribute when attributes are enforced", func() {
+			admin := orgUser{merchant, map[string]string{entities.AttrRole: `merchant-admin`}}
+			bankOperator := orgUser{bank2, map[string]string{entities.AttrRole: `bank-operator`}}
+
+			var config entities.Config
+			Expect(json.Unmarshal(tickets.MockInvokeFunc("/config/get").Payload, &config)).To(Succeed())
+			config.EnforceAttributes = true
+			ExpectResponseError(tickets.From(orgUser{merchant, map[string]string{entities.AttrRole: `merchant-operator`}}).Invoke(
+				"/config/update", config), `required: admin`)
+			ExpectResponseOk(tickets.From(admin).Invoke("/config/update", config))
+
+			checkFundsSuccess := ticketFixture.UpdateState(`abac`, entities.CheckFundsSuccess)
+			ExpectResponseError(tickets.From(bank2).Invoke("/updateState", checkFundsSuccess), `certificate has no tickets.role attribute`)
+			ExpectResponseOk(tickets.From(bankOperator).Invoke("/updateState", checkFundsSuccess))
+
+			config.EnforceAttributes = false
+			ExpectResponseOk(tickets.From(admin).Invoke("/config/update", config))
+		})
+
+		It("Require admin certificate for admin routes and states configured by merchant", func() {
+			ExpectResponseError(tickets.From(orgUser{merchant, map[string]string{entities.AttrRole: `merchant-operator`}}).Invoke(
+				"/fees/set", entities.FeeSchedule{}), `certificate access operator is not enough for /fees/set, required: admin`)
+
+			var config entities.Config
+			Expect(json.Unmarshal(tickets.MockInvokeFunc("/config/get").Payload, &config)).To(Succeed())
+			config.StateAccess = map[entities.PaymentState]string{entities.CheckFundsFail: `supervisor`}
+			ExpectResponseError(tickets.From(adminOf(merchant, RoleMerchant)).Invoke("/config/update", config),
+				`unknown access level supervisor of state CheckFundsFail`)
+			config.StateAccess = map[entities.PaymentState]string{entities.CheckFundsFail: entities.AccessAdmin}
+			ExpectResponseOk(tickets.From(adminOf(merchant, RoleMerchant)).Invoke("/config/update", config))
+
+			restricted, _ := ticketFixture.GetFixture("payment_2_SALE_from_Org6MSP.json")
+			restricted.Id = `restricted`
+			restricted.Amount = 100
+			ExpectResponseOk(tickets.From(agent2).Invoke("/create", restricted))
+			ExpectResponseOk(tickets.From(bank2).Invoke("/updateState", ticketFixture.UpdateState(restricted.Id, entities.CheckFundsInProgress)))
+
+			checkFundsFail := ticketFixture.UpdateState(restricted.Id, entities.CheckFundsFail)
+			ExpectResponseError(tickets.From(orgUser{bank2, map[string]string{entities.AttrRole: `bank-operator`}}).Invoke("/updateState", checkFundsFail),
+				`certificate access operator is not enough for state CheckFundsFail, required: admin`)
+			ExpectResponseOk(tickets.From(adminOf(bank2, RoleBank)).Invoke("/updateState", checkFundsFail))
+			ExpectPaymentState(tickets, restricted.Id, entities.CheckFundsFail)
+
+			config.StateAccess = nil
+			ExpectResponseOk(tickets.From(adminOf(merchant, RoleMerchant)).Invoke("/config/update", config))
+		})
+
+		It("Exempt config owner without organization role from certificate attributes", func() {
+			owned := s7t.NewFullMockStub(`owned`, NewTicket(l))
+			owned.MockPeerChaincode("organizations/mychannel", orgs)
+			owned.RegisterCreatorTransformer(orgToCreatorTransformer)
+
+			config := entities.Config{Merchant: merchant.OrganizationId, Owner: someOrg.OrganizationId, DefaultCurrency: `RUB`}
+			ExpectResponseOk(owned.MockInit("1", orgs.ArgsToBytes(config)))
+
+			config.EnforceAttributes = true
+			ExpectResponseError(owned.From(merchant).Invoke("/config/update", config), `only owner can update config`)
+			ExpectResponseOk(owned.From(someOrg).Invoke("/config/update", config))
+			ExpectResponseOk(owned.From(orgUser{someOrg, map[string]string{entities.AttrRole: `owner-admin`}}).Invoke("/config/update", config))
+		})
+
+		It("Check amount and state access of hold routes", func() {
+			held := createPayload(`heldAbac`, 1000)
+			ExpectResponseOk(tickets.From(agent).Invoke("/create", held))
+			ExpectResponseOk(tickets.From(bank).Invoke("/updateState", ticketFixture.UpdateState(held.Id, entities.CheckFundsInProgress)))
+
+			hold := entities.HoldConfirmPayload{PaymentId: held.Id, ExpiresAt: time.Now().Add(time.Hour).Format(time.RFC3339)}
+			ExpectResponseError(tickets.From(orgUser{bank, map[string]string{
+				entities.AttrRole: `bank-operator`, entities.AttrMaxAmount: `500`}}).Invoke("/hold/confirm", hold),
+				`amount 1000 exceeds certificate max amount 500`)
+			ExpectResponseOk(tickets.From(bank).Invoke("/hold/confirm", hold))
+
+			var config entities.Config
+			Expect(json.Unmarshal(tickets.MockInvokeFunc("/config/get").Payload, &config)).To(Succeed())
+			config.StateAccess = map[entities.PaymentState]string{entities.DebitRequest: entities.AccessAdmin}
+			ExpectResponseOk(tickets.From(adminOf(merchant, RoleMerchant)).Invoke("/config/update", config))
+
+			capture := entities.HoldCapturePayload{PaymentId: held.Id, Amount: 700}
+			ExpectResponseError(tickets.From(orgUser{agent, map[string]string{entities.AttrRole: `agent-operator`}}).Invoke("/hold/capture", capture),
+				`certificate access operator is not enough for state DebitRequest, required: admin`)
+			ExpectResponseError(tickets.From(orgUser{agent, map[string]string{
+				entities.AttrRole: `agent-admin`, entities.AttrMaxAmount: `500`}}).Invoke("/hold/capture", capture),
+				`amount 700 exceeds certificate max amount 500`)
+			capture.Amount = 500
+			ExpectResponseOk(tickets.From(orgUser{agent, map[string]string{
+				entities.AttrRole: `agent-admin`, entities.AttrMaxAmount: `500`}}).Invoke("/hold/capture", capture))
+			ExpectPaymentState(tickets, held.Id, entities.DebitRequest)
+
+			config.StateAccess = nil
+			ExpectResponseOk(tickets.From(adminOf(merchant, RoleMerchant)).Invoke("/config/update", config))
+		})
+	})
+
+	Describe("Four-eyes approval", func() {
+		It("Expire approval not confirmed in time", func() {
+			approval := &entities.PaymentApproval{ExpiresAt: `2030-01-01T00:00:00Z`}
+			Expect(approvalExpired(approval, time.Date(2029, 12, 31, 0, 0, 0, 0, time.UTC))).To(BeFalse())
+			Expect(approvalExpired(approval, time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))).To(BeTrue())
+
+			config := &entities.Config{ApprovalThresholds: map[string]uint{RoleAgent: 300}}
+			Expect(needsApproval(config, 400, entities.DebitRequest, RoleAgent)).To(BeTrue())
+			Expect(needsApproval(config, 300, entities.DebitRequest, RoleAgent)).To(BeFalse())
+			Expect(needsApproval(config, 400, entities.DebitSuccess, RoleBank)).To(BeFalse())
+		})
+
+		It("Change high-value payment state only after second approver of organization", func() {
+			var config entities.Config
+			Expect(json.Unmarshal(tickets.MockInvokeFunc("/config/get").Payload, &config)).To(Succeed())
+			config.ApprovalThresholds = map[string]uint{`OPERATOR`: 300}
+			ExpectResponseError(tickets.From(adminOf(merchant, RoleMerchant)).Invoke("/config/update", config), `approval threshold is set only for agent and bank`)
+			config.ApprovalThresholds = map[string]uint{RoleAgent: 300, RoleBank: 300}
+			ExpectResponseOk(tickets.From(adminOf(merchant, RoleMerchant)).Invoke("/config/update", config))
+
+			fourEyes, _ := ticketFixture.GetFixture("payment_2_SALE_from_Org6MSP.json")
+			fourEyes.Id = `fourEyes`
+			fourEyes.Amount = 400
+			ExpectResponseOk(tickets.From(agent2).Invoke("/create", fourEyes))
+			ExpectResponseOk(tickets.From(bank2).Invoke("/updateState", ticketFixture.UpdateState(fourEyes.Id, entities.CheckFundsInProgress)))
+			ExpectResponseOk(tickets.From(bank2).Invoke("/updateState", ticketFixture.UpdateState(fourEyes.Id, entities.CheckFundsSuccess)))
+
+			debitRequest := ticketFixture.UpdateState(fourEyes.Id, entities.DebitRequest)
+			cashier := orgUser{agent2, map[string]string{entities.AttrRole: `agent-operator`}}
+			supervisor := orgUser{agent2, map[string]string{entities.AttrRole: `agent-admin`}}
+
+			ExpectResponseOk(tickets.From(cashier).Invoke("/updateState", debitRequest))
+			pending, _ := ticketFixture.FromBytes(tickets.MockInvokeFunc("/get", fourEyes.Id).Payload)
+			Expect(pending.State).To(Equal(entities.CheckFundsSuccess))
+			Expect(pending.PendingApproval.State).To(Equal(entities.DebitRequest))
+
+			ExpectResponseError(tickets.From(cashier).Invoke("/updateState", debitRequest), `must be approved by another certificate`)
+			ExpectResponseOk(tickets.From(supervisor).Invoke("/updateState", debitRequest))
+
+			approved, _ := ticketFixture.FromBytes(tickets.MockInvokeFunc("/get", fourEyes.Id).Payload)
+			Expect(approved.State).To(Equal(entities.DebitRequest))
+			Expect(approved.PendingApproval).To(BeNil())
+			Expect(approved.Approvals).To(HaveLen(1))
+			Expect(approved.Approvals[0].FirstSigner).NotTo(Equal(approved.Approvals[0].SecondSigner))
+
+			ExpectResponseOk(tickets.From(bank2).Invoke("/updateState", ticketFixture.UpdateState(fourEyes.Id, entities.DebitInProgress)))
+			debitSuccess := ticketFixture.UpdateState(fourEyes.Id, entities.DebitSuccess)
+			ExpectResponseOk(tickets.From(bank2).Invoke("/updateState", debitSuccess))
+			ExpectPaymentState(tickets, fourEyes.Id, entities.DebitInProgress)
+			ExpectResponseError(tickets.From(bank2).Invoke("/updateState", debitSuccess), `must be approved by another certificate`)
+			ExpectResponseOk(tickets.From(orgUser{bank2, map[string]string{entities.AttrRole: `bank-operator`}}).Invoke("/updateState", debitSuccess))
+			ExpectPaymentState(tickets, fourEyes.Id, entities.DebitSuccess)
+
+			config.ApprovalThresholds = nil
+			ExpectResponseOk(tickets.From(adminOf(merchant, RoleMerchant)).Invoke("/config/update", config))
+		})
+
+		It("Approve bank leg change to DebitSuccess above threshold by leg amount", func() {
+			var config entities.Config
+			Expect(json.Unmarshal(tickets.MockInvokeFunc("/config/get").Payload, &config)).To(Succeed())
+			config.ApprovalThresholds = map[string]uint{RoleBank: 300}
+			ExpectResponseOk(tickets.From(adminOf(merchant, RoleMerchant)).Invoke("/config/update", config))
+
+			split := createPayload(`splitFourEyes`, 1000)
+			split.Legs = []entities.PaymentLegPayload{
+				{BankOrgId: bank.OrganizationId, Account: agent.Requisites.SettlementAccount, Amount: 700},
+				{BankOrgId: bank2.OrganizationId, Account: `acc2`, Amount: 300},
+			}
+			ExpectResponseOk(tickets.From(agent).Invoke("/create", split))
+
+			for _, state := range []entities.PaymentState{entities.CheckFundsInProgress, entities.CheckFundsSuccess} {
+				ExpectResponseOk(tickets.From(bank).Invoke("/updateState", ticketFixture.UpdateState(split.Id, state)))
+				ExpectResponseOk(tickets.From(bank2).Invoke("/updateState", ticketFixture.UpdateState(split.Id, state)))
+			}
+			ExpectResponseOk(tickets.From(agent).Invoke("/updateState", ticketFixture.UpdateState(split.Id, entities.DebitRequest)))
+			ExpectResponseOk(tickets.From(bank).Invoke("/updateState", ticketFixture.UpdateState(split.Id, entities.DebitInProgress)))
+			ExpectResponseOk(tickets.From(bank2).Invoke("/updateState", ticketFixture.UpdateState(split.Id, entities.DebitInProgress)))
+
+			// leg above threshold is approved even when it doesn't move payment to DebitSuccess
+			debitSuccess := ticketFixture.UpdateState(split.Id, entities.DebitSuccess)
+			response := tickets.From(bank).Invoke("/updateState", debitSuccess)
+			ExpectResponseOk(response)
+			var approval entities.PaymentApprovalEvent
+			Expect(json.Unmarshal(response.Payload, &approval)).To(Succeed())
+			Expect(approval.Approval.State).To(Equal(entities.DebitSuccess))
+			Expect(approval.Approval.LegId).To(Equal(`1`))
+			Expect(approval.Approval.Amount).To(Equal(uint(700)))
+
+			// leg below threshold is changed without approval and keeps approval of another leg
+			ExpectResponseOk(tickets.From(bank2).Invoke("/updateState", debitSuccess))
+			pending, _ := ticketFixture.FromBytes(tickets.MockInvokeFunc("/get", split.Id).Payload)
+			Expect(pending.State).To(Equal(entities.DebitInProgress))
+			Expect(pending.Legs[0].State).To(Equal(entities.DebitInProgress))
+			Expect(pending.Legs[1].State).To(Equal(entities.DebitSuccess))
+			Expect(pending.PendingApproval.LegId).To(Equal(`1`))
+
+			ExpectResponseError(tickets.From(bank).Invoke("/updateState", debitSuccess), `must be approved by another certificate`)
+			ExpectResponseOk(tickets.From(orgUser{bank, map[string]string{entities.AttrRole: `bank-operator`}}).Invoke("/updateState", debitSuccess))
+			ExpectPaymentState(tickets, split.Id, entities.DebitSuccess)
+
+			config.ApprovalThresholds = nil
+			ExpectResponseOk(tickets.From(adminOf(merchant, RoleMerchant)).Invoke("/config/update", config))
+		})
+
+		It("Approve hold capture above agent threshold", func() {
+			var config entities.Config
+			Expect(json.Unmarshal(tickets.MockInvokeFunc("/config/get").Payload, &config)).To(Succeed())
+			config.ApprovalThresholds = map[string]uint{RoleAgent: 300}
+			ExpectResponseOk(tickets.From(adminOf(merchant, RoleMerchant)).Invoke("/config/update", config))
+
+			held := createPayload(`heldFourEyes`, 1000)
+			ExpectResponseOk(tickets.From(agent).Invoke("/create", held))
+			ExpectResponseOk(tickets.From(bank).Invoke("/updateState", ticketFixture.UpdateState(held.Id, entities.CheckFundsInProgress)))
+			ExpectResponseOk(tickets.From(bank).Invoke("/hold/confirm", entities.HoldConfirmPayload{PaymentId: held.Id,
+				ExpiresAt: time.Now().Add(time.Hour).Format(time.RFC3339)}))
+
+			supervisor := orgUser{agent, map[string]string{entities.AttrRole: `agent-admin`}}
+			ExpectResponseOk(tickets.From(agent).Invoke("/hold/capture", entities.HoldCapturePayload{PaymentId: held.Id, Amount: 700}))
+			pending, _ := ticketFixture.FromBytes(tickets.MockInvokeFunc("/get", held.Id).Payload)
+			Expect(pending.State).To(Equal(entities.FundsHeld))
+			Expect(pending.Amount).To(Equal(uint(1000)))
+			Expect(pending.PendingApproval.Amount).To(Equal(uint(700)))
+
+			// approval of other amount is replaced, second signer approves only amount of first one
+			capture := entities.HoldCapturePayload{PaymentId: held.Id, Amount: 600}
+			ExpectResponseOk(tickets.From(supervisor).Invoke("/hold/capture", capture))
+			ExpectPaymentState(tickets, held.Id, entities.FundsHeld)
+			ExpectResponseOk(tickets.From(agent).Invoke("/hold/capture", capture))
+
+			captured, _ := ticketFixture.FromBytes(tickets.MockInvokeFunc("/get", held.Id).Payload)
+			Expect(captured.State).To(Equal(entities.DebitRequest))
+			Expect(captured.Amount).To(Equal(uint(600)))
+			Expect(captured.PendingApproval).To(BeNil())
+			Expect(captured.Approvals).To(HaveLen(1))
+
+			config.ApprovalThresholds = nil
+			ExpectResponseOk(tickets.From(adminOf(merchant, RoleMerchant)).Invoke("/config/update", config))
+		})
+	})
+
+	Describe("Migration", func() {
+		It("Upgrade payments stored without schema version", func() {
+			legacyKey := `PAYMENT_legacy_1`
+			tickets.MockTransactionStart(`legacy`)
+			tickets.PutState(legacyKey, []byte(`{"paymentId":"legacy_1","state":"TicketCanceled","amount":500,"currency":"RUB"}`))
+			tickets.MockTransactionEnd(`legacy`)
+
+			ExpectResponseError(tickets.From(adminOf(agent, RoleAgent)).Invoke("/migrate"), `only merchant can run migration`)
+
+			var migrationLog entities.MigrationLog
+			for i := 0; i < 10 && !migrationLog.Completed; i++ {
+				response := tickets.From(adminOf(merchant, RoleMerchant)).Invoke("/migrate", "1")
+				ExpectResponseOk(response)
+				Expect(json.Unmarshal(response.Payload, &migrationLog)).To(Succeed())
+			}
+			Expect(migrationLog.Completed).To(BeTrue())
+			Expect(migrationLog.TargetVersion).To(Equal(entities.PaymentSchemaVersion))
+			Expect(migrationLog.Migrated).To(Equal(uint(1)))
+
+			legacy, _ := ticketFixture.FromBytes(tickets.MockInvokeFunc("/get", `legacy_1`).Payload)
+			Expect(legacy.SchemaVersion).To(Equal(entities.PaymentSchemaVersion))
+			Expect(legacy.NetAmount).To(Equal(uint(500)))
+			Expect(legacy.Meta).NotTo(BeNil())
+		})
+
+		It("Refuse payments from newer schema", func() {
+			_, err := migratePayment(&entities.Payment{SchemaVersion: entities.PaymentSchemaVersion + 1}, entities.PaymentSchemaVersion)
+			Expect(err).To(HaveOccurred())
+		})
+	})
 })
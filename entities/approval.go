@@ -0,0 +1,25 @@
+package entities
+
+// PaymentApproval is four-eyes approval of payment state change by two certificates of one organization
+// FirstSigner and SecondSigner are certificate identities, approval expires at ExpiresAt if second signer doesn't confirm it
+// Amount is payment amount when approval was requested, second signer approves change of the same amount
+// LegId is set for state change of split payment leg, Amount is leg amount then
+type PaymentApproval struct {
+	State        PaymentState `json:"state"`
+	LegId        string       `json:"legId,omitempty"`
+	Amount       uint         `json:"amount"`
+	Role         string       `json:"role"`
+	OrgId        string       `json:"orgId"`
+	FirstSigner  string       `json:"firstSigner"`
+	SecondSigner string       `json:"secondSigner"`
+	RequestedAt  string       `json:"requestedAt"`
+	ExpiresAt    string       `json:"expiresAt"`
+	ApprovedAt   string       `json:"approvedAt"`
+}
+
+type PaymentApprovalEvent struct {
+	PaymentId string          `json:"paymentId"`
+	Approval  PaymentApproval `json:"approval"`
+}
+
+const PaymentApprovalRequested = "PaymentApprovalRequested"
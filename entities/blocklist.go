@@ -0,0 +1,26 @@
+package entities
+
+type BlocklistEntryType string
+
+const (
+	BlockAccount BlocklistEntryType = "ACCOUNT"
+	BlockItn     BlocklistEntryType = "ITN"
+	BlockPayerId BlocklistEntryType = "PAYER_ID"
+)
+
+// BlocklistEntry rejects payments of payer with matched account, itn or payer id
+// ExpiresAt is RFC3339 time, entry never expires if it is empty
+type BlocklistEntry struct {
+	Type        BlocklistEntryType `json:"type"`
+	Value       string             `json:"value"`
+	Reason      string             `json:"reason"`
+	ExpiresAt   string             `json:"expiresAt"`
+	AddedBy     string             `json:"addedBy"`
+	AddedByRole string             `json:"addedByRole"`
+	AddedAt     string             `json:"addedAt"`
+}
+
+const (
+	BlocklistEntriesAdded = "BlocklistEntriesAdded"
+	BlocklistEntryRemoved = "BlocklistEntryRemoved"
+)
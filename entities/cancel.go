@@ -0,0 +1,40 @@
+package entities
+
+// Reason codes of canceled and voided payments
+const (
+	CancelReasonDebitFail   = "DEBIT_FAIL"
+	CancelReasonAgentCancel = "AGENT_CANCEL"
+	CancelReasonVoid        = "MERCHANT_VOID"
+)
+
+// CancelPayload is sent by agent canceling payment before debit or merchant voiding issued ticket
+type CancelPayload struct {
+	PaymentId string `json:"paymentId"`
+	Reason    string `json:"reason"`
+}
+
+// IssuePayload is sent by agent after ticket issuance
+type IssuePayload struct {
+	PaymentId    string `json:"paymentId"`
+	TicketNumber string `json:"ticketNumber"`
+}
+
+// PaymentCanceledEvent lists banks of every leg of split payment in PayerBankOrgIds
+type PaymentCanceledEvent struct {
+	PaymentId       string       `json:"payment_id"`
+	PayerOrgId      string       `json:"payer_org_id"`
+	PayerBankOrgId  string       `json:"payer_bank_org_id"`
+	PayerBankOrgIds []string     `json:"payer_bank_org_ids"`
+	PreviousState   PaymentState `json:"previous_state"`
+	CurrentState    PaymentState `json:"current_state"`
+	ReasonCode      string       `json:"reason_code"`
+	Reason          string       `json:"reason"`
+	Amount          uint         `json:"amount"`
+	Currency        string       `json:"currency"`
+}
+
+const (
+	TicketIssued                 = "TicketIssued"
+	TicketPaymentCanceledByAgent = "TicketPaymentCanceledByAgent"
+	TicketVoidedByMerchant       = "TicketVoidedByMerchant"
+)
@@ -0,0 +1,63 @@
+package entities
+
+// Config is tickets chaincode settings, set by init payload and changed by owner with /config/update
+type Config struct {
+	Merchant        string `json:"merchant"`
+	Owner           string `json:"owner"`
+	DefaultCurrency string `json:"defaultCurrency"`
+
+	// IssuanceTimeout is seconds given to agent to issue ticket after successful debit
+	IssuanceTimeout uint `json:"issuanceTimeout"`
+
+	MinPaymentAmount uint `json:"minPaymentAmount"`
+	MaxPaymentAmount uint `json:"maxPaymentAmount"`
+
+	// MaxRetries is count of agent retries after CheckFundsFail and DebitFail, zero disables retries,
+	// default is set when it is omitted, configs stored before retries were introduced have no value and don't allow retries
+	MaxRetries *uint `json:"maxRetries"`
+
+	// VoidWindow is seconds after ticket issuance when merchant can void ticket with refund, zero disables voids,
+	// default is set when it is omitted, configs stored before voids were introduced have no value and don't allow voids
+	VoidWindow *uint `json:"voidWindow"`
+
+	// DisputeResponseWindow is seconds given to merchant to respond to dispute,
+	// DisputeResolutionWindow is seconds given to payer bank to resolve dispute after response
+	DisputeResponseWindow   uint `json:"disputeResponseWindow"`
+	DisputeResolutionWindow uint `json:"disputeResolutionWindow"`
+
+	// EnforceAttributes rejects changes from certificates without role attribute,
+	// otherwise such certificates have admin access of organization role as before attributes were introduced
+	EnforceAttributes bool `json:"enforceAttributes"`
+
+	// StateAccess is certificate access level required to change payment to state,
+	// states not listed are changed with operator access required by /updateState
+	StateAccess map[PaymentState]string `json:"stateAccess"`
+
+	// ApprovalThresholds is payment amount per role above which DebitRequest of agent and DebitSuccess of bank
+	// need two distinct approvers, ApprovalTimeout is seconds given to second approver
+	ApprovalThresholds map[string]uint `json:"approvalThresholds"`
+	ApprovalTimeout    uint            `json:"approvalTimeout"`
+}
+
+// Retries returns max count of agent retries, zero if it isn't set
+func (c Config) Retries() uint {
+	if c.MaxRetries == nil {
+		return 0
+	}
+	return *c.MaxRetries
+}
+
+// VoidSeconds returns void window, zero if it isn't set
+func (c Config) VoidSeconds() uint {
+	if c.VoidWindow == nil {
+		return 0
+	}
+	return *c.VoidWindow
+}
+
+type ConfigUpdatedEvent struct {
+	Previous Config `json:"previous"`
+	Current  Config `json:"current"`
+}
+
+const ConfigUpdated = "ConfigUpdated"
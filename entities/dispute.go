@@ -0,0 +1,80 @@
+package entities
+
+// DisputeState is stage of dispute case, ACCEPTED and REJECTED are final
+type DisputeState string
+
+const (
+	DisputeOpened    DisputeState = "OPENED"
+	DisputeResponded DisputeState = "RESPONDED"
+	DisputeAccepted  DisputeState = "ACCEPTED"
+	DisputeRejected  DisputeState = "REJECTED"
+)
+
+// CancelReasonChargeback is reason code of payment refunded by accepted dispute
+const CancelReasonChargeback = "CHARGEBACK"
+
+// DisputeEvidence is reference to document kept off ledger, Hash is hex encoded sha256 of document
+type DisputeEvidence struct {
+	Hash        string `json:"hash"`
+	Description string `json:"description"`
+}
+
+// Dispute is case opened by payer bank against debited payment
+// Merchant responds with evidence until RespondBy, bank resolves case until ResolveBy
+type Dispute struct {
+	Id             string            `json:"disputeId"`
+	PaymentId      string            `json:"paymentId"`
+	PayerOrgId     string            `json:"payerOrgId"`
+	BankOrgId      string            `json:"bankOrgId"`
+	State          DisputeState      `json:"state"`
+	Amount         uint              `json:"amount"`
+	Currency       string            `json:"currency"`
+	Reason         string            `json:"reason"`
+	Evidence       []DisputeEvidence `json:"evidence"`
+	MerchantReply  string            `json:"merchantReply"`
+	Resolution     string            `json:"resolution"`
+	OpenedAt       string            `json:"openedAt"`
+	RespondBy      string            `json:"respondBy"`
+	RespondedAt    string            `json:"respondedAt"`
+	ResolveBy      string            `json:"resolveBy"`
+	ResolvedAt     string            `json:"resolvedAt"`
+	ResolvedBy     string            `json:"resolvedBy"`
+	ResolvedByRole string            `json:"resolvedByRole"`
+}
+
+// DisputeOpenPayload is sent by payer bank
+type DisputeOpenPayload struct {
+	PaymentId string `json:"paymentId"`
+	Reason    string `json:"reason"`
+}
+
+// DisputeRespondPayload is sent by merchant, at least one evidence is required
+type DisputeRespondPayload struct {
+	DisputeId string            `json:"disputeId"`
+	Evidence  []DisputeEvidence `json:"evidence"`
+	Reply     string            `json:"reply"`
+}
+
+// DisputeResolvePayload is sent by payer bank or merchant, State is ACCEPTED or REJECTED
+type DisputeResolvePayload struct {
+	DisputeId  string       `json:"disputeId"`
+	State      DisputeState `json:"state"`
+	Resolution string       `json:"resolution"`
+}
+
+type DisputeEvent struct {
+	DisputeId      string       `json:"dispute_id"`
+	PaymentId      string       `json:"payment_id"`
+	PayerOrgId     string       `json:"payer_org_id"`
+	BankOrgId      string       `json:"bank_org_id"`
+	State          DisputeState `json:"state"`
+	PaymentState   PaymentState `json:"payment_state"`
+	Deadline       string       `json:"deadline"`
+	EvidenceHashes []string     `json:"evidence_hashes"`
+}
+
+const (
+	PaymentDisputeOpened    = "PaymentDisputeOpened"
+	PaymentDisputeResponded = "PaymentDisputeResponded"
+	PaymentDisputeResolved  = "PaymentDisputeResolved"
+)
@@ -0,0 +1,34 @@
+package entities
+
+// FeeRule matches payment by route and amount, empty or zero criteria match any payment
+// Percent values are set in basis points, 1 bp = 0.01%
+type FeeRule struct {
+	Id                  string `json:"id"`
+	InternationalFlight *bool  `json:"internationalFlight,omitempty"`
+	PaymentType         string `json:"paymentType"`
+	Currency            string `json:"currency"`
+	MinAmount           uint   `json:"minAmount"`
+	MaxAmount           uint   `json:"maxAmount"`
+
+	CommissionBps    uint `json:"commissionBps"`
+	CommissionFixed  uint `json:"commissionFixed"`
+	MerchantFeeBps   uint `json:"merchantFeeBps"`
+	MerchantFeeFixed uint `json:"merchantFeeFixed"`
+}
+
+// FeeSchedule is ordered list of fee rules, first matched rule is applied
+type FeeSchedule struct {
+	Version uint      `json:"version"`
+	Rules   []FeeRule `json:"rules"`
+}
+
+// PaymentFees is result of fee schedule applied to payment
+type PaymentFees struct {
+	FeeScheduleVersion uint   `json:"feeScheduleVersion"`
+	FeeRuleId          string `json:"feeRuleId"`
+	AgentCommission    uint   `json:"agentCommission"`
+	MerchantFee        uint   `json:"merchantFee"`
+	NetAmount          uint   `json:"netAmount"`
+}
+
+const FeeScheduleUpdated = "FeeScheduleUpdated"
@@ -0,0 +1,18 @@
+package entities
+
+type PaymentFieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+type PaymentHistoryEntry struct {
+	TxId          string               `json:"txId"`
+	Timestamp     string               `json:"timestamp"`
+	IsDelete      bool                 `json:"isDelete"`
+	Actor         string               `json:"actor"`
+	ActorRole     string               `json:"actorRole"`
+	PreviousState *Payment             `json:"previousState"`
+	State         *Payment             `json:"state"`
+	Changes       []PaymentFieldChange `json:"changes"`
+}
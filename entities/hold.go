@@ -0,0 +1,42 @@
+package entities
+
+// PaymentHold is funds reserved by payer bank after check funds
+// Captured is debited amount, rest of hold is returned to payer on capture
+type PaymentHold struct {
+	BankOrgId   string `json:"bankOrgId"`
+	Amount      uint   `json:"amount"`
+	ExpiresAt   string `json:"expiresAt"`
+	ConfirmedAt string `json:"confirmedAt"`
+	Captured    uint   `json:"captured"`
+	CapturedAt  string `json:"capturedAt"`
+	ReleasedBy  string `json:"releasedBy"`
+	ReleasedAt  string `json:"releasedAt"`
+}
+
+// HoldConfirmPayload is sent by payer bank, ExpiresAt is RFC3339 time
+type HoldConfirmPayload struct {
+	PaymentId string `json:"paymentId"`
+	ExpiresAt string `json:"expiresAt"`
+}
+
+// HoldCapturePayload is sent by agent, zero Amount captures full hold
+type HoldCapturePayload struct {
+	PaymentId string `json:"paymentId"`
+	Amount    uint   `json:"amount"`
+}
+
+// PaymentHoldEvent lists banks of every leg of split payment in PayerBankOrgIds
+type PaymentHoldEvent struct {
+	PaymentId       string       `json:"payment_id"`
+	PayerOrgId      string       `json:"payer_org_id"`
+	PayerBankOrgId  string       `json:"payer_bank_org_id"`
+	PayerBankOrgIds []string     `json:"payer_bank_org_ids"`
+	State           PaymentState `json:"state"`
+	Hold            PaymentHold  `json:"hold"`
+}
+
+const (
+	PaymentHoldConfirmed = "PaymentHoldConfirmed"
+	PaymentHoldCaptured  = "PaymentHoldCaptured"
+	PaymentHoldReleased  = "PaymentHoldReleased"
+)
@@ -0,0 +1,70 @@
+package entities
+
+// PNRPattern is format of booking record locator, itinerary PNR and pnr meta value must match it
+const PNRPattern = `^[A-Z0-9]{6}$`
+
+// Passenger types of IATA fare
+const (
+	PassengerAdult  = "ADT"
+	PassengerChild  = "CHD"
+	PassengerInfant = "INF"
+)
+
+// Passenger name is in uppercase latin letters as in ticket
+type Passenger struct {
+	LastName  string `json:"lastName"`
+	FirstName string `json:"firstName"`
+	Type      string `json:"type"`
+}
+
+// FlightSegment is one flight of itinerary
+// Carrier is IATA airline designator, Origin and Destination are IATA airport codes,
+// countries are ISO 3166 alpha-2 codes, DepartureDate is YYYY-MM-DD
+type FlightSegment struct {
+	Carrier            string `json:"carrier"`
+	FlightNumber       string `json:"flightNumber"`
+	Origin             string `json:"origin"`
+	OriginCountry      string `json:"originCountry"`
+	Destination        string `json:"destination"`
+	DestinationCountry string `json:"destinationCountry"`
+	DepartureDate      string `json:"departureDate"`
+}
+
+// FareTax is tax or fee with IATA tax code
+type FareTax struct {
+	Code   string `json:"code"`
+	Amount uint   `json:"amount"`
+}
+
+// Fare is breakdown of payment amount, base fare and taxes sum to payment amount
+type Fare struct {
+	BaseFare uint      `json:"baseFare"`
+	Taxes    []FareTax `json:"taxes"`
+}
+
+// Total returns base fare with taxes
+func (f Fare) Total() uint {
+	total := f.BaseFare
+	for _, tax := range f.Taxes {
+		total += tax.Amount
+	}
+	return total
+}
+
+// Itinerary is booking paid by payment, PNR is record locator of booking
+type Itinerary struct {
+	PNR        string          `json:"pnr"`
+	Passengers []Passenger     `json:"passengers"`
+	Segments   []FlightSegment `json:"segments"`
+	Fare       Fare            `json:"fare"`
+}
+
+// International returns true if itinerary visits more than one country
+func (i Itinerary) International() bool {
+	countries := make(map[string]bool)
+	for _, s := range i.Segments {
+		countries[s.OriginCountry] = true
+		countries[s.DestinationCountry] = true
+	}
+	return len(countries) > 1
+}
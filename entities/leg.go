@@ -0,0 +1,32 @@
+package entities
+
+// PaymentLegPayload is funding leg of split payment requested by agent
+type PaymentLegPayload struct {
+	BankOrgId string `json:"bankOrgId"`
+	Account   string `json:"account"`
+	Amount    uint   `json:"amount"`
+}
+
+// PaymentLeg is part of payment funded from one payer bank account
+// Leg passes check funds and debit states independently, payment state is derived from legs
+type PaymentLeg struct {
+	Id        string       `json:"id"`
+	BankOrgId string       `json:"bankOrgId"`
+	Account   string       `json:"account"`
+	Amount    uint         `json:"amount"`
+	State     PaymentState `json:"state"`
+}
+
+// AgentAccountPayload is settlement account agent registers to fund split payment legs
+type AgentAccountPayload struct {
+	BankOrgId string `json:"bankOrgId"`
+	Account   string `json:"account"`
+}
+
+// AgentAccount is settlement account of agent at bank, account funds legs only after bank confirmed it
+type AgentAccount struct {
+	AgentId   string `json:"agentId"`
+	BankOrgId string `json:"bankOrgId"`
+	Account   string `json:"account"`
+	Confirmed bool   `json:"confirmed"`
+}
@@ -0,0 +1,53 @@
+package entities
+
+import "errors"
+
+// ErrLimitExceeded prefixes error of payment creation rejected by agent or point of sale limit
+var ErrLimitExceeded = errors.New(`payment limit exceeded`)
+
+// AgentLimit restricts payments created by agent during rolling window, zero max value means no limit
+type AgentLimit struct {
+	WindowSeconds int64 `json:"windowSeconds"`
+	MaxCount      uint  `json:"maxCount"`
+	MaxAmount     uint  `json:"maxAmount"`
+}
+
+// PaymentLimits is set by merchant, Default is applied to agents without own limit
+type PaymentLimits struct {
+	Version uint                  `json:"version"`
+	Default AgentLimit            `json:"default"`
+	Agents  map[string]AgentLimit `json:"agents"`
+}
+
+// AgentUsageEntry is payment created by agent, Timestamp is unix time of transaction
+type AgentUsageEntry struct {
+	PaymentId string `json:"paymentId"`
+	Timestamp int64  `json:"timestamp"`
+	Amount    uint   `json:"amount"`
+}
+
+// AgentUsage is payments created by agent inside current window
+type AgentUsage struct {
+	AgentId string            `json:"agentId"`
+	Entries []AgentUsageEntry `json:"entries"`
+}
+
+const (
+	LimitCount  = "COUNT"
+	LimitAmount = "AMOUNT"
+)
+
+// LimitBreachEvent is emitted when agent payment exceeds limit
+type LimitBreachEvent struct {
+	AgentId       string `json:"agentId"`
+	PaymentId     string `json:"paymentId"`
+	Limit         string `json:"limit"`
+	Max           uint   `json:"max"`
+	Requested     uint   `json:"requested"`
+	WindowSeconds int64  `json:"windowSeconds"`
+}
+
+const (
+	PaymentLimitsUpdated = "PaymentLimitsUpdated"
+	LimitBreached        = "LimitBreached"
+)
@@ -0,0 +1,67 @@
+package entities
+
+// Payment meta keys allowed by MetaSchema
+const (
+	MetaPNR           = "pnr"
+	MetaPassengerName = "passengerName"
+	MetaFlight        = "flight"
+	MetaRoute         = "route"
+)
+
+// MetaField describes allowed payment meta key
+// Writers are roles allowed to set key, agent and bank must be participants of payment
+type MetaField struct {
+	Key         string   `json:"key"`
+	Description string   `json:"description"`
+	MaxSize     int      `json:"maxSize"`
+	Pattern     string   `json:"pattern"`
+	Writers     []string `json:"writers"`
+}
+
+// MetaSchema lists keys which can be stored in payment meta
+var MetaSchema = []MetaField{
+	{Key: MetaPNR, Description: `booking reference`, MaxSize: 6, Pattern: PNRPattern,
+		Writers: []string{`AGENT`}},
+	{Key: MetaPassengerName, Description: `passenger name as in ticket`, MaxSize: 128,
+		Writers: []string{`AGENT`}},
+	{Key: MetaFlight, Description: `carrier code and flight number`, MaxSize: 8, Pattern: `^[A-Z0-9]{2}[0-9]{1,4}[A-Z]?$`,
+		Writers: []string{`AGENT`, `MERCHANT`}},
+	{Key: MetaRoute, Description: `airport codes separated by dash`, MaxSize: 64, Pattern: `^[A-Z]{3}(-[A-Z]{3})+$`,
+		Writers: []string{`AGENT`, `MERCHANT`}},
+}
+
+// MaxMetaSize is limit of summary size of payment meta values in bytes
+const MaxMetaSize = 1024
+
+// PaymentMeta is typed view of payment meta
+type PaymentMeta struct {
+	PNR           string `json:"pnr"`
+	PassengerName string `json:"passengerName"`
+	Flight        string `json:"flight"`
+	Route         string `json:"route"`
+}
+
+// NewPaymentMeta returns typed view of payment meta, unknown keys are skipped
+func NewPaymentMeta(meta map[string][]byte) PaymentMeta {
+	return PaymentMeta{
+		PNR:           string(meta[MetaPNR]),
+		PassengerName: string(meta[MetaPassengerName]),
+		Flight:        string(meta[MetaFlight]),
+		Route:         string(meta[MetaRoute]),
+	}
+}
+
+// MetaPutPayload sets payment meta values, empty value removes key
+type MetaPutPayload struct {
+	PaymentId string            `json:"paymentId"`
+	Values    map[string]string `json:"values"`
+}
+
+// PaymentMetaUpdatedEvent has changed keys only, values may contain personal data
+type PaymentMetaUpdatedEvent struct {
+	PaymentId string   `json:"payment_id"`
+	Keys      []string `json:"keys"`
+	UpdatedBy string   `json:"updated_by"`
+}
+
+const PaymentMetaUpdated = "PaymentMetaUpdated"
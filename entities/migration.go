@@ -0,0 +1,19 @@
+package entities
+
+// PaymentSchemaVersion is version of Payment json stored in world state
+// Records without schemaVersion are treated as version 0
+const PaymentSchemaVersion uint = 1
+
+// MigrationLog tracks progress of payment migration to TargetVersion
+// LastKey is last processed state key, next chunk is started after it
+type MigrationLog struct {
+	TargetVersion uint   `json:"targetVersion"`
+	LastKey       string `json:"lastKey"`
+	Processed     uint   `json:"processed"`
+	Migrated      uint   `json:"migrated"`
+	Completed     bool   `json:"completed"`
+	StartedAt     string `json:"startedAt"`
+	UpdatedAt     string `json:"updatedAt"`
+}
+
+const PaymentsMigrated = "PaymentsMigrated"
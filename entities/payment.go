@@ -18,9 +18,15 @@ type PaymentCreatePayload struct {
 	RecipientAccount    string `json:"recipientAccount"`
 	RecipientNumber     string `json:"recipientNumber"`
 	VatIncluded         bool   `json:"vat"`
+	// Legs split payment between payer bank accounts, leg amounts must sum to Amount
+	Legs []PaymentLegPayload `json:"legs"`
+	// Itinerary is booking paid by payment, InternationalFlight is derived from it and ignored in payload,
+	// payment without itinerary is domestic
+	Itinerary *Itinerary `json:"itinerary"`
 }
 
 type Payment struct {
+	SchemaVersion       uint              `json:"schemaVersion"`
 	Id                  string            `json:"paymentId"`
 	TicketNumber        string            `json:"ticket_number"`
 	State               PaymentState      `json:"state"`
@@ -31,6 +37,7 @@ type Payment struct {
 	VatIncluded         bool              `json:"vat"`
 	Purpose             string            `json:"purpose"`
 	Meta                map[string][]byte `json:"meta"`
+	PaymentFees
 
 	PayerOrgId         string `json:"payerOrgId"`
 	PayerBankOrgId     string `json:"payerBankOrgId"`
@@ -42,6 +49,50 @@ type Payment struct {
 	RecipientId        string `json:"recipientId"`
 	RecipientAccount   string `json:"recipientAccount"`
 	RecipientNumber    string `json:"recipientNumber"`
+
+	// RetryCount is count of agent retries after failed check funds or debit
+	RetryCount        uint   `json:"retryCount"`
+	LastFailureReason string `json:"lastFailureReason"`
+
+	// IssuedAt is RFC3339 time of ticket issuance, merchant can void ticket during void window after it
+	IssuedAt     string `json:"issuedAt"`
+	CancelReason string `json:"cancelReason"`
+	CanceledAt   string `json:"canceledAt"`
+
+	// Itinerary is booking paid by payment, it is searchable by PNR
+	Itinerary *Itinerary `json:"itinerary"`
+
+	// DisputeId is last dispute case opened against payment, DisputeState is its current stage
+	DisputeId    string       `json:"disputeId"`
+	DisputeState DisputeState `json:"disputeState"`
+
+	// Hold is set when payer bank reserved funds instead of plain check funds
+	Hold *PaymentHold `json:"hold"`
+
+	// Legs are set for split payment, PayerBankOrgId and PayerAccount are taken from first leg in this case
+	Legs []PaymentLeg `json:"legs"`
+
+	SettlementBatchId       string `json:"settlementBatchId"`
+	RefundSettlementBatchId string `json:"refundSettlementBatchId"`
+	Settled                 bool   `json:"settled"`
+
+	// SalesReportId and RefundReportId are closed sales reports which included issue and refund of payment
+	SalesReportId  string `json:"salesReportId"`
+	RefundReportId string `json:"refundReportId"`
+
+	// RiskFlags are ids of risk rules matched on creation, payment is created in RiskReview state if any matched
+	RiskRulesVersion uint     `json:"riskRulesVersion"`
+	RiskFlags        []string `json:"riskFlags"`
+
+	// PendingApproval is state change signed by first approver and waiting for second one, Approvals are completed ones
+	PendingApproval *PaymentApproval  `json:"pendingApproval"`
+	Approvals       []PaymentApproval `json:"approvals"`
+
+	// SubAgentId is point of sale of payer agent which created payment, empty if agent created it itself
+	SubAgentId string `json:"subAgentId"`
+
+	UpdatedBy     string `json:"updatedBy"`
+	UpdatedByRole string `json:"updatedByRole"`
 }
 
 type PaymentState string
@@ -59,6 +110,10 @@ const (
 	DebitFail             PaymentState = "DebitFail"
 	TicketCanceled        PaymentState = "TicketCanceled"
 	Refunded              PaymentState = "Refunded"
+	RiskReview            PaymentState = "RiskReview"
+	RiskRejected          PaymentState = "RiskRejected"
+	FundsHeld             PaymentState = "FundsHeld"
+	HoldReleased          PaymentState = "HoldReleased"
 )
 
 type TicketsPaymentStateChangedEvent struct {
@@ -70,6 +125,9 @@ type TicketsPaymentStateChangedEvent struct {
 	From          entities.Member `json:"from"`
 	Amount        uint            `json:"amount"`
 	Currency      string          `json:"currency"`
+	PaymentFees
+	// Leg is set when bank changed state of split payment leg
+	Leg *PaymentLeg `json:"leg,omitempty"`
 }
 
 const TicketPaymentCreated = "TicketPaymentCreated"
@@ -0,0 +1,17 @@
+package entities
+
+// Certificate attributes with permissions of client inside organization
+// AttrRole is "<organization role>-<access>" in lower case, e.g. bank-operator or merchant-admin,
+// AttrApprove allows amounts above AttrMaxAmount, zero or missing max amount doesn't limit client
+const (
+	AttrRole      = "tickets.role"
+	AttrApprove   = "tickets.approve"
+	AttrMaxAmount = "tickets.maxAmount"
+)
+
+// Access levels of certificate role, every level includes previous one
+const (
+	AccessViewer   = "viewer"
+	AccessOperator = "operator"
+	AccessAdmin    = "admin"
+)
@@ -0,0 +1,97 @@
+package entities
+
+// Payment types, exchange payments are reported as EXCH transactions
+const (
+	PaymentTypeSale     = "SALE"
+	PaymentTypeExchange = "EXCHANGE"
+)
+
+// Transaction codes of sales report lines as in BSP HOT files
+const (
+	TransactionIssue    = "TKTT"
+	TransactionExchange = "EXCH"
+	TransactionRefund   = "RFND"
+)
+
+// SalesReportState is DRAFT until merchant and agent co-signed report, CLOSED report locks period
+type SalesReportState string
+
+const (
+	SalesReportDraft  SalesReportState = "DRAFT"
+	SalesReportClosed SalesReportState = "CLOSED"
+)
+
+// SalesReportLine is issued, exchanged or refunded payment of reporting period
+// Fare is base fare without taxes, Remittance is amount agent owes to merchant after commission
+type SalesReportLine struct {
+	PaymentId       string `json:"paymentId"`
+	TicketNumber    string `json:"ticketNumber"`
+	PNR             string `json:"pnr"`
+	TransactionCode string `json:"transactionCode"`
+	Date            string `json:"date"`
+	Currency        string `json:"currency"`
+	Amount          uint   `json:"amount"`
+	Fare            uint   `json:"fare"`
+	Taxes           uint   `json:"taxes"`
+	Commission      uint   `json:"commission"`
+	Remittance      uint   `json:"remittance"`
+}
+
+// SalesReportTotal sums report lines in currency, refunds are subtracted in net amounts
+type SalesReportTotal struct {
+	Currency       string `json:"currency"`
+	Issues         uint   `json:"issues"`
+	Exchanges      uint   `json:"exchanges"`
+	Refunds        uint   `json:"refunds"`
+	GrossAmount    uint   `json:"grossAmount"`
+	RefundedAmount uint   `json:"refundedAmount"`
+	NetTaxes       int64  `json:"netTaxes"`
+	NetCommission  int64  `json:"netCommission"`
+	NetRemittance  int64  `json:"netRemittance"`
+}
+
+// SalesReportSignature is co-signature of report hash by merchant or agent
+type SalesReportSignature struct {
+	OrgId    string `json:"orgId"`
+	Role     string `json:"role"`
+	Hash     string `json:"hash"`
+	SignedAt string `json:"signedAt"`
+}
+
+// SalesReport aggregates agent payments of reporting period [PeriodStart, PeriodEnd)
+// Hash is hex encoded sha256 of lines and totals, signatures are reset when report is rebuilt
+type SalesReport struct {
+	Id          string                 `json:"reportId"`
+	AgentId     string                 `json:"agentId"`
+	PeriodStart string                 `json:"periodStart"`
+	PeriodEnd   string                 `json:"periodEnd"`
+	State       SalesReportState       `json:"state"`
+	Lines       []SalesReportLine      `json:"lines"`
+	Totals      []SalesReportTotal     `json:"totals"`
+	Hash        string                 `json:"hash"`
+	Signatures  []SalesReportSignature `json:"signatures"`
+	CreatedAt   string                 `json:"createdAt"`
+	ClosedAt    string                 `json:"closedAt"`
+}
+
+// SalesReportPayload is sent by merchant, period dates are YYYY-MM-DD and PeriodEnd is exclusive
+type SalesReportPayload struct {
+	AgentId     string `json:"agentId"`
+	PeriodStart string `json:"periodStart"`
+	PeriodEnd   string `json:"periodEnd"`
+}
+
+type SalesReportEvent struct {
+	ReportId    string           `json:"report_id"`
+	AgentId     string           `json:"agent_id"`
+	PeriodStart string           `json:"period_start"`
+	PeriodEnd   string           `json:"period_end"`
+	State       SalesReportState `json:"state"`
+	Hash        string           `json:"hash"`
+}
+
+const (
+	SalesReportCreated = "SalesReportCreated"
+	SalesReportSigned  = "SalesReportSigned"
+	SalesPeriodClosed  = "SalesPeriodClosed"
+)
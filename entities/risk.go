@@ -0,0 +1,48 @@
+package entities
+
+type RiskRuleType string
+
+const (
+	// RiskAmount matches payment with amount above Threshold
+	RiskAmount RiskRuleType = "AMOUNT"
+	// RiskAgentAmount matches payment with amount above Multiplier times average amount of agent,
+	// rule is applied after agent created MinPayments payments
+	RiskAgentAmount RiskRuleType = "AGENT_AMOUNT"
+	// RiskVelocity matches payment when agent created Threshold payments during WindowSeconds including current one
+	RiskVelocity RiskRuleType = "VELOCITY"
+	// RiskPayerAgents matches payment when payer paid through Threshold distinct agents including current one
+	RiskPayerAgents RiskRuleType = "PAYER_AGENTS"
+)
+
+// RiskRule sends matched payment to RiskReview state
+type RiskRule struct {
+	Id            string       `json:"id"`
+	Type          RiskRuleType `json:"type"`
+	Threshold     uint         `json:"threshold"`
+	Multiplier    uint         `json:"multiplier"`
+	MinPayments   uint         `json:"minPayments"`
+	WindowSeconds int64        `json:"windowSeconds"`
+}
+
+// RiskRules is set by merchant, Version is incremented on every update
+type RiskRules struct {
+	Version uint       `json:"version"`
+	Rules   []RiskRule `json:"rules"`
+}
+
+// AgentRiskStats is on-ledger aggregate of payments created by agent
+// Recent holds unix timestamps of payments inside longest velocity window
+type AgentRiskStats struct {
+	AgentId     string  `json:"agentId"`
+	Count       uint    `json:"count"`
+	TotalAmount uint    `json:"totalAmount"`
+	Recent      []int64 `json:"recent"`
+}
+
+// PayerRiskStats is on-ledger aggregate of agents payer paid through, agents are sorted
+type PayerRiskStats struct {
+	PayerId string   `json:"payerId"`
+	Agents  []string `json:"agents"`
+}
+
+const RiskRulesUpdated = "RiskRulesUpdated"
@@ -0,0 +1,48 @@
+package entities
+
+type SettlementBatchState string
+
+const (
+	SettlementPending   SettlementBatchState = "Pending"
+	SettlementCompleted SettlementBatchState = "Completed"
+)
+
+// SettlementPair is gross flow between payer and recipient bank in one cycle
+type SettlementPair struct {
+	PayerBankOrgId     string   `json:"payerBankOrgId"`
+	RecipientBankOrgId string   `json:"recipientBankOrgId"`
+	Debited            uint     `json:"debited"`
+	Refunded           uint     `json:"refunded"`
+	Net                int64    `json:"net"`
+	AgentCommission    uint     `json:"agentCommission"`
+	MerchantFee        uint     `json:"merchantFee"`
+	PaymentIds         []string `json:"paymentIds"`
+}
+
+// SettlementPosition is multilateral net position of bank, positive value means bank receives funds
+type SettlementPosition struct {
+	BankOrgId string `json:"bankOrgId"`
+	Net       int64  `json:"net"`
+	Confirmed bool   `json:"confirmed"`
+}
+
+type SettlementBatch struct {
+	Id         string               `json:"id"`
+	State      SettlementBatchState `json:"state"`
+	CreatedAt  string               `json:"createdAt"`
+	Currency   string               `json:"currency"`
+	PaymentIds []string             `json:"paymentIds"`
+	RefundIds  []string             `json:"refundIds"`
+	Pairs      []SettlementPair     `json:"pairs"`
+	Positions  []SettlementPosition `json:"positions"`
+}
+
+type SettlementBatchEvent struct {
+	BatchId   string               `json:"batch_id"`
+	State     SettlementBatchState `json:"state"`
+	Positions []SettlementPosition `json:"positions"`
+}
+
+const SettlementBatchCreated = "SettlementBatchCreated"
+const SettlementBatchConfirmed = "SettlementBatchConfirmed"
+const SettlementBatchCompleted = "SettlementBatchCompleted"
@@ -0,0 +1,37 @@
+package entities
+
+// AttrSubAgent is certificate attribute with point of sale id of agent
+// Certificates without attribute act for agent itself and can manage all its points of sale
+const AttrSubAgent = "tickets.subAgent"
+
+// Permissions of point of sale, set by merchant
+const (
+	SubAgentCreate = "CREATE"
+	SubAgentIssue  = "ISSUE"
+	SubAgentCancel = "CANCEL"
+)
+
+// SubAgent is office or terminal of agent acting under agent MSP
+// MaxAmount limits single payment, Limit restricts payments of point of sale during rolling window
+type SubAgent struct {
+	Id          string     `json:"subAgentId"`
+	AgentId     string     `json:"agentId"`
+	Name        string     `json:"name"`
+	Permissions []string   `json:"permissions"`
+	MaxAmount   uint       `json:"maxAmount"`
+	Limit       AgentLimit `json:"limit"`
+	Disabled    bool       `json:"disabled"`
+	UpdatedAt   string     `json:"updatedAt"`
+}
+
+// Can returns true if point of sale has permission
+func (s SubAgent) Can(permission string) bool {
+	for _, p := range s.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+const SubAgentUpdated = "SubAgentUpdated"